@@ -8,6 +8,7 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -15,6 +16,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -22,6 +24,10 @@ const (
 	maxBodySize    = 10 * 1024 * 1024 // 10MB
 	defaultTimeout = 30 * time.Second
 	metadataFlavor = "Google"
+	// refreshMargin is how long before expiry a cached token is replaced.
+	refreshMargin = 60 * time.Second
+	// metadataAttempts bounds token requests to a metadata server returning 5xx.
+	metadataAttempts = 3
 	//nolint:revive // GCP metadata server only supports HTTP
 	defaultMetadataURL = "http://metadata.google.internal/computeMetadata/v1"
 )
@@ -30,6 +36,35 @@ var httpClient = &http.Client{
 	Timeout: defaultTimeout,
 }
 
+// ErrAuth is returned, wrapped, when a token endpoint rejects the caller's
+// credentials with 401 or 403. Retrying can't help; the credentials or their
+// permissions must change.
+var ErrAuth = errors.New("auth: credentials rejected")
+
+// statusError is a non-success response from a token or metadata endpoint.
+type statusError struct {
+	source     string
+	statusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned %d", e.source, e.statusCode)
+}
+
+// Unwrap maps rejected credentials to ErrAuth.
+func (e *statusError) Unwrap() error {
+	if e.statusCode == http.StatusUnauthorized || e.statusCode == http.StatusForbidden {
+		return ErrAuth
+	}
+	return nil
+}
+
+// transient reports whether err is a server error worth retrying.
+func transient(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.statusCode >= 500
+}
+
 // Config holds auth configuration.
 type Config struct {
 	// MetadataURL is the URL for the GCP metadata server.
@@ -39,8 +74,31 @@ type Config struct {
 	// SkipADC skips Application Default Credentials and goes straight to metadata server.
 	// Useful for testing to ensure mock servers are used.
 	SkipADC bool
+
+	tokens tokenCache
+}
+
+// tokenCache holds the access token last fetched for a Config. At most one
+// fetch is in flight at a time; concurrent callers wait for its result.
+type tokenCache struct {
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time
+	inflight *tokenFetch
+}
+
+// tokenFetch is a token request shared by every caller waiting on it.
+type tokenFetch struct {
+	done   chan struct{}
+	token  string
+	expiry time.Time
+	err    error
 }
 
+// defaultConfig is used when the context carries no Config, so that its
+// token cache is shared by every such caller.
+var defaultConfig = &Config{MetadataURL: defaultMetadataURL}
+
 // configKey is the key for storing Config in context.
 type configKey struct{}
 
@@ -54,41 +112,104 @@ func getConfig(ctx context.Context) *Config {
 	if cfg, ok := ctx.Value(configKey{}).(*Config); ok && cfg != nil {
 		return cfg
 	}
-	return &Config{
-		MetadataURL: defaultMetadataURL,
-		SkipADC:     false,
-	}
+	return defaultConfig
 }
 
 // AccessToken retrieves a GCP access token.
 // It tries Application Default Credentials first, then falls back to the metadata server.
 // Configuration can be provided via auth.WithConfig in the context.
+//
+// Tokens are cached per Config and reused until a minute before they expire.
+// When a token must be fetched, only one request is made however many
+// goroutines ask for it; the rest wait for its result or for their own
+// context to be done.
 func AccessToken(ctx context.Context) (string, error) {
 	cfg := getConfig(ctx)
+	c := &cfg.tokens
+
+	c.mu.Lock()
+	if c.token != "" && time.Until(c.expiry) > refreshMargin {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+	f := c.inflight
+	if f == nil {
+		f = &tokenFetch{done: make(chan struct{})}
+		c.inflight = f
+		// The fetch is shared, so one caller giving up must not fail the others
+		go c.fetch(context.WithoutCancel(ctx), cfg, f)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.token, f.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
 
+// fetch requests a new token for cfg, records it in c, and completes f.
+func (c *tokenCache) fetch(ctx context.Context, cfg *Config, f *tokenFetch) {
+	f.token, f.expiry, f.err = fetchAccessToken(ctx, cfg)
+
+	c.mu.Lock()
+	if f.err == nil {
+		c.token, c.expiry = f.token, f.expiry
+	}
+	c.inflight = nil
+	c.mu.Unlock()
+	close(f.done)
+}
+
+// fetchAccessToken requests a new access token and returns it with its expiry.
+func fetchAccessToken(ctx context.Context, cfg *Config) (string, time.Time, error) {
 	// Skip ADC if configured (useful for testing to ensure mock metadata server is used)
 	if !cfg.SkipADC {
 		// Try Application Default Credentials first (for local development)
-		token, err := accessTokenFromADC(ctx)
+		token, expiry, err := accessTokenFromADC(ctx)
 		if err == nil {
-			return token, nil
+			return token, expiry, nil
 		}
 	}
 
-	// Fall back to metadata server (for GCP environments or tests)
-	return accessTokenFromMetadata(ctx)
+	// Fall back to metadata server (for GCP environments or tests), retrying
+	// server errors with backoff: 100ms, 200ms
+	var token string
+	var expiry time.Time
+	var err error
+	for attempt := range metadataAttempts {
+		token, expiry, err = accessTokenFromMetadata(ctx)
+		if !transient(err) || attempt == metadataAttempts-1 {
+			break
+		}
+		slog.DebugContext(ctx, "metadata token request failed, will retry", "attempt", attempt+1, "error", err)
+		select {
+		case <-time.After(time.Duration(100*(1<<attempt)) * time.Millisecond):
+		case <-ctx.Done():
+			return "", time.Time{}, ctx.Err()
+		}
+	}
+	return token, expiry, err
+}
+
+// expiryAfter returns when a token issued now with the given lifetime in
+// seconds expires.
+func expiryAfter(expiresIn int) time.Time {
+	return time.Now().Add(time.Duration(expiresIn) * time.Second)
 }
 
 // accessTokenFromADC retrieves an access token from Application Default Credentials.
 // This supports gcloud auth application-default login for local development.
-func accessTokenFromADC(ctx context.Context) (string, error) {
+func accessTokenFromADC(ctx context.Context) (string, time.Time, error) {
 	// Check GOOGLE_APPLICATION_CREDENTIALS environment variable
 	credsFile := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
 	if credsFile == "" {
 		// Check well-known ADC location
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
-			return "", fmt.Errorf("failed to get home directory: %w", err)
+			return "", time.Time{}, fmt.Errorf("failed to get home directory: %w", err)
 		}
 		credsFile = homeDir + "/.config/gcloud/application_default_credentials.json"
 	}
@@ -96,7 +217,7 @@ func accessTokenFromADC(ctx context.Context) (string, error) {
 	// Read credentials file
 	data, err := os.ReadFile(credsFile)
 	if err != nil {
-		return "", fmt.Errorf("failed to read credentials file: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to read credentials file: %w", err)
 	}
 
 	// Parse credentials
@@ -108,12 +229,12 @@ func accessTokenFromADC(ctx context.Context) (string, error) {
 	}
 
 	if err := json.Unmarshal(data, &creds); err != nil {
-		return "", fmt.Errorf("failed to parse credentials: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to parse credentials: %w", err)
 	}
 
 	// Only support authorized_user type (from gcloud auth application-default login)
 	if creds.Type != "authorized_user" {
-		return "", fmt.Errorf("unsupported credential type: %s", creds.Type)
+		return "", time.Time{}, fmt.Errorf("unsupported credential type: %s", creds.Type)
 	}
 
 	// Exchange refresh token for access token
@@ -121,7 +242,7 @@ func accessTokenFromADC(ctx context.Context) (string, error) {
 }
 
 // exchangeRefreshToken exchanges a refresh token for an access token.
-func exchangeRefreshToken(ctx context.Context, clientID, clientSecret, refreshToken string) (string, error) {
+func exchangeRefreshToken(ctx context.Context, clientID, clientSecret, refreshToken string) (string, time.Time, error) {
 	tokenURL := "https://oauth2.googleapis.com/token" //nolint:gosec // This is Google's OAuth2 token endpoint, not a hardcoded credential
 
 	// Use url.Values for proper URL encoding to prevent parameter injection
@@ -135,13 +256,13 @@ func exchangeRefreshToken(ctx context.Context, clientID, clientSecret, refreshTo
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(reqBody))
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("token exchange failed: %w", err)
+		return "", time.Time{}, fmt.Errorf("token exchange failed: %w", err)
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
@@ -152,16 +273,16 @@ func exchangeRefreshToken(ctx context.Context, clientID, clientSecret, refreshTo
 	if resp.StatusCode != http.StatusOK {
 		body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
 		if readErr != nil {
-			return "", fmt.Errorf("token exchange returned %d", resp.StatusCode)
+			return "", time.Time{}, &statusError{source: "token exchange", statusCode: resp.StatusCode}
 		}
 		// Log full error details but return sanitized message to prevent information leakage
 		slog.ErrorContext(ctx, "OAuth token exchange failed", "status", resp.StatusCode, "response", string(body))
-		return "", fmt.Errorf("token exchange returned %d", resp.StatusCode)
+		return "", time.Time{}, &statusError{source: "token exchange", statusCode: resp.StatusCode}
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
 	var tokenResp struct {
@@ -170,27 +291,27 @@ func exchangeRefreshToken(ctx context.Context, clientID, clientSecret, refreshTo
 	}
 
 	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return "", fmt.Errorf("failed to parse token response: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to parse token response: %w", err)
 	}
 
-	return tokenResp.AccessToken, nil
+	return tokenResp.AccessToken, expiryAfter(tokenResp.ExpiresIn), nil
 }
 
 // accessTokenFromMetadata retrieves an access token from the GCP metadata server.
 // This is used when running on GCP (GCE, GKE, Cloud Run, etc.).
-func accessTokenFromMetadata(ctx context.Context) (string, error) {
+func accessTokenFromMetadata(ctx context.Context) (string, time.Time, error) {
 	cfg := getConfig(ctx)
 	reqURL := cfg.MetadataURL + "/instance/service-accounts/default/token"
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, http.NoBody)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 	req.Header.Set("Metadata-Flavor", metadataFlavor)
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("token request failed: %w", err)
+		return "", time.Time{}, fmt.Errorf("token request failed: %w", err)
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
@@ -199,12 +320,12 @@ func accessTokenFromMetadata(ctx context.Context) (string, error) {
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("metadata server returned %d", resp.StatusCode)
+		return "", time.Time{}, &statusError{source: "metadata server", statusCode: resp.StatusCode}
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
 	var tokenResp struct {
@@ -213,10 +334,10 @@ func accessTokenFromMetadata(ctx context.Context) (string, error) {
 	}
 
 	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return "", fmt.Errorf("failed to parse token: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to parse token: %w", err)
 	}
 
-	return tokenResp.AccessToken, nil
+	return tokenResp.AccessToken, expiryAfter(tokenResp.ExpiresIn), nil
 }
 
 // ProjectID retrieves the project ID from the GCP metadata server.
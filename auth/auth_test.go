@@ -3,13 +3,17 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestWithConfig(t *testing.T) {
@@ -113,7 +117,7 @@ func TestAccessTokenFromMetadata(t *testing.T) {
 				SkipADC:     true,
 			})
 
-			token, err := accessTokenFromMetadata(ctx)
+			token, _, err := accessTokenFromMetadata(ctx)
 
 			if tt.wantErr {
 				if err == nil {
@@ -283,7 +287,7 @@ func TestAccessTokenFromADC(t *testing.T) {
 
 			// Note: This will try to hit the real OAuth endpoint
 			// We'll mark this as expected to fail for now
-			token, err := accessTokenFromADC(ctx)
+			token, _, err := accessTokenFromADC(ctx)
 
 			if tt.wantErr {
 				if err == nil {
@@ -381,7 +385,7 @@ func TestAccessTokenMetadataServerDown(t *testing.T) {
 		MetadataURL: "http://localhost:59999",
 		SkipADC:     true,
 	})
-	_, err := accessTokenFromMetadata(ctx)
+	_, _, err := accessTokenFromMetadata(ctx)
 
 	if err == nil {
 		t.Error("expected error when metadata server is down, got nil")
@@ -467,7 +471,7 @@ func TestAccessTokenFromMetadataReadError(t *testing.T) {
 		MetadataURL: server.URL,
 		SkipADC:     true,
 	})
-	_, err := accessTokenFromMetadata(ctx)
+	_, _, err := accessTokenFromMetadata(ctx)
 
 	if err == nil {
 		t.Error("expected error on invalid JSON")
@@ -523,7 +527,7 @@ func TestAccessTokenFromMetadataWithMalformedJSON(t *testing.T) {
 		MetadataURL: server.URL,
 		SkipADC:     true,
 	})
-	_, err := accessTokenFromMetadata(ctx)
+	_, _, err := accessTokenFromMetadata(ctx)
 	// Should either succeed (if parser is lenient) or fail with parse error
 	if err != nil {
 		t.Logf("Got expected error parsing malformed JSON: %v", err)
@@ -578,7 +582,7 @@ func TestAccessTokenFromADCWithServiceAccount(t *testing.T) {
 	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", credsFile)
 
 	ctx := context.Background()
-	_, err := accessTokenFromADC(ctx)
+	_, _, err := accessTokenFromADC(ctx)
 
 	if err == nil {
 		t.Error("expected error for unsupported credential type")
@@ -595,7 +599,7 @@ func TestAccessTokenFromADCMissingFile(t *testing.T) {
 	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "/nonexistent/path/credentials.json")
 
 	ctx := context.Background()
-	_, err := accessTokenFromADC(ctx)
+	_, _, err := accessTokenFromADC(ctx)
 
 	if err == nil {
 		t.Error("expected error for missing credentials file")
@@ -619,7 +623,7 @@ func TestAccessTokenFromADCInvalidJSON(t *testing.T) {
 	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", credsFile)
 
 	ctx := context.Background()
-	_, err := accessTokenFromADC(ctx)
+	_, _, err := accessTokenFromADC(ctx)
 
 	if err == nil {
 		t.Error("expected error for invalid JSON")
@@ -736,7 +740,7 @@ func TestAccessTokenFromADCDefaultLocation(t *testing.T) {
 	ctx := context.Background()
 	// This will try to exchange the refresh token, which will fail
 	// because we're not mocking the OAuth endpoint
-	_, err := accessTokenFromADC(ctx)
+	_, _, err := accessTokenFromADC(ctx)
 
 	if err == nil {
 		t.Log("accessTokenFromADC succeeded (unexpected)")
@@ -780,9 +784,143 @@ func TestAccessTokenFromMetadataTypeError(t *testing.T) {
 		MetadataURL: server.URL,
 		SkipADC:     true,
 	})
-	_, err := accessTokenFromMetadata(ctx)
+	_, _, err := accessTokenFromMetadata(ctx)
 
 	if err == nil {
 		t.Error("expected error for invalid expires_in type")
 	}
 }
+
+func TestAccessTokenSingleFlight(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		// Hold the request open so every caller arrives while it is in flight
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "fresh-token",
+			"expires_in":   3600,
+		}); err != nil {
+			t.Logf("encode failed: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &Config{MetadataURL: server.URL, SkipADC: true}
+	ctx := WithConfig(context.Background(), cfg)
+
+	// Start from a token that is about to expire
+	cfg.tokens.token = "stale-token"
+	cfg.tokens.expiry = time.Now().Add(30 * time.Second)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 50)
+	errs := make([]error, 50)
+	for i := range 50 {
+		wg.Go(func() {
+			tokens[i], errs[i] = AccessToken(ctx)
+		})
+	}
+	wg.Wait()
+
+	for i := range 50 {
+		if errs[i] != nil {
+			t.Fatalf("AccessToken %d failed: %v", i, errs[i])
+		}
+		if tokens[i] != "fresh-token" {
+			t.Errorf("AccessToken %d = %q, want fresh-token", i, tokens[i])
+		}
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("token endpoint hit %d times, want 1", got)
+	}
+
+	// The refreshed token is served from the cache
+	if _, err := AccessToken(ctx); err != nil {
+		t.Fatalf("AccessToken failed: %v", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("token endpoint hit %d times after cached read, want 1", got)
+	}
+}
+
+func TestAccessTokenFailureNotCached(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "second-token",
+			"expires_in":   3600,
+		}); err != nil {
+			t.Logf("encode failed: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	ctx := WithConfig(context.Background(), &Config{MetadataURL: server.URL, SkipADC: true})
+	if _, err := AccessToken(ctx); err == nil {
+		t.Fatal("expected first fetch to fail")
+	}
+	token, err := AccessToken(ctx)
+	if err != nil {
+		t.Fatalf("AccessToken failed: %v", err)
+	}
+	if token != "second-token" {
+		t.Errorf("expected second-token, got %q", token)
+	}
+}
+
+func TestAccessTokenRejectedCredentials(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		var hits atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			w.WriteHeader(status)
+		}))
+
+		ctx := WithConfig(context.Background(), &Config{MetadataURL: server.URL, SkipADC: true})
+		_, err := AccessToken(ctx)
+		server.Close()
+		if !errors.Is(err, ErrAuth) {
+			t.Errorf("status %d: expected ErrAuth, got %v", status, err)
+		}
+		if got := hits.Load(); got != 1 {
+			t.Errorf("status %d: token endpoint hit %d times, want 1", status, got)
+		}
+	}
+}
+
+func TestAccessTokenRetriesServerErrors(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "recovered-token",
+			"expires_in":   3600,
+		}); err != nil {
+			t.Logf("encode failed: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	ctx := WithConfig(context.Background(), &Config{MetadataURL: server.URL, SkipADC: true})
+	token, err := AccessToken(ctx)
+	if err != nil {
+		t.Fatalf("AccessToken failed: %v", err)
+	}
+	if token != "recovered-token" {
+		t.Errorf("expected recovered-token, got %q", token)
+	}
+	if got := hits.Load(); got != 3 {
+		t.Errorf("token endpoint hit %d times, want 3", got)
+	}
+}
@@ -345,7 +345,7 @@ func TestIntegrationCleanup(t *testing.T) {
 
 	t.Run("CleanupTestEntities", func(t *testing.T) {
 		// Delete all test entities
-		err := client.DeleteAllByKind(ctx, testKind)
+		_, err := client.DeleteAllByKind(ctx, testKind)
 		if err != nil {
 			t.Fatalf("Failed to delete test entities: %v", err)
 		}
@@ -0,0 +1,52 @@
+package datastore
+
+import "context"
+
+// Batch buffers non-transactional writes, possibly across kinds, and sends
+// them in as few commits as possible when flushed. Unlike a transaction, a
+// batch holds no locks and its commits are independent: if one fails, those
+// before it stay applied. A Batch is not safe for concurrent use.
+type Batch struct {
+	client *Client
+	muts   []*Mutation
+}
+
+// NewBatch returns an empty Batch that writes with c.
+func (c *Client) NewBatch() *Batch {
+	return &Batch{client: c}
+}
+
+// Put buffers an upsert of src at key. An incomplete key is completed when
+// the batch is flushed.
+func (b *Batch) Put(key *Key, src any) {
+	b.muts = append(b.muts, NewUpsert(key, src))
+}
+
+// Delete buffers a delete of the entity at key.
+func (b *Batch) Delete(key *Key) {
+	b.muts = append(b.muts, NewDelete(key))
+}
+
+// Len returns the number of buffered writes.
+func (b *Batch) Len() int {
+	return len(b.muts)
+}
+
+// Flush commits the buffered writes in order, up to 500 per commit, and
+// returns their keys in the order they were buffered, with incomplete keys
+// completed. On error, Flush returns the keys of the writes already committed;
+// the rest stay buffered, so Flush may be called again to retry them.
+func (b *Batch) Flush(ctx context.Context) ([]*Key, error) {
+	keys := make([]*Key, 0, len(b.muts))
+	for len(b.muts) > 0 {
+		n := min(len(b.muts), maxMutationBatch)
+		committed, err := b.client.Mutate(ctx, b.muts[:n]...)
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, committed...)
+		b.muts = b.muts[n:]
+	}
+	b.muts = nil
+	return keys, nil
+}
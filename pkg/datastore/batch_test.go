@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/codeGROOVE-dev/ds9/auth"
 	"github.com/codeGROOVE-dev/ds9/pkg/datastore"
+	"github.com/codeGROOVE-dev/ds9/pkg/mock"
 )
 
 func TestBatchOperations(t *testing.T) {
@@ -92,3 +94,68 @@ func TestAllocateIDsBatch(t *testing.T) {
 		seen[k.ID] = true
 	}
 }
+
+func TestClientBatchFlush(t *testing.T) {
+	metadataURL, apiURL, cleanup := mock.NewMockServers(t)
+	defer cleanup()
+
+	collector := &fakeCollector{}
+	ctx := context.Background()
+	client, err := datastore.NewClient(ctx, "test-project",
+		datastore.WithEndpoint(apiURL),
+		datastore.WithAuth(&auth.Config{MetadataURL: metadataURL, SkipADC: true}),
+		datastore.WithMetrics(collector),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	// 600 puts alternating between two kinds, half with incomplete keys
+	b := client.NewBatch()
+	for i := range 600 {
+		if i%2 == 0 {
+			b.Put(datastore.NameKey("Author", fmt.Sprintf("a%d", i), nil), &testEntity{Name: "author", Count: int64(i)})
+		} else {
+			b.Put(datastore.IncompleteKey("Book", nil), &testEntity{Name: "book", Count: int64(i)})
+		}
+	}
+	if b.Len() != 600 {
+		t.Fatalf("expected 600 buffered writes, got %d", b.Len())
+	}
+
+	keys, err := b.Flush(ctx)
+	if err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("expected an empty batch after Flush, got %d", b.Len())
+	}
+
+	collector.mu.Lock()
+	commits := 0
+	for _, r := range collector.requests {
+		if r.method == "commit" {
+			commits++
+		}
+	}
+	collector.mu.Unlock()
+	if commits != 2 {
+		t.Errorf("expected 2 commit requests, got %d", commits)
+	}
+
+	if len(keys) != 600 {
+		t.Fatalf("expected 600 keys, got %d", len(keys))
+	}
+	got := make([]testEntity, len(keys))
+	if err := client.GetMulti(ctx, keys, got); err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	for i, k := range keys {
+		if k.Incomplete() {
+			t.Fatalf("key %d was not completed", i)
+		}
+		if got[i].Count != int64(i) {
+			t.Errorf("key %s: expected count %d, got %d", k, i, got[i].Count)
+		}
+	}
+}
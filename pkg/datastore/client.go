@@ -7,9 +7,12 @@ package datastore
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,9 +23,12 @@ const (
 	maxRetries     = 3
 	maxBodySize    = 10 * 1024 * 1024 // 10MB
 	defaultTimeout = 30 * time.Second
-	baseBackoffMS  = 100  // Start with 100ms
-	maxBackoffMS   = 2000 // Cap at 2 seconds
-	jitterFraction = 0.25 // 25% jitter
+	baseBackoff    = 100 * time.Millisecond // Start with 100ms
+	maxBackoff     = 2 * time.Second        // Cap at 2 seconds
+	jitterFraction = 0.25                   // 25% jitter
+
+	compressionThreshold  = 8 * 1024         // Request size above which WithCompression gzips bodies
+	defaultMaxRequestSize = 10 * 1024 * 1024 // Datastore's limit on the size of a commit request
 )
 
 const (
@@ -46,30 +52,73 @@ var (
 		"<=": "LESS_THAN_OR_EQUAL",
 		">":  "GREATER_THAN",
 		">=": "GREATER_THAN_OR_EQUAL",
+		"!=": "NOT_EQUAL",
 	}
 )
 
+// RetryConfig controls how requests that fail with a transient error are retried.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Values below 1 mean a single attempt.
+	MaxAttempts int
+	// BaseBackoff is the delay before the first retry; it doubles on each retry.
+	// Zero means the default of 100ms.
+	BaseBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. Zero means the default of 2s.
+	MaxBackoff time.Duration
+}
+
+// defaultRetry is the retry configuration used unless overridden.
+var defaultRetry = RetryConfig{
+	MaxAttempts: maxRetries,
+	BaseBackoff: baseBackoff,
+	MaxBackoff:  maxBackoff,
+}
+
 // ClientOption is an option for a Datastore client.
 type ClientOption func(*clientOptionsInternal)
 
 // clientOptionsInternal holds internal client configuration that can be modified by ClientOption.
 type clientOptionsInternal struct {
-	authConfig *auth.Config
-	logger     *slog.Logger
-	baseURL    string
+	authConfig     *auth.Config
+	logger         *slog.Logger
+	baseURL        string
+	namespace      string
+	keyRewrite     func(*Key) *Key
+	keyReverse     func(*Key) *Key
+	kindTypes      map[string]reflect.Type
+	loadMigrations map[string]func(PropertyList) (PropertyList, bool)
+	tracer         Tracer
+	metrics        MetricsCollector
+	readRetry      RetryConfig
+	commitRetry    RetryConfig
+	timeout        time.Duration
+	strictLimit    bool
+	countFallback  bool
+	compress       bool
+	retryableCodes map[string]bool
+	emptyBatchNoOp bool
+	strictEmpty    bool
+	maxRequestSize int
+	maxProperties  int
+	inequalities   bool
 }
 
-// WithEndpoint returns a ClientOption that sets the API base URL.
+// WithEndpoint returns a ClientOption that sets the Datastore API base URL for
+// this client, such as a regional endpoint, a private Google access address,
+// or a proxy. The default is https://datastore.googleapis.com/v1. The metadata
+// server used for credentials is configured separately, with WithAuth.
 func WithEndpoint(url string) ClientOption {
 	return func(o *clientOptionsInternal) {
-		o.baseURL = url
+		o.baseURL = strings.TrimRight(url, "/")
 	}
 }
 
 // WithLogger returns a ClientOption that sets the logger.
-func WithLogger(logger *slog.Logger) ClientOption {
+// By default the client doesn't log; a *slog.Logger may be passed directly.
+func WithLogger(logger Logger) ClientOption {
 	return func(o *clientOptionsInternal) {
-		o.logger = logger
+		o.logger = newSlogLogger(logger)
 	}
 }
 
@@ -80,13 +129,195 @@ func WithAuth(cfg *auth.Config) ClientOption {
 	}
 }
 
+// WithReadRetry returns a ClientOption that sets how lookups, queries, and
+// other read-only requests are retried.
+func WithReadRetry(cfg RetryConfig) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.readRetry = cfg
+	}
+}
+
+// WithCommitRetry returns a ClientOption that sets how non-transactional
+// commits (Put, Delete, Mutate, and their Multi variants) are retried.
+// Commits may not be idempotent, so callers may prefer fewer attempts than for reads.
+func WithCommitRetry(cfg RetryConfig) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.commitRetry = cfg
+	}
+}
+
+// WithRetryableCodes returns a ClientOption that retries failed requests only
+// when the response carries one of the given canonical codes, such as
+// "ABORTED", "UNAVAILABLE", or "DEADLINE_EXCEEDED". Responses without a code
+// in their body are classified by HTTP status. This replaces the default of
+// retrying every 5xx response other than 501; the number of attempts is still
+// set by WithReadRetry and WithCommitRetry.
+func WithRetryableCodes(codes ...string) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.retryableCodes = make(map[string]bool, len(codes))
+		for _, code := range codes {
+			o.retryableCodes[code] = true
+		}
+	}
+}
+
+// WithTimeout returns a ClientOption that bounds each RPC, including its
+// retries, to d. A caller's context with an earlier deadline still applies.
+// By default RPCs are bounded only by the caller's context.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.timeout = d
+	}
+}
+
+// WithNamespace returns a ClientOption that sets the default namespace for all
+// operations. Keys and queries that specify their own namespace are unaffected.
+func WithNamespace(ns string) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.namespace = ns
+	}
+}
+
+// WithKindType returns a ClientOption that registers the struct type of example
+// as the type entities of kind decode into with GetAllTyped. example may be a
+// struct or a pointer to one.
+func WithKindType(kind string, example any) ClientOption {
+	return func(o *clientOptionsInternal) {
+		t := reflect.TypeOf(example)
+		if t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if o.kindTypes == nil {
+			o.kindTypes = make(map[string]reflect.Type)
+		}
+		o.kindTypes[kind] = t
+	}
+}
+
+// WithCompression returns a ClientOption that controls gzip compression of
+// Datastore API traffic. When enabled, request bodies larger than 8KB are sent
+// gzip-encoded and gzip-encoded responses are accepted. Compression is disabled
+// by default; authentication and metadata requests are never compressed.
+func WithCompression(enabled bool) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.compress = enabled
+	}
+}
+
+// WithStrictLimit returns a ClientOption under which Query.Limit(0) returns no
+// results, matching cloud.google.com/go/datastore. Without it, Limit(0) means
+// no limit, as in earlier versions of this package. In either mode a negative
+// or unset limit means no limit.
+func WithStrictLimit() ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.strictLimit = true
+	}
+}
+
+// WithCountFallback returns a ClientOption that controls whether Count falls
+// back to counting the keys of a keys-only query when the server doesn't
+// support aggregation queries, as with the emulator or older deployments.
+// The fallback is disabled by default.
+func WithCountFallback(enabled bool) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.countFallback = enabled
+	}
+}
+
+// WithEmptyBatchNoOp returns a ClientOption under which GetMulti and
+// GetMultiFunc return nil for an empty slice of keys, leaving dst unchanged,
+// instead of an error. PutMulti and DeleteMulti are always no-ops for no keys.
+func WithEmptyBatchNoOp() ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.emptyBatchNoOp = true
+	}
+}
+
+// WithInequalityCheck returns a ClientOption that controls whether queries
+// with inequality filters on more than one property fail with
+// ErrMultipleInequality before they are sent. The check is enabled by
+// default; disable it for Firestore in Datastore mode, which accepts such
+// queries.
+func WithInequalityCheck(enabled bool) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.inequalities = enabled
+	}
+}
+
+// WithStrictEmptyEntities returns a ClientOption under which writing an entity
+// with no properties, such as a struct whose fields are all tagged "-", fails
+// with ErrEmptyEntity. By default such writes are logged as a warning and sent.
+func WithStrictEmptyEntities() ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.strictEmpty = true
+	}
+}
+
+// WithMaxRequestSize returns a ClientOption that sets the largest commit
+// request body, in bytes, the client will send. Writes, including transaction
+// commits, whose serialized body is larger fail with ErrRequestTooLarge before
+// the commit is sent, rather than with a generic error from Datastore. The
+// default is 10MB, Datastore's own limit; n of zero or less disables the check.
+func WithMaxRequestSize(n int) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.maxRequestSize = n
+	}
+}
+
+// WithMaxProperties returns a ClientOption under which writing an entity with
+// more than n property values fails with ErrTooManyProperties before any
+// request is made. Each element of an array property and each property of a
+// nested entity counts as a value, since each needs its own index entry.
+// Datastore enforces its own caps, but reports exceeding them opaquely.
+// By default there is no limit.
+func WithMaxProperties(n int) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.maxProperties = n
+	}
+}
+
+// WithKeyRewriter returns a ClientOption that rewrites every key sent to
+// Datastore with rewrite, and every key returned by Datastore with reverse.
+// This lets a multi-tenant application enforce isolation centrally, for
+// example by prefixing kinds or names per tenant.
+//
+// reverse must exactly undo rewrite, so that reverse(rewrite(k)) equals k;
+// otherwise lookups cannot match results to the keys requested. Queries are
+// rewritten as if for an incomplete key of the query's kind and namespace,
+// and ancestor filters are rewritten like any other key.
+func WithKeyRewriter(rewrite, reverse func(*Key) *Key) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.keyRewrite = rewrite
+		o.keyReverse = reverse
+	}
+}
+
 // Client is a Google Cloud Datastore client.
 type Client struct {
-	logger     *slog.Logger
-	authConfig *auth.Config // Auth configuration for this client
-	projectID  string
-	databaseID string
-	baseURL    string // API base URL, defaults to production
+	logger         *slog.Logger
+	authConfig     *auth.Config                                       // Auth configuration for this client
+	keyRewrite     func(*Key) *Key                                    // Applied to keys sent to Datastore
+	keyReverse     func(*Key) *Key                                    // Applied to keys returned by Datastore
+	kindTypes      map[string]reflect.Type                            // Struct types registered for GetAllTyped
+	loadMigrations map[string]func(PropertyList) (PropertyList, bool) // Per-kind migrations applied by Get and GetMulti
+	tracer         Tracer
+	metrics        MetricsCollector
+	projectID      string
+	databaseID     string
+	baseURL        string          // API base URL, defaults to production
+	namespace      string          // Default namespace for keys and queries without one
+	retryableCodes map[string]bool // Canonical codes to retry, or nil to retry 5xx responses
+	readRetry      RetryConfig
+	commitRetry    RetryConfig
+	timeout        time.Duration // Per-RPC deadline, or zero for none
+	strictLimit    bool          // Limit(0) returns no results
+	countFallback  bool          // Count via keys-only query if aggregation is unsupported
+	compress       bool          // Gzip large requests and accept gzip responses
+	emptyBatchNoOp bool          // GetMulti with no keys returns nil instead of an error
+	strictEmpty    bool          // Writing an entity with no properties fails instead of warning
+	maxRequestSize int           // Largest commit body sent, or zero for no limit
+	maxProperties  int           // Most property values per written entity, or zero for no limit
+	inequalities   bool          // Reject queries with inequalities on more than one property
 }
 
 // NewClient creates a new Datastore client.
@@ -101,8 +332,12 @@ func NewClient(ctx context.Context, projectID string, opts ...ClientOption) (*Cl
 func NewClientWithDatabase(ctx context.Context, projID, dbID string, opts ...ClientOption) (*Client, error) {
 	// Apply default internal options
 	options := &clientOptionsInternal{
-		baseURL: defaultAPIURL,
-		logger:  slog.Default(),
+		baseURL:        defaultAPIURL,
+		logger:         slog.New(slog.DiscardHandler),
+		readRetry:      defaultRetry,
+		commitRetry:    defaultRetry,
+		maxRequestSize: defaultMaxRequestSize,
+		inequalities:   true,
 	}
 
 	// Apply provided options
@@ -142,11 +377,30 @@ func NewClientWithDatabase(ctx context.Context, projID, dbID string, opts ...Cli
 	}
 
 	return &Client{
-		projectID:  projID,
-		databaseID: dbID,
-		baseURL:    baseURL,
-		authConfig: options.authConfig, // Use authConfig from options
-		logger:     options.logger,     // Use logger from options
+		projectID:      projID,
+		databaseID:     dbID,
+		baseURL:        baseURL,
+		namespace:      options.namespace,
+		authConfig:     options.authConfig, // Use authConfig from options
+		logger:         withRequestIDs(options.logger),
+		strictLimit:    options.strictLimit,
+		countFallback:  options.countFallback,
+		compress:       options.compress,
+		emptyBatchNoOp: options.emptyBatchNoOp,
+		strictEmpty:    options.strictEmpty,
+		inequalities:   options.inequalities,
+		keyRewrite:     options.keyRewrite,
+		keyReverse:     options.keyReverse,
+		kindTypes:      options.kindTypes,
+		loadMigrations: options.loadMigrations,
+		tracer:         options.tracer,
+		metrics:        options.metrics,
+		retryableCodes: options.retryableCodes,
+		readRetry:      options.readRetry,
+		commitRetry:    options.commitRetry,
+		timeout:        options.timeout,
+		maxRequestSize: max(options.maxRequestSize, 0),
+		maxProperties:  max(options.maxProperties, 0),
 	}, nil
 }
 
@@ -157,6 +411,21 @@ func (*Client) Close() error {
 	return nil
 }
 
+// pingKind is the kind Ping queries. Nothing is ever stored under it.
+const pingKind = "ds9Ping"
+
+// Ping checks that Datastore is reachable and accepts the client's
+// credentials, for use as a readiness check. It runs a keys-only query for at
+// most one entity of a kind that is never written. Failures wrap ErrAuth if
+// the credentials or access token were rejected, and ErrUnavailable otherwise.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.AllKeys(ctx, NewQuery(pingKind).KeysOnly().Limit(1))
+	if err == nil || errors.Is(err, ErrAuth) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
+
 // withClientConfig returns a context with the client's auth configuration injected.
 // This ensures that operations use the client's auth settings even if the caller
 // passes a bare context.Background().
@@ -166,3 +435,159 @@ func (c *Client) withClientConfig(ctx context.Context) context.Context {
 	}
 	return ctx
 }
+
+// outKey returns k as it should be sent to Datastore.
+func (c *Client) outKey(k *Key) *Key {
+	if k == nil {
+		return nil
+	}
+	if c.namespace != "" && k.Namespace == "" {
+		k = withNamespace(k, c.namespace)
+	}
+	if c.keyRewrite == nil {
+		return k
+	}
+	return c.keyRewrite(k)
+}
+
+// withNamespace returns a copy of k with ns set on it and all of its ancestors.
+func withNamespace(k *Key, ns string) *Key {
+	if k == nil {
+		return nil
+	}
+	nk := *k
+	nk.Namespace = ns
+	nk.Parent = withNamespace(k.Parent, ns)
+	return &nk
+}
+
+// inKey returns k as it should be returned to the caller.
+func (c *Client) inKey(k *Key) *Key {
+	if c.keyReverse == nil || k == nil {
+		return k
+	}
+	return c.keyReverse(k)
+}
+
+// inEntity returns entity with its key mapped back by inKey, so that decoded
+// keys and __key__ fields match what the caller wrote. entity is not modified.
+func (c *Client) inEntity(entity map[string]any) map[string]any {
+	if c.keyReverse == nil || entity == nil {
+		return entity
+	}
+	key, err := keyFromJSON(entity["key"])
+	if err != nil {
+		return entity
+	}
+	out := make(map[string]any, len(entity))
+	for k, v := range entity {
+		out[k] = v
+	}
+	out["key"] = keyToJSON(c.inKey(key))
+	return out
+}
+
+// outQuery returns a copy of q with its kind, namespace, ancestor, and __key__
+// filter values rewritten for Datastore, or q itself if there is nothing to rewrite.
+func (c *Client) outQuery(q *Query) *Query {
+	if c.keyRewrite == nil && c.namespace == "" {
+		return q
+	}
+	rq := *q
+	if rq.namespace == "" {
+		rq.namespace = c.namespace
+	}
+	if c.keyRewrite != nil && q.kind != "" {
+		k := c.keyRewrite(&Key{Kind: q.kind, Namespace: rq.namespace})
+		rq.kind, rq.namespace = k.Kind, k.Namespace
+	}
+	rq.ancestor = c.outKey(q.ancestor)
+	rq.filters = make([]queryFilter, len(q.filters))
+	for i, f := range q.filters {
+		if f.property == "__key__" {
+			f.value = rewriteKeyValue(f.value, c.outKey)
+		}
+		rq.filters[i] = f
+	}
+	rq.entityFilters = make([]Filter, len(q.entityFilters))
+	for i, f := range q.entityFilters {
+		rq.entityFilters[i] = f.rewriteKeys(c.outKey)
+	}
+	return &rq
+}
+
+// readTimeKey is the context key for a snapshot read time.
+type readTimeKey struct{}
+
+// WithReadTimeContext returns a context under which all non-transactional reads
+// (lookups, queries, and counts) are served from the snapshot at time t.
+func WithReadTimeContext(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, readTimeKey{}, t)
+}
+
+// requestIDKey is the context key for a request ID.
+type requestIDKey struct{}
+
+// requestIDHeader is the header that carries a context's request ID.
+const requestIDHeader = "X-Request-ID"
+
+// WithRequestID returns a context under which every Datastore request carries
+// id in the X-Request-ID header, and the client's logs for the operation
+// include it as request_id, for correlating requests across services.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestID returns the request ID carried by ctx, or "" if there is none.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string) //nolint:errcheck // Absent means no request ID
+	return id
+}
+
+// setRequestID sets the request ID header on req from its context, if any.
+func setRequestID(req *http.Request) {
+	if id := requestID(req.Context()); id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+}
+
+// readMetaKey is the context key for a *ReadMeta.
+type readMetaKey struct{}
+
+// ReadMeta holds metadata that Datastore reports about a read.
+type ReadMeta struct {
+	// ReadTime is the time at which the most recent read was served.
+	ReadTime time.Time
+}
+
+// WithReadMeta returns a context under which lookups, queries, and counts
+// record the read time echoed by Datastore into meta. Combined with
+// WithReadTimeContext, this lets callers verify the snapshot they were served.
+func WithReadMeta(ctx context.Context, meta *ReadMeta) context.Context {
+	return context.WithValue(ctx, readMetaKey{}, meta)
+}
+
+// recordReadTime stores the read time echoed in a response into the
+// context's ReadMeta, if there is one. Unparseable times are ignored.
+func recordReadTime(ctx context.Context, readTime string) {
+	meta, ok := ctx.Value(readMetaKey{}).(*ReadMeta)
+	if !ok || meta == nil || readTime == "" {
+		return
+	}
+	if t, err := time.Parse(time.RFC3339Nano, readTime); err == nil {
+		meta.ReadTime = t
+	}
+}
+
+// readOptions returns the readOptions for a non-transactional read on ctx,
+// or nil if the read should use the defaults. A snapshot read time from the
+// context takes precedence over eventual consistency, since they're exclusive.
+func readOptions(ctx context.Context, eventual bool) map[string]any {
+	if t, ok := ctx.Value(readTimeKey{}).(time.Time); ok && !t.IsZero() {
+		return map[string]any{"readTime": t.UTC().Format(time.RFC3339Nano)}
+	}
+	if eventual {
+		return map[string]any{"readConsistency": "EVENTUAL"}
+	}
+	return nil
+}
@@ -3,13 +3,16 @@ package datastore_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/codeGROOVE-dev/ds9/auth"
 	"github.com/codeGROOVE-dev/ds9/pkg/datastore"
+	"github.com/codeGROOVE-dev/ds9/pkg/mock"
 )
 
 func TestNewClient(t *testing.T) {
@@ -209,3 +212,215 @@ func TestClose(t *testing.T) {
 		t.Errorf("Second Close() returned unexpected error: %v", err)
 	}
 }
+
+func TestWithKeyRewriter(t *testing.T) {
+	metadataURL, apiURL, cleanup := mock.NewMockServers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	authConfig := &auth.Config{MetadataURL: metadataURL, SkipADC: true}
+
+	const prefix = "tenant-a-"
+	rewrite := func(k *datastore.Key) *datastore.Key {
+		out := *k
+		out.Name = prefix + k.Name
+		return &out
+	}
+	reverse := func(k *datastore.Key) *datastore.Key {
+		out := *k
+		out.Name = strings.TrimPrefix(k.Name, prefix)
+		return &out
+	}
+
+	tenant, err := datastore.NewClient(ctx, "test-project",
+		datastore.WithEndpoint(apiURL),
+		datastore.WithAuth(authConfig),
+		datastore.WithKeyRewriter(rewrite, reverse),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	raw, err := datastore.NewClient(ctx, "test-project", datastore.WithEndpoint(apiURL), datastore.WithAuth(authConfig))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	key := datastore.NameKey("Order", "o1", nil)
+	if _, err := tenant.Put(ctx, key, &testEntity{Name: "order"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Stored under the prefixed key.
+	var stored testEntity
+	if err := raw.Get(ctx, datastore.NameKey("Order", prefix+"o1", nil), &stored); err != nil {
+		t.Fatalf("expected entity under prefixed key: %v", err)
+	}
+	if err := raw.Get(ctx, key, &stored); !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("expected no entity under unprefixed key, got %v", err)
+	}
+
+	// Read back through the rewriter with the original key.
+	var got testEntity
+	if err := tenant.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Name != "order" {
+		t.Errorf("expected name %q, got %q", "order", got.Name)
+	}
+
+	keys, err := tenant.AllKeys(ctx, datastore.NewQuery("Order").KeysOnly())
+	if err != nil {
+		t.Fatalf("AllKeys failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0].Name != "o1" {
+		t.Errorf("expected unprefixed key o1, got %v", keys)
+	}
+}
+
+func TestWithNamespace(t *testing.T) {
+	var namespaces []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Mutations []struct {
+				Upsert struct {
+					Key struct {
+						PartitionID struct {
+							NamespaceID string `json:"namespaceId"`
+						} `json:"partitionId"`
+					} `json:"key"`
+				} `json:"upsert"`
+			} `json:"mutations"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode failed: %v", err)
+		}
+		for _, m := range req.Mutations {
+			namespaces = append(namespaces, m.Upsert.Key.PartitionID.NamespaceID)
+		}
+		writeJSON(t, w, map[string]any{"mutationResults": []any{map[string]any{}}})
+	}, datastore.WithNamespace("prod"))
+
+	ctx := context.Background()
+	if _, err := client.Put(ctx, datastore.NameKey("Task", "a", nil), &testEntity{Name: "a"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	explicit := datastore.NameKey("Task", "b", nil)
+	explicit.Namespace = "staging"
+	if _, err := client.Put(ctx, explicit, &testEntity{Name: "b"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	if want := []string{"prod", "staging"}; !slices.Equal(namespaces, want) {
+		t.Errorf("expected namespaces %v, got %v", want, namespaces)
+	}
+}
+
+func TestWithNamespaceRoundTrip(t *testing.T) {
+	metadataURL, apiURL, cleanup := mock.NewMockServers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	client, err := datastore.NewClient(ctx, "test-project",
+		datastore.WithEndpoint(apiURL),
+		datastore.WithAuth(&auth.Config{MetadataURL: metadataURL, SkipADC: true}),
+		datastore.WithNamespace("prod"),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	key := datastore.NameKey("Task", "a", nil)
+	if _, err := client.Put(ctx, key, &testEntity{Name: "a"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var got []testEntity
+	if err := client.GetMulti(ctx, []*datastore.Key{key}, &got); err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "a" {
+		t.Errorf("expected entity 'a', got %+v", got)
+	}
+}
+
+func TestWithEndpointPerClient(t *testing.T) {
+	newEndpoint := func(hits *[]string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*hits = append(*hits, r.URL.Path)
+			writeJSON(t, w, map[string]any{"found": []any{}, "missing": []any{}})
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	var regionalHits, proxyHits []string
+	regional := newEndpoint(&regionalHits)
+	proxy := newEndpoint(&proxyHits)
+
+	// The metadata server comes from newTestClient; only the API endpoint differs
+	unused := func(http.ResponseWriter, *http.Request) { t.Error("request sent to the default test endpoint") }
+	regionalClient := newTestClient(t, unused, datastore.WithEndpoint(regional.URL))
+	proxyClient := newTestClient(t, unused, datastore.WithEndpoint(proxy.URL+"/"))
+
+	ctx := context.Background()
+	key := datastore.NameKey("TestKind", "k", nil)
+	var dst testEntity
+	if err := regionalClient.Get(ctx, key, &dst); !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Fatalf("expected ErrNoSuchEntity from the regional client, got %v", err)
+	}
+	if err := proxyClient.Get(ctx, key, &dst); !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Fatalf("expected ErrNoSuchEntity from the proxy client, got %v", err)
+	}
+
+	want := "/projects/test-project:lookup"
+	if len(regionalHits) != 1 || regionalHits[0] != want {
+		t.Errorf("regional endpoint got %v, want one %s", regionalHits, want)
+	}
+	// A trailing slash on the endpoint doesn't produce a double slash
+	if len(proxyHits) != 1 || proxyHits[0] != want {
+		t.Errorf("proxy endpoint got %v, want one %s", proxyHits, want)
+	}
+}
+
+func TestPing(t *testing.T) {
+	ctx := context.Background()
+
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("Ping against the mock failed: %v", err)
+	}
+
+	// The token source rejecting the credentials is an auth failure
+	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer metadataServer.Close()
+	client, err := datastore.NewClient(ctx, "test-project",
+		datastore.WithEndpoint("http://127.0.0.1:1"),
+		datastore.WithAuth(&auth.Config{MetadataURL: metadataServer.URL, SkipADC: true}))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if err := client.Ping(ctx); !errors.Is(err, datastore.ErrAuth) || errors.Is(err, datastore.ErrUnavailable) {
+		t.Errorf("expected ErrAuth for a rejected token request, got %v", err)
+	}
+
+	// So is Datastore rejecting the token
+	noRetry := datastore.WithReadRetry(datastore.RetryConfig{MaxAttempts: 1})
+	client = newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		writeJSON(t, w, map[string]any{"error": map[string]any{"code": 403, "status": "PERMISSION_DENIED"}})
+	}, noRetry)
+	if err := client.Ping(ctx); !errors.Is(err, datastore.ErrAuth) {
+		t.Errorf("expected ErrAuth for a rejected access token, got %v", err)
+	}
+
+	// Anything else is a connectivity failure
+	client = newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, noRetry)
+	if err := client.Ping(ctx); !errors.Is(err, datastore.ErrUnavailable) || errors.Is(err, datastore.ErrAuth) {
+		t.Errorf("expected ErrUnavailable for an unavailable server, got %v", err)
+	}
+}
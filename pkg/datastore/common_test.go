@@ -1,7 +1,17 @@
 package datastore_test
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
 	"time"
+
+	"github.com/codeGROOVE-dev/ds9/auth"
+	"github.com/codeGROOVE-dev/ds9/pkg/datastore"
 )
 
 // testEntity represents a simple test entity used across multiple test files.
@@ -21,3 +31,113 @@ type arrayEntity struct {
 	Floats  []float64 `datastore:"floats,omitempty"`
 	Bools   []bool    `datastore:"bools,omitempty"`
 }
+
+// newTestClient starts a fake metadata server and an API server backed by handler,
+// and returns a client wired to both. Servers are closed when the test finishes.
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...datastore.ClientOption) *datastore.Client {
+	t.Helper()
+
+	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/instance/service-accounts/default/token" {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(map[string]any{
+				"access_token": "test-token",
+				"expires_in":   3600,
+			}); err != nil {
+				t.Logf("encode failed: %v", err)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(metadataServer.Close)
+
+	apiServer := httptest.NewServer(handler)
+	t.Cleanup(apiServer.Close)
+
+	opts = append([]datastore.ClientOption{
+		datastore.WithEndpoint(apiServer.URL),
+		datastore.WithAuth(&auth.Config{
+			MetadataURL: metadataServer.URL,
+			SkipADC:     true,
+		}),
+	}, opts...)
+
+	client, err := datastore.NewClient(context.Background(), "test-project", opts...)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	return client
+}
+
+// writeJSON writes v as a JSON response body.
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Logf("encode failed: %v", err)
+	}
+}
+
+// pagedQuery is the part of a runQuery request read by pagingHandler.
+type pagedQuery struct {
+	Query struct {
+		Projection  []map[string]any `json:"projection"`
+		StartCursor string           `json:"startCursor"`
+	} `json:"query"`
+}
+
+// pagingHandler serves total runQuery results in batches of batchSize,
+// reporting NOT_FINISHED until the last batch. Each batch's end cursor is the
+// index of the next result. entity returns the entity for result i, and
+// observe, if not nil, sees each request before it is answered; calls to it
+// are serialized.
+func pagingHandler(t *testing.T, total, batchSize int, entity func(i int) map[string]any, observe func(pagedQuery)) http.HandlerFunc {
+	t.Helper()
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/test-project:runQuery" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req pagedQuery
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if observe != nil {
+			mu.Lock()
+			observe(req)
+			mu.Unlock()
+		}
+
+		start := 0
+		if req.Query.StartCursor != "" {
+			n, err := strconv.Atoi(req.Query.StartCursor)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			start = n
+		}
+		end := min(start+batchSize, total)
+
+		results := make([]any, 0, end-start)
+		for i := start; i < end; i++ {
+			results = append(results, map[string]any{"entity": entity(i)})
+		}
+		more := "NOT_FINISHED"
+		if end == total {
+			more = "NO_MORE_RESULTS"
+		}
+		writeJSON(t, w, map[string]any{"batch": map[string]any{
+			"entityResults": results,
+			"endCursor":     strconv.Itoa(end),
+			"moreResults":   more,
+		}})
+	}
+}
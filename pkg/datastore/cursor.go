@@ -1,6 +1,10 @@
 package datastore
 
-import "errors"
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
 
 // Cursor represents a query cursor for pagination.
 // API compatible with cloud.google.com/go/datastore.
@@ -11,11 +15,24 @@ func (c Cursor) String() string {
 	return string(c)
 }
 
-// DecodeCursor decodes a cursor string.
+// cursorEncodings are the base64 forms a cursor may take: the API returns
+// standard base64, and URL-safe base64 is common once cursors travel in URLs.
+var cursorEncodings = []*base64.Encoding{
+	base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding,
+}
+
+// DecodeCursor decodes a cursor string, such as one from Cursor.String.
+// It returns an error if s is empty or isn't a base64-encoded cursor, so a
+// corrupted cursor fails here rather than when the query runs.
 // API compatible with cloud.google.com/go/datastore.
 func DecodeCursor(s string) (Cursor, error) {
 	if s == "" {
 		return "", errors.New("empty cursor string")
 	}
-	return Cursor(s), nil
+	for _, enc := range cursorEncodings {
+		if _, err := enc.DecodeString(s); err == nil {
+			return Cursor(s), nil
+		}
+	}
+	return "", fmt.Errorf("invalid cursor %q: not base64", s)
 }
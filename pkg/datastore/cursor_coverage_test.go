@@ -136,3 +136,51 @@ func TestCursorWithLimitedResults(t *testing.T) {
 		t.Errorf("Expected 2 results with limit, got %d", count)
 	}
 }
+
+// TestCursorRoundTrip tests that a cursor survives String and DecodeCursor
+// and resumes the query where it left off
+func TestCursorRoundTrip(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for i := range 4 {
+		if _, err := client.Put(ctx, datastore.IDKey("CursorTrip", int64(i+1), nil), &testEntity{Count: int64(i)}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	it := client.Run(ctx, datastore.NewQuery("CursorTrip").Order("count").Limit(2))
+	for {
+		var e testEntity
+		if _, err := it.Next(&e); errors.Is(err, datastore.Done) {
+			break
+		} else if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+	}
+	cursor, err := it.Cursor()
+	if err != nil {
+		t.Fatalf("Cursor failed: %v", err)
+	}
+
+	decoded, err := datastore.DecodeCursor(cursor.String())
+	if err != nil {
+		t.Fatalf("DecodeCursor failed: %v", err)
+	}
+	if decoded != cursor {
+		t.Errorf("DecodeCursor(%q) = %q, want the same cursor", cursor.String(), decoded)
+	}
+
+	var rest []testEntity
+	if _, err := client.GetAll(ctx, datastore.NewQuery("CursorTrip").Order("count").Start(decoded), &rest); err != nil {
+		t.Fatalf("GetAll from cursor failed: %v", err)
+	}
+	if len(rest) != 2 || rest[0].Count != 2 || rest[1].Count != 3 {
+		t.Errorf("expected counts 2 and 3 after the cursor, got %+v", rest)
+	}
+
+	if _, err := datastore.DecodeCursor("not a cursor!"); err == nil {
+		t.Error("expected DecodeCursor to reject a malformed cursor")
+	}
+}
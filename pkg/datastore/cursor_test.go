@@ -50,6 +50,28 @@ func TestDecodeCursor(t *testing.T) {
 			cursorStr:   "",
 			expectError: true,
 		},
+		{
+			name:        "padded standard base64",
+			cursorStr:   "CgQIARAB+/8=",
+			expectError: false,
+			expected:    Cursor("CgQIARAB+/8="),
+		},
+		{
+			name:        "URL-safe base64",
+			cursorStr:   "CgQIARAB-_8",
+			expectError: false,
+			expected:    Cursor("CgQIARAB-_8"),
+		},
+		{
+			name:        "not base64",
+			cursorStr:   "page=2&size=10",
+			expectError: true,
+		},
+		{
+			name:        "truncated",
+			cursorStr:   "CgQIARAB+/8=x",
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,54 @@
+package datastore
+
+import (
+	"reflect"
+	"time"
+)
+
+// Date is a calendar date without a time of day or time zone, for fields such
+// as birthdays or due dates where those would only cause bugs.
+//
+// A Date is stored as a timestamp at midnight UTC, so dates sort and compare
+// correctly in queries, and is read back in UTC so the day never shifts with
+// the reader's time zone. The zero Date is stored as the zero time.Time, and is
+// treated as empty by omitempty.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+var dateType = reflect.TypeOf(Date{})
+
+// DateOf returns the date on which t falls in t's location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// Time returns midnight UTC at the start of d, the instant d is stored as.
+// The zero Date returns the zero time.Time.
+func (d Date) Time() time.Time {
+	if d.IsZero() {
+		return time.Time{}
+	}
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// dateFromStored returns the Date stored as t.
+func dateFromStored(t time.Time) Date {
+	if t.IsZero() {
+		return Date{}
+	}
+	return DateOf(t.UTC())
+}
+
+// String returns d in ISO 8601 form, e.g. "2024-03-10".
+func (d Date) String() string {
+	return d.Time().Format(time.DateOnly)
+}
+
+// IsZero reports whether d is the zero Date.
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
@@ -52,8 +52,8 @@ func TestEncodeValue_Errors(t *testing.T) {
 		value any
 	}{
 		{
-			"map type",
-			map[string]int{"key": 1},
+			"non-string map key",
+			map[int]string{1: "value"},
 		},
 		{
 			"function type",
@@ -63,7 +63,7 @@ func TestEncodeValue_Errors(t *testing.T) {
 			"channel type",
 			make(chan int),
 		},
-		// Note: struct types are now supported as nested entities
+		// Note: struct types and string-keyed maps are now supported as nested entities
 	}
 
 	for _, tt := range tests {
@@ -1,6 +1,11 @@
 package datastore
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
 
 // Entity encoding/decoding errors.
 var (
@@ -8,3 +13,161 @@ var (
 	errNotStructPtr  = errors.New("dst must be a pointer to struct")
 	errInvalidEntity = errors.New("invalid entity format")
 )
+
+// Property is a single named property of an entity.
+// Value is a string, int64, float64, bool, time.Time, []byte, *Key, GeoPoint,
+// []any, *Entity for a nested entity, or nil. A map[string]any Value is also written
+// as a nested entity, but nested entities are always read as *Entity.
+//
+// A []any Value is an array property. An array can also be written as several
+// Properties with the same name, one per element, which lets elements differ
+// in NoIndex; arrays whose elements differ in NoIndex are read that way too.
+type Property struct {
+	Name    string
+	Value   any
+	NoIndex bool
+}
+
+// PropertyList is an entity's properties without a Go struct to hold them.
+type PropertyList []Property
+
+// Entity is a nested entity used as a Property Value, so a PropertyList can
+// hold entities nested to any depth. Key is nil unless the nested entity was
+// stored with a key.
+type Entity struct {
+	Key        *Key
+	Properties []Property
+}
+
+// GeoPoint is a geographical point, in degrees, used as a Property Value.
+// Struct fields store geo points with the lat and lng tag options instead.
+type GeoPoint struct {
+	Lat, Lng float64
+}
+
+// propertiesToList converts an entity's JSON properties to a PropertyList,
+// sorted by name.
+func propertiesToList(properties map[string]any) (PropertyList, error) {
+	pl := make(PropertyList, 0, len(properties))
+	for name, p := range properties {
+		prop, ok := p.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("property %s: %w", name, errInvalidEntity)
+		}
+		v, err := decodeListValue(prop)
+		if err != nil {
+			return nil, fmt.Errorf("property %s: %w", name, err)
+		}
+		noIndex, _ := prop["excludeFromIndexes"].(bool) //nolint:errcheck // Absent means indexed
+		if arr, ok := prop["arrayValue"].(map[string]any); ok {
+			// Unindexed arrays carry the flag on their elements
+			values, _ := arr["values"].([]any) //nolint:errcheck // Missing values mean an empty array
+			flags := make([]bool, len(values))
+			for i, ev := range values {
+				if em, ok := ev.(map[string]any); ok {
+					flags[i], _ = em["excludeFromIndexes"].(bool) //nolint:errcheck // Absent means indexed
+				}
+			}
+			if len(flags) > 0 {
+				noIndex = flags[0]
+			}
+			if elems, ok := v.([]any); ok && slices.Contains(flags, !noIndex) {
+				// Elements differ in indexing, so each becomes its own Property
+				for i, ev := range elems {
+					pl = append(pl, Property{Name: name, Value: ev, NoIndex: flags[i]})
+				}
+				continue
+			}
+		}
+		pl = append(pl, Property{Name: name, Value: v, NoIndex: noIndex})
+	}
+	// Stable, so repeated properties keep their element order
+	slices.SortStableFunc(pl, func(a, b Property) int { return strings.Compare(a.Name, b.Name) })
+	return pl, nil
+}
+
+// decodeListValue decodes a property value for a PropertyList. It is decodeAny
+// except that nested entities, including array elements, become *Entity.
+func decodeListValue(prop map[string]any) (any, error) {
+	if val, ok := prop["entityValue"]; ok {
+		entityMap, ok := val.(map[string]any)
+		if !ok {
+			return nil, errors.New("invalid entityValue format")
+		}
+		properties, _ := entityMap["properties"].(map[string]any) //nolint:errcheck // Missing properties mean an empty entity
+		pl, err := propertiesToList(properties)
+		if err != nil {
+			return nil, err
+		}
+		e := &Entity{Properties: pl}
+		if keyData, ok := entityMap["key"]; ok {
+			if e.Key, err = keyFromJSON(keyData); err != nil {
+				return nil, err
+			}
+		}
+		return e, nil
+	}
+
+	v, err := decodeAny(prop)
+	if err != nil {
+		return nil, err
+	}
+	if arr, ok := prop["arrayValue"].(map[string]any); ok {
+		values, _ := arr["values"].([]any) //nolint:errcheck // Missing values mean an empty array
+		elems, _ := v.([]any)              //nolint:errcheck // Arrays always decode to []any
+		for i, ev := range values {
+			if em, ok := ev.(map[string]any); ok && em["entityValue"] != nil && i < len(elems) {
+				if elems[i], err = decodeListValue(em); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return v, nil
+}
+
+// listToProperties converts a PropertyList to an entity's JSON properties.
+// Properties sharing a name are combined into one array property.
+func listToProperties(pl PropertyList) (map[string]any, error) {
+	counts := make(map[string]int, len(pl))
+	for _, p := range pl {
+		counts[p.Name]++
+	}
+
+	properties := make(map[string]any, len(counts))
+	for _, p := range pl {
+		encoded, err := encodeAny(p.Value)
+		if err != nil {
+			return nil, fmt.Errorf("property %s: %w", p.Name, err)
+		}
+		prop, ok := encoded.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("property %s: unexpected encoded type", p.Name)
+		}
+		if p.NoIndex {
+			excludeFromIndexes(prop)
+		}
+		if counts[p.Name] == 1 {
+			properties[p.Name] = prop
+			continue
+		}
+
+		// Repeated: append to the array, flattening slice values since
+		// arrays can't contain arrays
+		arr, ok := properties[p.Name].(map[string]any)
+		if !ok {
+			arr = map[string]any{"arrayValue": map[string]any{"values": []map[string]any{}}}
+			properties[p.Name] = arr
+		}
+		av, _ := arr["arrayValue"].(map[string]any)  //nolint:errcheck // Created above
+		values, _ := av["values"].([]map[string]any) //nolint:errcheck // Created above
+		if elems, ok := prop["arrayValue"].(map[string]any); ok {
+			more, _ := elems["values"].([]map[string]any) //nolint:errcheck // Encoded arrays always hold this type
+			values = append(values, more...)
+		} else {
+			values = append(values, prop)
+		}
+		av["values"] = values
+	}
+	return properties, nil
+}
@@ -2,9 +2,11 @@ package datastore
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -42,7 +44,7 @@ func TestEncodeValue_AllTypes(t *testing.T) {
 }
 
 func TestEncodeValue_UnsupportedType(t *testing.T) {
-	_, err := encodeAny(map[string]string{"key": "value"})
+	_, err := encodeAny(map[int]string{1: "value"})
 	if err == nil {
 		t.Error("Expected error for unsupported type, got nil")
 	}
@@ -322,3 +324,282 @@ func TestNewClientWithDatabase_Coverage(t *testing.T) {
 		t.Error("Expected error for empty project ID, got nil")
 	}
 }
+
+func TestPropertyListArrayRoundTrip(t *testing.T) {
+	// roundTrip encodes pl, sends it through JSON as the wire would, and decodes it.
+	roundTrip := func(t *testing.T, pl PropertyList) (PropertyList, map[string]any) {
+		t.Helper()
+		props, err := listToProperties(pl)
+		if err != nil {
+			t.Fatalf("listToProperties failed: %v", err)
+		}
+		data, err := json.Marshal(props)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		var wire map[string]any
+		if err := unmarshalResponse(data, &wire); err != nil {
+			t.Fatalf("unmarshal failed: %v", err)
+		}
+		got, err := propertiesToList(wire)
+		if err != nil {
+			t.Fatalf("propertiesToList failed: %v", err)
+		}
+		return got, wire
+	}
+
+	t.Run("RepeatedWithMixedIndexing", func(t *testing.T) {
+		pl := PropertyList{
+			{Name: "count", Value: int64(3)},
+			{Name: "tags", Value: "red"},
+			{Name: "tags", Value: "long description", NoIndex: true},
+			{Name: "tags", Value: "blue"},
+		}
+		got, wire := roundTrip(t, pl)
+		if !reflect.DeepEqual(got, pl) {
+			t.Errorf("round trip:\n got %+v\nwant %+v", got, pl)
+		}
+		tags, _ := wire["tags"].(map[string]any)
+		if _, ok := tags["arrayValue"]; !ok {
+			t.Errorf("expected repeated tags to be stored as an array, got %v", wire["tags"])
+		}
+	})
+
+	t.Run("SliceValue", func(t *testing.T) {
+		pl := PropertyList{{Name: "mixed", Value: []any{"a", int64(1), true, 2.5}, NoIndex: true}}
+		got, _ := roundTrip(t, pl)
+		if !reflect.DeepEqual(got, pl) {
+			t.Errorf("round trip:\n got %+v\nwant %+v", got, pl)
+		}
+	})
+
+	t.Run("RepeatedWithUniformIndexing", func(t *testing.T) {
+		pl := PropertyList{{Name: "tags", Value: "a"}, {Name: "tags", Value: "b"}}
+		got, _ := roundTrip(t, pl)
+		want := PropertyList{{Name: "tags", Value: []any{"a", "b"}}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip:\n got %+v\nwant %+v", got, want)
+		}
+	})
+}
+
+func TestParseInteger(t *testing.T) {
+	for _, v := range []any{"-12", json.Number("-12"), float64(-12)} {
+		n, err := parseInteger(v)
+		if err != nil || n != -12 {
+			t.Errorf("parseInteger(%#v) = %d, %v; want -12", v, n, err)
+		}
+	}
+	for _, v := range []any{"12.5", json.Number("1e400"), 12.5, true, nil} {
+		if _, err := parseInteger(v); err == nil {
+			t.Errorf("parseInteger(%#v): expected error", v)
+		}
+	}
+}
+
+func TestEmbeddedStructPromotion(t *testing.T) {
+	type Timestamps struct {
+		CreatedAt time.Time `datastore:"created_at"`
+		UpdatedAt time.Time `datastore:"updated_at,noindex"`
+	}
+	type Audit struct {
+		By string `datastore:"by"`
+	}
+	type Doc struct {
+		Timestamps
+		*Audit
+		Title string `datastore:"title"`
+	}
+
+	created := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	src := &Doc{
+		Timestamps: Timestamps{CreatedAt: created, UpdatedAt: created.Add(time.Hour)},
+		Audit:      &Audit{By: "alice"},
+		Title:      "hello",
+	}
+	entity, err := encodeEntity(NameKey("Doc", "d1", nil), src)
+	if err != nil {
+		t.Fatalf("encodeEntity failed: %v", err)
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var wire map[string]any
+	if err := unmarshalResponse(data, &wire); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	props, _ := wire["properties"].(map[string]any)
+	for _, name := range []string{"created_at", "updated_at", "by", "title"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("expected top-level property %q, got %v", name, props)
+		}
+	}
+	if len(props) != 4 {
+		t.Errorf("got %d properties, want 4: %v", len(props), props)
+	}
+	if updated, _ := props["updated_at"].(map[string]any); updated["excludeFromIndexes"] != true {
+		t.Errorf("expected updated_at to keep its noindex tag, got %v", updated)
+	}
+
+	var got Doc
+	if err := decodeEntity(wire, &got); err != nil {
+		t.Fatalf("decodeEntity failed: %v", err)
+	}
+	if !reflect.DeepEqual(&got, src) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, src)
+	}
+
+	// A nil embedded pointer contributes no properties
+	entity, err = encodeEntity(NameKey("Doc", "d2", nil), &Doc{Title: "bare"})
+	if err != nil {
+		t.Fatalf("encodeEntity failed: %v", err)
+	}
+	if props, _ := entity["properties"].(map[string]any); props["by"] != nil {
+		t.Errorf("expected no property for nil embedded pointer, got %v", props["by"])
+	}
+}
+
+func TestFlattenNestedStruct(t *testing.T) {
+	type Address struct {
+		City string `datastore:"city"`
+		Zip  string `datastore:"zip"`
+	}
+	type Customer struct {
+		Name    string   `datastore:"name"`
+		Address Address  `datastore:"address,flatten"`
+		Billing *Address `datastore:"billing,flatten,noindex"`
+	}
+
+	src := &Customer{
+		Name:    "Ada",
+		Address: Address{City: "London", Zip: "NW1"},
+		Billing: &Address{City: "Leeds", Zip: "LS1"},
+	}
+	entity, err := encodeEntity(NameKey("Customer", "c1", nil), src)
+	if err != nil {
+		t.Fatalf("encodeEntity failed: %v", err)
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var wire map[string]any
+	if err := unmarshalResponse(data, &wire); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	props, _ := wire["properties"].(map[string]any)
+	pl, err := propertiesToList(props)
+	if err != nil {
+		t.Fatalf("propertiesToList failed: %v", err)
+	}
+	want := PropertyList{
+		{Name: "address.city", Value: "London"},
+		{Name: "address.zip", Value: "NW1"},
+		{Name: "billing.city", Value: "Leeds", NoIndex: true},
+		{Name: "billing.zip", Value: "LS1", NoIndex: true},
+		{Name: "name", Value: "Ada"},
+	}
+	if !reflect.DeepEqual(pl, want) {
+		t.Errorf("stored properties:\n got %+v\nwant %+v", pl, want)
+	}
+	if got, want := unindexedProperties(reflect.TypeOf(Customer{})), map[string]bool{"billing.city": true, "billing.zip": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unindexedProperties = %v, want %v", got, want)
+	}
+
+	var got Customer
+	if err := decodeEntity(wire, &got); err != nil {
+		t.Fatalf("decodeEntity failed: %v", err)
+	}
+	if !reflect.DeepEqual(&got, src) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, *src)
+	}
+}
+
+func TestPropertyListNestedEntity(t *testing.T) {
+	wire := map[string]any{
+		"key": keyToJSON(NameKey("Order", "o1", nil)),
+		"properties": map[string]any{
+			"customer": map[string]any{
+				"entityValue": map[string]any{
+					"key": keyToJSON(NameKey("Customer", "c1", nil)),
+					"properties": map[string]any{
+						"name": map[string]any{"stringValue": "Ada"},
+						"address": map[string]any{
+							"entityValue": map[string]any{
+								"properties": map[string]any{
+									"city": map[string]any{"stringValue": "London", "excludeFromIndexes": true},
+									"zip":  map[string]any{"stringValue": "NW1"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	data, err := json.Marshal(wire)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var entity map[string]any
+	if err := unmarshalResponse(data, &entity); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	var pl PropertyList
+	if err := decodeEntity(entity, &pl); err != nil {
+		t.Fatalf("decodeEntity failed: %v", err)
+	}
+	if len(pl) != 1 || pl[0].Name != "customer" {
+		t.Fatalf("properties = %+v, want only customer", pl)
+	}
+	customer, ok := pl[0].Value.(*Entity)
+	if !ok {
+		t.Fatalf("customer value is %T, want *Entity", pl[0].Value)
+	}
+	if !customer.Key.Equal(NameKey("Customer", "c1", nil)) {
+		t.Errorf("customer key = %v, want Customer c1", customer.Key)
+	}
+	if len(customer.Properties) != 2 || customer.Properties[0].Name != "address" || customer.Properties[1].Name != "name" {
+		t.Fatalf("customer properties = %+v, want address and name", customer.Properties)
+	}
+	if got := customer.Properties[1].Value; got != "Ada" {
+		t.Errorf("customer name = %v, want Ada", got)
+	}
+	address, ok := customer.Properties[0].Value.(*Entity)
+	if !ok {
+		t.Fatalf("address value is %T, want *Entity", customer.Properties[0].Value)
+	}
+	if address.Key != nil {
+		t.Errorf("address key = %v, want nil", address.Key)
+	}
+	want := []Property{
+		{Name: "city", Value: "London", NoIndex: true},
+		{Name: "zip", Value: "NW1"},
+	}
+	if !reflect.DeepEqual(address.Properties, want) {
+		t.Errorf("address properties:\n got %+v\nwant %+v", address.Properties, want)
+	}
+
+	encoded, err := encodeEntity(NameKey("Order", "o1", nil), pl)
+	if err != nil {
+		t.Fatalf("encodeEntity failed: %v", err)
+	}
+	if data, err = json.Marshal(encoded); err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var reread map[string]any
+	if err := unmarshalResponse(data, &reread); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	var got PropertyList
+	if err := decodeEntity(reread, &got); err != nil {
+		t.Fatalf("decodeEntity failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, pl) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, pl)
+	}
+}
@@ -1,18 +1,34 @@
 package datastore
 
 import (
+	"encoding"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// decodeEntity converts a Datastore entity to a Go struct.
+// decodeEntity converts a Datastore entity to a Go struct, or to a
+// PropertyList if dst is a *PropertyList.
 // It also populates any field tagged with `datastore:"__key__"` with the entity's key.
 func decodeEntity(entity map[string]any, dst any) error {
+	if pl, ok := dst.(*PropertyList); ok {
+		// An entity without properties may omit them entirely
+		properties, _ := entity["properties"].(map[string]any) //nolint:errcheck // Missing means no properties
+		list, err := propertiesToList(properties)
+		if err != nil {
+			return err
+		}
+		*pl = list
+		return nil
+	}
+
 	v := reflect.ValueOf(dst)
 	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
 		return errNotStructPtr
@@ -52,7 +68,7 @@ func decodeStruct(properties map[string]any, v reflect.Value, key *Key, prefix s
 		}
 
 		opts := parseDecodeTag(field)
-		if opts.skip {
+		if opts.skip || opts.version {
 			continue
 		}
 
@@ -64,18 +80,26 @@ func decodeStruct(properties map[string]any, v reflect.Value, key *Key, prefix s
 			continue
 		}
 
-		// Handle embedded (anonymous) structs
-		if field.Anonymous && fieldVal.Kind() == reflect.Struct {
+		// Embedded structs receive the promoted properties
+		if isEmbeddedStruct(field) {
+			if fieldVal.Kind() == reflect.Ptr {
+				if fieldVal.IsNil() {
+					fieldVal.Set(reflect.New(fieldVal.Type().Elem()))
+				}
+				fieldVal = fieldVal.Elem()
+			}
 			if err := decodeStruct(properties, fieldVal, key, prefix); err != nil {
 				return fmt.Errorf("embedded %s: %w", field.Name, err)
 			}
 			continue
 		}
 
+		// Tag names are literal: `datastore:"a.b"` reads a property named "a.b".
+		// Only flatten maps dotted names onto nested structs.
 		propName := prefix + opts.name
 
 		// Handle flatten for struct fields
-		if opts.flatten && isDecodableStruct(fieldVal) {
+		if opts.flatten && isFlattenableStruct(fieldVal) {
 			sv := fieldVal
 			if sv.Kind() == reflect.Ptr {
 				// Allocate if nil
@@ -92,6 +116,12 @@ func decodeStruct(properties map[string]any, v reflect.Value, key *Key, prefix s
 
 		prop, ok := properties[propName]
 		if !ok {
+			// Only an absent property takes the default; a stored zero value is kept
+			if opts.hasDefault {
+				if err := setDefault(fieldVal, opts.def); err != nil {
+					return fmt.Errorf("field %s: %w", field.Name, err)
+				}
+			}
 			continue
 		}
 
@@ -100,6 +130,20 @@ func decodeStruct(properties map[string]any, v reflect.Value, key *Key, prefix s
 			continue
 		}
 
+		if opts.geo != "" {
+			if err := decodeGeoCoordinate(propMap, opts.geo, fieldVal); err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			continue
+		}
+
+		if opts.json {
+			if err := decodeJSONField(propMap, fieldVal); err != nil {
+				return fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			continue
+		}
+
 		if err := decodeValue(propMap, fieldVal); err != nil {
 			return fmt.Errorf("field %s: %w", field.Name, err)
 		}
@@ -108,16 +152,78 @@ func decodeStruct(properties map[string]any, v reflect.Value, key *Key, prefix s
 	return nil
 }
 
+// decodeGeoCoordinate decodes one coordinate of a geoPointValue property into
+// a float field. coord is "lat" or "lng".
+func decodeGeoCoordinate(prop map[string]any, coord string, dst reflect.Value) error {
+	gp, ok := prop["geoPointValue"].(map[string]any)
+	if !ok {
+		return errors.New("property is not a geo point")
+	}
+	val, ok := gp[geoPointFields[coord]]
+	if !ok {
+		val = float64(0) // JSON omits zero coordinates
+	}
+	return decodeDouble(val, dst)
+}
+
+// decodeGeoPoint decodes a geoPointValue into a GeoPoint.
+func decodeGeoPoint(val any, dst reflect.Value) error {
+	if dst.Type() != reflect.TypeOf(GeoPoint{}) {
+		return fmt.Errorf("cannot decode geo point into %s", dst.Type())
+	}
+	gp, ok := val.(map[string]any)
+	if !ok {
+		return errors.New("invalid geoPointValue format")
+	}
+	var point GeoPoint
+	// JSON omits zero coordinates
+	if lat, ok := gp["latitude"]; ok {
+		if err := decodeDouble(lat, reflect.ValueOf(&point.Lat).Elem()); err != nil {
+			return fmt.Errorf("latitude: %w", err)
+		}
+	}
+	if lng, ok := gp["longitude"]; ok {
+		if err := decodeDouble(lng, reflect.ValueOf(&point.Lng).Elem()); err != nil {
+			return fmt.Errorf("longitude: %w", err)
+		}
+	}
+	dst.Set(reflect.ValueOf(point))
+	return nil
+}
+
+// decodeJSONField decodes a field tagged json from the JSON text stored in
+// its string property.
+func decodeJSONField(prop map[string]any, dst reflect.Value) error {
+	dst.Set(reflect.Zero(dst.Type()))
+	if _, ok := prop["nullValue"]; ok {
+		return nil
+	}
+	s, ok := prop["stringValue"].(string)
+	if !ok {
+		return errors.New("json option requires a string property")
+	}
+	if err := json.Unmarshal([]byte(s), dst.Addr().Interface()); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return nil
+}
+
 // decodeTagOptions holds parsed decode tag options.
 type decodeTagOptions struct {
-	name    string
-	flatten bool
-	skip    bool
+	name       string
+	def        string // from the `default:"..."` tag, used when the property is absent
+	geo        string // "lat" or "lng" to read one coordinate of a geo point property
+	hasDefault bool
+	flatten    bool
+	json       bool // stored as a JSON string
+	version    bool
+	skip       bool
 }
 
 // parseDecodeTag extracts field name and options from datastore tag for decoding.
 func parseDecodeTag(field reflect.StructField) decodeTagOptions {
 	opts := decodeTagOptions{name: field.Name}
+	opts.def, opts.hasDefault = field.Tag.Lookup("default")
 
 	tag := field.Tag.Get("datastore")
 	if tag == "" {
@@ -134,22 +240,122 @@ func parseDecodeTag(field reflect.StructField) decodeTagOptions {
 	}
 
 	for _, opt := range parts[1:] {
-		if opt == "flatten" {
+		switch opt {
+		case "flatten":
 			opts.flatten = true
+		case "version":
+			opts.version = true
+		case "json":
+			opts.json = true
+		case "lat", "lng":
+			opts.geo = opt
+		default:
+			// Ignore options that only affect encoding
 		}
 	}
 
 	return opts
 }
 
-// isDecodableStruct reports whether v is a struct or pointer to struct (excluding time.Time).
-func isDecodableStruct(v reflect.Value) bool {
+// setDefault sets dst from the text of a `default:"..."` struct tag.
+// Times are given in RFC 3339 format.
+func setDefault(dst reflect.Value, def string) error {
+	if dst.Kind() == reflect.Ptr {
+		v := reflect.New(dst.Type().Elem())
+		if err := setDefault(v.Elem(), def); err != nil {
+			return err
+		}
+		dst.Set(v)
+		return nil
+	}
+
+	if dst.Type() == reflect.TypeOf(time.Time{}) {
+		t, err := time.Parse(time.RFC3339Nano, def)
+		if err != nil {
+			return fmt.Errorf("invalid default %q: %w", def, err)
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(def)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return fmt.Errorf("invalid default %q: %w", def, err)
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(def, 10, dst.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid default %q: %w", def, err)
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(def, 10, dst.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid default %q: %w", def, err)
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(def, dst.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid default %q: %w", def, err)
+		}
+		dst.SetFloat(f)
+	default:
+		return fmt.Errorf("default values are not supported for type %s", dst.Type())
+	}
+	return nil
+}
+
+// setVersion populates any int64 field tagged `datastore:",version"` with the
+// entity's commit version, as reported by lookups and queries in microseconds.
+func setVersion(dst any, version int64) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+	setVersionField(v.Elem(), version)
+}
+
+func setVersionField(v reflect.Value, version int64) {
+	t := v.Type()
+	for i := range v.NumField() {
+		field := t.Field(i)
+		fieldVal := v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if isEmbeddedStruct(field) {
+			if fieldVal.Kind() == reflect.Ptr {
+				if fieldVal.IsNil() {
+					continue
+				}
+				fieldVal = fieldVal.Elem()
+			}
+			setVersionField(fieldVal, version)
+			continue
+		}
+
+		if parseDecodeTag(field).version && fieldVal.Kind() == reflect.Int64 {
+			fieldVal.SetInt(version)
+		}
+	}
+}
+
+// isFlattenableStruct reports whether v is a struct or pointer to struct that
+// flatten can spread across properties; time.Time and Date are single values.
+func isFlattenableStruct(v reflect.Value) bool {
 	if v.Kind() == reflect.Struct {
-		return v.Type() != reflect.TypeOf(time.Time{})
+		return v.Type() != reflect.TypeOf(time.Time{}) && v.Type() != dateType
 	}
 	if v.Kind() == reflect.Ptr {
 		elem := v.Type().Elem()
-		return elem.Kind() == reflect.Struct && elem != reflect.TypeOf(time.Time{})
+		return elem.Kind() == reflect.Struct && elem != reflect.TypeOf(time.Time{}) && elem != dateType
 	}
 	return false
 }
@@ -170,6 +376,20 @@ func decodeValue(prop map[string]any, dst reflect.Value) error {
 		return decodeValue(prop, dst.Elem())
 	}
 
+	// Interface destinations (e.g. []any elements) take each value's natural Go type
+	if dst.Kind() == reflect.Interface && dst.NumMethod() == 0 {
+		v, err := decodeAny(prop)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			dst.Set(reflect.Zero(dst.Type()))
+		} else {
+			dst.Set(reflect.ValueOf(v))
+		}
+		return nil
+	}
+
 	// Handle null for non-pointers
 	if _, ok := prop["nullValue"]; ok {
 		dst.Set(reflect.Zero(dst.Type()))
@@ -221,14 +441,93 @@ func decodeValue(prop map[string]any, dst reflect.Value) error {
 		return decodeKeyValue(val, dst)
 	}
 
+	// Geo point
+	if val, ok := prop["geoPointValue"]; ok {
+		return decodeGeoPoint(val, dst)
+	}
+
 	return fmt.Errorf("unsupported property type for %s", dst.Type())
 }
 
+// anyValueTypes maps Datastore value fields to the Go type they decode to
+// when the destination is an empty interface.
+var anyValueTypes = []struct {
+	typ   reflect.Type
+	field string
+}{
+	{field: "stringValue", typ: reflect.TypeOf("")},
+	{field: "integerValue", typ: reflect.TypeOf(int64(0))},
+	{field: "doubleValue", typ: reflect.TypeOf(float64(0))},
+	{field: "booleanValue", typ: reflect.TypeOf(false)},
+	{field: "timestampValue", typ: reflect.TypeOf(time.Time{})},
+	{field: "blobValue", typ: reflect.TypeOf([]byte(nil))},
+	{field: "keyValue", typ: reflect.TypeOf((*Key)(nil))},
+	{field: "geoPointValue", typ: reflect.TypeOf(GeoPoint{})},
+	{field: "arrayValue", typ: reflect.TypeOf([]any(nil))},
+}
+
+// decodeAny decodes a Datastore property value into its natural Go type:
+// string, int64, float64, bool, time.Time, []byte, *Key, GeoPoint, []any, or
+// map[string]any for nested entities. Null values decode to nil.
+func decodeAny(prop map[string]any) (any, error) {
+	if _, ok := prop["nullValue"]; ok {
+		return nil, nil //nolint:nilnil // A null property is a valid nil value
+	}
+
+	if val, ok := prop["entityValue"]; ok {
+		entityMap, ok := val.(map[string]any)
+		if !ok {
+			return nil, errors.New("invalid entityValue format")
+		}
+		properties, _ := entityMap["properties"].(map[string]any) //nolint:errcheck // Missing properties mean an empty entity
+		m := make(map[string]any, len(properties))
+		for name, p := range properties {
+			pm, ok := p.(map[string]any)
+			if !ok {
+				continue
+			}
+			v, err := decodeAny(pm)
+			if err != nil {
+				return nil, fmt.Errorf("property %s: %w", name, err)
+			}
+			m[name] = v
+		}
+		return m, nil
+	}
+
+	for _, vt := range anyValueTypes {
+		if _, ok := prop[vt.field]; ok {
+			v := reflect.New(vt.typ).Elem()
+			if err := decodeValue(prop, v); err != nil {
+				return nil, err
+			}
+			return v.Interface(), nil
+		}
+	}
+
+	return nil, errors.New("unsupported property type for interface value")
+}
+
 func decodeString(val any, dst reflect.Value) error {
 	s, ok := val.(string)
 	if !ok {
 		return errors.New("invalid string value")
 	}
+	if dst.Type() == bigIntType {
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return fmt.Errorf("invalid big.Int value: %q", s)
+		}
+		dst.Set(reflect.ValueOf(*n))
+		return nil
+	}
+	if dst.CanAddr() && dst.Addr().Type().Implements(textUnmarshalerType) {
+		tu, _ := dst.Addr().Interface().(encoding.TextUnmarshaler) //nolint:errcheck // Checked by Implements
+		if err := tu.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("unmarshaling %s: %w", dst.Type(), err)
+		}
+		return nil
+	}
 	if dst.Kind() != reflect.String {
 		return fmt.Errorf("cannot decode string into %s", dst.Type())
 	}
@@ -236,20 +535,38 @@ func decodeString(val any, dst reflect.Value) error {
 	return nil
 }
 
-func decodeInteger(val any, dst reflect.Value) error {
-	var intVal int64
-
+// parseInteger parses a 64-bit integer from JSON, such as an integerValue, a
+// key ID, or a count. The API sends these as strings, but some proxies and the
+// emulator send JSON numbers, which arrive as json.Number or float64 depending
+// on how the response was unmarshaled.
+func parseInteger(val any) (int64, error) {
 	switch v := val.(type) {
 	case string:
-		var err error
-		intVal, err = strconv.ParseInt(v, 10, 64)
+		n, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
-			return fmt.Errorf("invalid integer: %w", err)
+			return 0, fmt.Errorf("invalid integer: %w", err)
 		}
+		return n, nil
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer: %w", err)
+		}
+		return n, nil
 	case float64:
-		intVal = int64(v)
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("invalid integer: %v", v)
+		}
+		return int64(v), nil
 	default:
-		return fmt.Errorf("unexpected integer format: %T", val)
+		return 0, fmt.Errorf("unexpected integer format: %T", val)
+	}
+}
+
+func decodeInteger(val any, dst reflect.Value) error {
+	intVal, err := parseInteger(val)
+	if err != nil {
+		return err
 	}
 
 	switch dst.Kind() {
@@ -279,8 +596,16 @@ func decodeBool(val any, dst reflect.Value) error {
 }
 
 func decodeDouble(val any, dst reflect.Value) error {
-	f, ok := val.(float64)
-	if !ok {
+	var f float64
+	switch v := val.(type) {
+	case float64:
+		f = v
+	case json.Number:
+		var err error
+		if f, err = v.Float64(); err != nil {
+			return fmt.Errorf("invalid double value: %w", err)
+		}
+	default:
 		return errors.New("invalid double value")
 	}
 	switch dst.Kind() {
@@ -297,13 +622,17 @@ func decodeTimestamp(val any, dst reflect.Value) error {
 	if !ok {
 		return errors.New("invalid timestamp value")
 	}
-	if dst.Type() != reflect.TypeOf(time.Time{}) {
+	if dst.Type() != reflect.TypeOf(time.Time{}) && dst.Type() != dateType {
 		return fmt.Errorf("cannot decode timestamp into %s", dst.Type())
 	}
 	t, err := time.Parse(time.RFC3339Nano, s)
 	if err != nil {
 		return fmt.Errorf("invalid timestamp format: %w", err)
 	}
+	if dst.Type() == dateType {
+		dst.Set(reflect.ValueOf(dateFromStored(t)))
+		return nil
+	}
 	dst.Set(reflect.ValueOf(t))
 	return nil
 }
@@ -313,7 +642,8 @@ func decodeBlob(val any, dst reflect.Value) error {
 	if !ok {
 		return errors.New("invalid blob value")
 	}
-	if dst.Type() != reflect.TypeOf([]byte(nil)) {
+	// Named byte slices, such as net.IP, are encoded as blobs too
+	if dst.Kind() != reflect.Slice || dst.Type().Elem().Kind() != reflect.Uint8 {
 		return fmt.Errorf("cannot decode blob into %s", dst.Type())
 	}
 	data, err := base64.StdEncoding.DecodeString(s)
@@ -372,6 +702,10 @@ func decodeEntityValue(val any, dst reflect.Value) error {
 		return errors.New("invalid entityValue.properties format")
 	}
 
+	if dst.Kind() == reflect.Map {
+		return decodeMap(properties, dst)
+	}
+
 	if dst.Kind() != reflect.Struct {
 		return fmt.Errorf("cannot decode entity into %s", dst.Type())
 	}
@@ -379,8 +713,34 @@ func decodeEntityValue(val any, dst reflect.Value) error {
 	return decodeStruct(properties, dst, nil, "")
 }
 
+// decodeMap decodes the properties of an entity value into a map with string keys.
+func decodeMap(properties map[string]any, dst reflect.Value) error {
+	if dst.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("cannot decode entity into %s (map keys must be strings)", dst.Type())
+	}
+
+	m := reflect.MakeMapWithSize(dst.Type(), len(properties))
+	elemType := dst.Type().Elem()
+	for name, p := range properties {
+		pm, ok := p.(map[string]any)
+		if !ok {
+			return fmt.Errorf("invalid property %s", name)
+		}
+		elem := reflect.New(elemType).Elem()
+		if err := decodeValue(pm, elem); err != nil {
+			return fmt.Errorf("map key %q: %w", name, err)
+		}
+		m.SetMapIndex(reflect.ValueOf(name).Convert(dst.Type().Key()), elem)
+	}
+
+	dst.Set(m)
+	return nil
+}
+
+// decodeKeyValue decodes a keyValue into a Key. decodeValue has already
+// allocated and dereferenced a *Key destination.
 func decodeKeyValue(val any, dst reflect.Value) error {
-	if dst.Type() != reflect.TypeOf((*Key)(nil)) {
+	if dst.Type() != reflect.TypeOf(Key{}) {
 		return fmt.Errorf("cannot decode key into %s", dst.Type())
 	}
 
@@ -389,6 +749,6 @@ func decodeKeyValue(val any, dst reflect.Value) error {
 		return fmt.Errorf("invalid key: %w", err)
 	}
 
-	dst.Set(reflect.ValueOf(key))
+	dst.Set(reflect.ValueOf(*key))
 	return nil
 }
@@ -1,25 +1,54 @@
 package datastore
 
 import (
+	"context"
+	"encoding"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var (
+	bigIntType    = reflect.TypeOf(big.Int{})
+	bigIntPtrType = reflect.TypeOf((*big.Int)(nil))
+
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// geoPointFields maps the lat and lng tag options to geoPointValue fields.
+// Fields tagged `datastore:"loc,lat"` and `datastore:"loc,lng"` share the
+// single geo point property "loc".
+var geoPointFields = map[string]string{"lat": "latitude", "lng": "longitude"}
+
 // tagOptions holds parsed struct field tag options.
 type tagOptions struct {
 	name      string
+	geo       string // "lat" or "lng" to store one coordinate of a geo point property
 	noIndex   bool
 	omitempty bool
 	flatten   bool
+	json      bool // stored as an unindexed JSON string
+	text      bool // stored as the string from encoding.TextMarshaler
 	skip      bool
 }
 
-// encodeEntity converts a Go struct to a Datastore entity.
+// encodeEntity converts a Go struct, or a PropertyList, to a Datastore entity.
 func encodeEntity(key *Key, src any) (map[string]any, error) {
+	switch pl := src.(type) {
+	case PropertyList:
+		return encodePropertyList(key, pl)
+	case *PropertyList:
+		if pl != nil {
+			return encodePropertyList(key, *pl)
+		}
+	}
+
 	v := reflect.ValueOf(src)
 	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
@@ -40,6 +69,70 @@ func encodeEntity(key *Key, src any) (map[string]any, error) {
 	}, nil
 }
 
+// encodePropertyList converts a PropertyList to a Datastore entity.
+func encodePropertyList(key *Key, pl PropertyList) (map[string]any, error) {
+	properties, err := listToProperties(pl)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"key":        keyToJSON(key),
+		"properties": properties,
+	}, nil
+}
+
+// encodeForWrite encodes src as the entity to write at key, mapping key for
+// Datastore. An entity with no properties is usually a mistake, such as a
+// struct with every field skipped, so it is logged or, in strict mode, rejected.
+// An entity with more property values than the client allows is rejected.
+func (c *Client) encodeForWrite(ctx context.Context, key *Key, src any) (map[string]any, error) {
+	entity, err := encodeEntity(c.outKey(key), src)
+	if err != nil {
+		return nil, err
+	}
+	props, _ := entity["properties"].(map[string]any) //nolint:errcheck // Always set by encodeEntity
+	if len(props) == 0 {
+		if c.strictEmpty {
+			return nil, fmt.Errorf("%w: %s", ErrEmptyEntity, key)
+		}
+		c.logger.WarnContext(ctx, "writing entity with no properties", "key", key.String(), "type", reflect.TypeOf(src).String())
+	}
+	if c.maxProperties > 0 {
+		if n := countPropertyValues(props); n > c.maxProperties {
+			return nil, fmt.Errorf("%w: %s has %d property values, limit is %d", ErrTooManyProperties, key, n, c.maxProperties)
+		}
+	}
+	return entity, nil
+}
+
+// countPropertyValues returns the number of values in encoded properties,
+// counting each array element and each property of a nested entity.
+func countPropertyValues(properties map[string]any) int {
+	n := 0
+	for _, p := range properties {
+		prop, _ := p.(map[string]any) //nolint:errcheck // Encoded properties are always maps
+		if arr, ok := prop["arrayValue"].(map[string]any); ok {
+			values, _ := arr["values"].([]map[string]any) //nolint:errcheck // Encoded arrays always hold this type
+			for _, v := range values {
+				n += countValue(v)
+			}
+			continue
+		}
+		n += countValue(prop)
+	}
+	return n
+}
+
+// countValue returns the number of values in one encoded value: one, or the
+// values of a nested entity's properties.
+func countValue(v map[string]any) int {
+	if ev, ok := v["entityValue"].(map[string]any); ok {
+		nested, _ := ev["properties"].(map[string]any) //nolint:errcheck // Missing properties mean an empty entity
+		return countPropertyValues(nested)
+	}
+	return 1
+}
+
 // encodeStruct encodes a struct value to Datastore properties.
 // prefix is used for flattened nested structs (e.g., "Address.").
 func encodeStruct(v reflect.Value, prefix string) (map[string]any, error) {
@@ -59,8 +152,14 @@ func encodeStruct(v reflect.Value, prefix string) (map[string]any, error) {
 			continue
 		}
 
-		// Handle embedded (anonymous) structs
-		if field.Anonymous && fieldVal.Kind() == reflect.Struct {
+		// Embedded structs promote their fields into this entity
+		if isEmbeddedStruct(field) {
+			if fieldVal.Kind() == reflect.Ptr {
+				if fieldVal.IsNil() {
+					continue
+				}
+				fieldVal = fieldVal.Elem()
+			}
 			embedded, err := encodeStruct(fieldVal, prefix)
 			if err != nil {
 				return nil, fmt.Errorf("embedded %s: %w", field.Name, err)
@@ -71,6 +170,11 @@ func encodeStruct(v reflect.Value, prefix string) (map[string]any, error) {
 			continue
 		}
 
+		// Nil *big.Int fields are omitted rather than stored as null
+		if fieldVal.Type() == bigIntPtrType && fieldVal.IsNil() {
+			continue
+		}
+
 		// Check omitempty before encoding
 		if opts.omitempty && isEmpty(fieldVal) {
 			continue
@@ -78,8 +182,36 @@ func encodeStruct(v reflect.Value, prefix string) (map[string]any, error) {
 
 		propName := prefix + opts.name
 
+		if opts.geo != "" {
+			if err := encodeGeoCoordinate(properties, propName, opts, fieldVal); err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			continue
+		}
+
+		if opts.json {
+			data, err := json.Marshal(fieldVal.Interface())
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			properties[propName] = map[string]any{"stringValue": string(data), "excludeFromIndexes": true}
+			continue
+		}
+
+		if opts.text {
+			prop, err := encodeText(fieldVal)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			if opts.noIndex {
+				excludeFromIndexes(prop)
+			}
+			properties[propName] = prop
+			continue
+		}
+
 		// Handle flatten for struct fields
-		if opts.flatten && isStructOrStructPtr(fieldVal) {
+		if opts.flatten && isFlattenableStruct(fieldVal) {
 			sv := fieldVal
 			if sv.Kind() == reflect.Ptr {
 				if sv.IsNil() {
@@ -91,7 +223,11 @@ func encodeStruct(v reflect.Value, prefix string) (map[string]any, error) {
 			if err != nil {
 				return nil, fmt.Errorf("field %s: %w", field.Name, err)
 			}
+			// noindex on the struct applies to every property flattened from it
 			for k, v := range flattened {
+				if opts.noIndex {
+					excludeFromIndexes(v)
+				}
 				properties[k] = v
 			}
 			continue
@@ -103,9 +239,7 @@ func encodeStruct(v reflect.Value, prefix string) (map[string]any, error) {
 		}
 
 		if opts.noIndex {
-			if m, ok := prop.(map[string]any); ok {
-				m["excludeFromIndexes"] = true
-			}
+			excludeFromIndexes(prop)
 		}
 
 		properties[propName] = prop
@@ -114,6 +248,81 @@ func encodeStruct(v reflect.Value, prefix string) (map[string]any, error) {
 	return properties, nil
 }
 
+// encodeGeoCoordinate stores a float field as one coordinate of the geo point
+// property propName, creating the property for the first of its two fields.
+func encodeGeoCoordinate(properties map[string]any, propName string, opts tagOptions, v reflect.Value) error {
+	if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+		return fmt.Errorf("%s option requires a float field, got %s", opts.geo, v.Type())
+	}
+	prop, ok := properties[propName].(map[string]any)
+	if !ok {
+		prop = map[string]any{"geoPointValue": map[string]any{}}
+		properties[propName] = prop
+	}
+	gp, ok := prop["geoPointValue"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("property %q is both a geo point and another value", propName)
+	}
+	gp[geoPointFields[opts.geo]] = v.Float()
+	if opts.noIndex {
+		excludeFromIndexes(prop)
+	}
+	return nil
+}
+
+// excludeFromIndexes marks an encoded value as unindexed. Datastore rejects the
+// flag on an array value itself, so an array marks each of its elements instead.
+func excludeFromIndexes(prop any) {
+	m, ok := prop.(map[string]any)
+	if !ok {
+		return
+	}
+	if arr, ok := m["arrayValue"].(map[string]any); ok {
+		values, _ := arr["values"].([]map[string]any) //nolint:errcheck // Encoded arrays always hold this type
+		for _, v := range values {
+			v["excludeFromIndexes"] = true
+		}
+		return
+	}
+	m["excludeFromIndexes"] = true
+}
+
+// unindexedProperties returns the names of the properties that values of the
+// struct type t store unindexed.
+func unindexedProperties(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	collectUnindexed(t, "", names, false)
+	return names
+}
+
+// collectUnindexed adds the unindexed properties of t to names, or all of
+// them if all is set.
+func collectUnindexed(t reflect.Type, prefix string, names map[string]bool, all bool) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		opts := parseTag(field)
+		if opts.skip {
+			continue
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch {
+		case isEmbeddedStruct(field):
+			collectUnindexed(ft, prefix, names, all)
+		case opts.flatten && ft.Kind() == reflect.Struct && ft != reflect.TypeOf(time.Time{}) && ft != dateType:
+			collectUnindexed(ft, prefix+opts.name+".", names, all || opts.noIndex)
+		case all || opts.noIndex:
+			names[prefix+opts.name] = true
+		}
+	}
+}
+
 // parseTag extracts field name and options from datastore tag.
 func parseTag(field reflect.StructField) tagOptions {
 	opts := tagOptions{name: field.Name}
@@ -140,6 +349,17 @@ func parseTag(field reflect.StructField) tagOptions {
 			opts.omitempty = true
 		case "flatten":
 			opts.flatten = true
+		case "json":
+			// JSON text is opaque to queries, so it is never indexed
+			opts.json = true
+			opts.noIndex = true
+		case "text":
+			opts.text = true
+		case "lat", "lng":
+			opts.geo = opt
+		case "version":
+			// Populated from the entity's commit version on read; never stored
+			opts.skip = true
 		default:
 			// Ignore unknown options
 		}
@@ -166,8 +386,11 @@ func isEmpty(v reflect.Value) bool {
 	case reflect.Float32, reflect.Float64:
 		return v.Float() == 0
 	case reflect.Struct:
-		// Special case for time.Time
-		if t, ok := v.Interface().(time.Time); ok {
+		// Special case for time.Time and Date
+		switch t := v.Interface().(type) {
+		case time.Time:
+			return t.IsZero()
+		case Date:
 			return t.IsZero()
 		}
 		return false
@@ -176,15 +399,74 @@ func isEmpty(v reflect.Value) bool {
 	}
 }
 
-// isStructOrStructPtr reports whether v is a struct or pointer to struct.
-func isStructOrStructPtr(v reflect.Value) bool {
-	if v.Kind() == reflect.Struct {
-		return true
+// isEmbeddedStruct reports whether field is an anonymous struct or struct
+// pointer whose fields are promoted into the enclosing entity. An embedded
+// time.Time or Date is a single value stored under the type's name instead.
+func isEmbeddedStruct(field reflect.StructField) bool {
+	if !field.Anonymous {
+		return false
+	}
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{}) && t != dateType
+}
+
+// textMarshaler returns v as an encoding.TextMarshaler if v or, when v is
+// addressable, a pointer to v implements it.
+func textMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	if v.Type().Implements(textMarshalerType) {
+		tm, ok := v.Interface().(encoding.TextMarshaler)
+		return tm, ok
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+		tm, ok := v.Addr().Interface().(encoding.TextMarshaler)
+		return tm, ok
+	}
+	return nil, false
+}
+
+// encodeText encodes a field with the text option, such as an enum, as the
+// stringValue from its MarshalText. Pointers and the elements of slices and
+// arrays are encoded the same way. Without the option, types that implement
+// encoding.TextMarshaler keep their native encoding, so a slog.Level stays an
+// integer and a net.IP a blob.
+func encodeText(v reflect.Value) (any, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return map[string]any{"nullValue": nil}, nil
+		}
+		return encodeText(v.Elem())
+	}
+	if _, ok := textMarshaler(v); !ok && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) {
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return map[string]any{"nullValue": nil}, nil
+		}
+		values := make([]map[string]any, v.Len())
+		for i := range values {
+			encoded, err := encodeText(v.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			m, ok := encoded.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("unexpected encoded type for element %d", i)
+			}
+			values[i] = m
+		}
+		return map[string]any{"arrayValue": map[string]any{"values": values}}, nil
+	}
+
+	tm, ok := textMarshaler(v)
+	if !ok {
+		return nil, fmt.Errorf("text option requires an encoding.TextMarshaler, got %s", v.Type())
 	}
-	if v.Kind() == reflect.Ptr && v.Type().Elem().Kind() == reflect.Struct {
-		return true
+	text, err := tm.MarshalText()
+	if err != nil {
+		return nil, fmt.Errorf("marshaling %s: %w", v.Type(), err)
 	}
-	return false
+	return map[string]any{"stringValue": string(text)}, nil
 }
 
 // encodeAny converts any Go value to a Datastore property value.
@@ -197,16 +479,28 @@ func encodeAny(v any) (any, error) {
 
 // encodeValue converts a Go reflect.Value to a Datastore property value.
 func encodeValue(v reflect.Value) (any, error) {
-	// Handle pointers - dereference or return null
-	if v.Kind() == reflect.Ptr {
+	// Handle interface{} - get underlying value
+	if v.Kind() == reflect.Interface {
 		if v.IsNil() {
 			return map[string]any{"nullValue": nil}, nil
 		}
 		v = v.Elem()
 	}
 
-	// Handle interface{} - get underlying value
-	if v.Kind() == reflect.Interface {
+	// Keys are references, not nested structs, so match them before the
+	// pointer is dereferenced
+	switch k := v.Interface().(type) {
+	case *Key:
+		if k == nil {
+			return map[string]any{"nullValue": nil}, nil
+		}
+		return map[string]any{"keyValue": keyToJSON(k)}, nil
+	case Key:
+		return map[string]any{"keyValue": keyToJSON(&k)}, nil
+	}
+
+	// Handle pointers - dereference or return null
+	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
 			return map[string]any{"nullValue": nil}, nil
 		}
@@ -217,11 +511,23 @@ func encodeValue(v reflect.Value) (any, error) {
 	switch val := v.Interface().(type) {
 	case time.Time:
 		return map[string]any{"timestampValue": val.Format(time.RFC3339Nano)}, nil
-	case *Key:
-		if val == nil {
-			return map[string]any{"nullValue": nil}, nil
+	case Date:
+		return map[string]any{"timestampValue": val.Time().Format(time.RFC3339Nano)}, nil
+	case big.Int:
+		// Arbitrary-precision integers are stored as decimal strings
+		return map[string]any{"stringValue": val.String()}, nil
+	case GeoPoint:
+		return map[string]any{"geoPointValue": map[string]any{"latitude": val.Lat, "longitude": val.Lng}}, nil
+	case Entity:
+		properties, err := listToProperties(val.Properties)
+		if err != nil {
+			return nil, err
 		}
-		return map[string]any{"keyValue": keyToJSON(val)}, nil
+		ev := map[string]any{"properties": properties}
+		if val.Key != nil {
+			ev["key"] = keyToJSON(val.Key)
+		}
+		return map[string]any{"entityValue": ev}, nil
 	}
 
 	// Handle by kind
@@ -247,6 +553,9 @@ func encodeValue(v reflect.Value) (any, error) {
 	case reflect.Struct:
 		return encodeNestedStruct(v)
 
+	case reflect.Map:
+		return encodeMap(v)
+
 	default:
 		return nil, fmt.Errorf("unsupported type: %s", v.Type())
 	}
@@ -260,6 +569,11 @@ func encodeSlice(v reflect.Value) (any, error) {
 		return map[string]any{"blobValue": base64.StdEncoding.EncodeToString(data)}, nil
 	}
 
+	// A nil slice is stored as null so it reads back as nil, distinct from an empty slice
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return map[string]any{"nullValue": nil}, nil
+	}
+
 	length := v.Len()
 	values := make([]map[string]any, length)
 
@@ -279,6 +593,34 @@ func encodeSlice(v reflect.Value) (any, error) {
 	return map[string]any{"arrayValue": map[string]any{"values": values}}, nil
 }
 
+// encodeMap encodes a map with string keys as an entity value whose
+// property names are the map keys.
+func encodeMap(v reflect.Value) (any, error) {
+	if v.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("unsupported type: %s (map keys must be strings)", v.Type())
+	}
+	if v.IsNil() {
+		return map[string]any{"nullValue": nil}, nil
+	}
+
+	properties := make(map[string]any, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		name := iter.Key().String()
+		prop, err := encodeValue(iter.Value())
+		if err != nil {
+			return nil, fmt.Errorf("map key %q: %w", name, err)
+		}
+		properties[name] = prop
+	}
+
+	return map[string]any{
+		"entityValue": map[string]any{
+			"properties": properties,
+		},
+	}, nil
+}
+
 // encodeNestedStruct encodes a nested struct as an entity value.
 func encodeNestedStruct(v reflect.Value) (any, error) {
 	properties, err := encodeStruct(v, "")
@@ -1,10 +1,17 @@
 package datastore_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -94,13 +101,13 @@ func TestUnsupportedEncodeType(t *testing.T) {
 	// Entity with unsupported type (map)
 	type BadEntity struct {
 		Name string
-		Data map[string]string // maps not supported
+		Data map[int]string // maps need string keys
 	}
 
 	key := datastore.NameKey("TestKind", "bad", nil)
 	entity := BadEntity{
 		Name: "test",
-		Data: map[string]string{"key": "value"},
+		Data: map[int]string{1: "value"},
 	}
 
 	_, err := client.Put(ctx, key, &entity)
@@ -588,6 +595,31 @@ func TestDecodeValueInvalidInteger(t *testing.T) {
 	}
 }
 
+func TestDecodeValueIntegerAsNumber(t *testing.T) {
+	// 2^53 + 1 can't be represented exactly as a float64
+	const want = int64(9007199254740993)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, ":lookup") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(`{"found":[{"entity":{
+			"key":{"path":[{"kind":"Test","id":42}]},
+			"properties":{"count":{"integerValue":9007199254740993}}}}]}`)); err != nil {
+			t.Logf("write failed: %v", err)
+		}
+	})
+
+	var entity testEntity
+	if err := client.Get(context.Background(), datastore.IDKey("Test", 42, nil), &entity); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if entity.Count != want {
+		t.Errorf("expected count %d, got %d", want, entity.Count)
+	}
+}
+
 func TestDecodeValueWrongTypeForInteger(t *testing.T) {
 	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Metadata-Flavor") != "Google" {
@@ -840,3 +872,605 @@ func TestGetMultiDecodeError(t *testing.T) {
 		t.Error("expected error when one entity has decode error")
 	}
 }
+
+func TestEntityWithBigInt(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type Counter struct {
+		Total  *big.Int
+		Unset  *big.Int
+		Direct big.Int
+	}
+
+	total, ok := new(big.Int).SetString("123456789012345678901234567890", 10)
+	if !ok {
+		t.Fatal("failed to parse big.Int")
+	}
+	var direct big.Int
+	direct.Exp(big.NewInt(2), big.NewInt(100), nil)
+	direct.Neg(&direct)
+
+	key := datastore.NameKey("Counter", "big", nil)
+	if _, err := client.Put(ctx, key, &Counter{Total: total, Direct: direct}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var got Counter
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Total == nil || got.Total.Cmp(total) != 0 {
+		t.Errorf("expected Total %s, got %v", total, got.Total)
+	}
+	if got.Unset != nil {
+		t.Errorf("expected nil Unset, got %s", got.Unset)
+	}
+	if got.Direct.Cmp(&direct) != 0 {
+		t.Errorf("expected Direct %s, got %s", &direct, &got.Direct)
+	}
+
+	// A malformed stored value is a decode error.
+	type BadCounter struct {
+		Total string
+	}
+	badKey := datastore.NameKey("Counter", "bad", nil)
+	if _, err := client.Put(ctx, badKey, &BadCounter{Total: "12abc"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := client.Get(ctx, badKey, &got); err == nil {
+		t.Error("expected error decoding malformed big.Int")
+	}
+}
+
+func TestEntityWithMixedArray(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type Generic struct {
+		Values []any
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	key := datastore.NameKey("Generic", "mixed", nil)
+	if _, err := client.Put(ctx, key, &Generic{Values: []any{"text", int64(42), 2.5, true, now}}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var got Generic
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(got.Values) != 5 {
+		t.Fatalf("expected 5 values, got %d: %v", len(got.Values), got.Values)
+	}
+
+	if s, ok := got.Values[0].(string); !ok || s != "text" {
+		t.Errorf("expected string %q, got %T %v", "text", got.Values[0], got.Values[0])
+	}
+	if n, ok := got.Values[1].(int64); !ok || n != 42 {
+		t.Errorf("expected int64 42, got %T %v", got.Values[1], got.Values[1])
+	}
+	if f, ok := got.Values[2].(float64); !ok || f != 2.5 {
+		t.Errorf("expected float64 2.5, got %T %v", got.Values[2], got.Values[2])
+	}
+	if b, ok := got.Values[3].(bool); !ok || !b {
+		t.Errorf("expected bool true, got %T %v", got.Values[3], got.Values[3])
+	}
+	if ts, ok := got.Values[4].(time.Time); !ok || !ts.Equal(now) {
+		t.Errorf("expected time.Time %v, got %T %v", now, got.Values[4], got.Values[4])
+	}
+}
+
+func TestEntityWithStructSlice(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type LineItem struct {
+		SKU      string  `datastore:"sku"`
+		Notes    string  `datastore:"notes,noindex"`
+		Quantity int64   `datastore:"qty"`
+		Price    float64 `datastore:"price"`
+	}
+	type Order struct {
+		Items    []LineItem `datastore:"items"`
+		Empty    []LineItem `datastore:"empty"`
+		Missing  []LineItem `datastore:"missing"`
+		Customer string     `datastore:"customer"`
+	}
+
+	order := &Order{
+		Customer: "acme",
+		Items: []LineItem{
+			{SKU: "A-1", Quantity: 2, Price: 9.99, Notes: "gift wrap"},
+			{SKU: "B-2", Quantity: 1, Price: 24.5},
+			{SKU: "C-3", Quantity: 10, Price: 0.25},
+		},
+		Empty: []LineItem{},
+	}
+
+	key := datastore.NameKey("Order", "order-1", nil)
+	if _, err := client.Put(ctx, key, order); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var got Order
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	if got.Customer != "acme" {
+		t.Errorf("expected customer %q, got %q", "acme", got.Customer)
+	}
+	if len(got.Items) != len(order.Items) {
+		t.Fatalf("expected %d items, got %d", len(order.Items), len(got.Items))
+	}
+	for i, item := range order.Items {
+		if got.Items[i] != item {
+			t.Errorf("item %d: expected %+v, got %+v", i, item, got.Items[i])
+		}
+	}
+	if got.Empty == nil || len(got.Empty) != 0 {
+		t.Errorf("expected empty non-nil slice, got %#v", got.Empty)
+	}
+	if got.Missing != nil {
+		t.Errorf("expected nil slice, got %#v", got.Missing)
+	}
+}
+
+func TestEntityWithMapFields(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type Product struct {
+		Attributes map[string]string `datastore:"attrs"`
+		Counts     map[string]int64  `datastore:"counts"`
+		Extra      map[string]any    `datastore:"extra"`
+	}
+
+	product := &Product{
+		Attributes: map[string]string{"color": "red", "size": "L", "material": "cotton"},
+		Counts:     map[string]int64{"views": 12, "sales": 3},
+		Extra:      map[string]any{"label": "new", "rank": int64(1)},
+	}
+
+	key := datastore.NameKey("Product", "shirt", nil)
+	if _, err := client.Put(ctx, key, product); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var got Product
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !maps.Equal(got.Attributes, product.Attributes) {
+		t.Errorf("expected attributes %v, got %v", product.Attributes, got.Attributes)
+	}
+	if !maps.Equal(got.Counts, product.Counts) {
+		t.Errorf("expected counts %v, got %v", product.Counts, got.Counts)
+	}
+	if !maps.Equal(got.Extra, product.Extra) {
+		t.Errorf("expected extra %v, got %v", product.Extra, got.Extra)
+	}
+
+	// Maps with non-string keys are rejected.
+	type BadProduct struct {
+		ByID map[int]string
+	}
+	_, err := client.Put(ctx, datastore.NameKey("Product", "bad", nil), &BadProduct{ByID: map[int]string{1: "one"}})
+	if err == nil || !strings.Contains(err.Error(), "map keys must be strings") {
+		t.Errorf("expected map key error, got %v", err)
+	}
+}
+
+func TestEntityWithDottedPropertyName(t *testing.T) {
+	type nested struct {
+		B string
+	}
+	type dotted struct {
+		Literal string `datastore:"a.b"`
+		A       nested // not flattened, so it must not claim "a.b"
+	}
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, ":lookup") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(t, w, map[string]any{
+			"found": []any{map[string]any{"entity": map[string]any{
+				"key": map[string]any{"path": []any{map[string]any{"kind": "Foreign", "name": "x"}}},
+				"properties": map[string]any{
+					"a.b": map[string]any{"stringValue": "literal"},
+				},
+			}}},
+		})
+	})
+
+	var got dotted
+	if err := client.Get(context.Background(), datastore.NameKey("Foreign", "x", nil), &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Literal != "literal" {
+		t.Errorf("expected Literal %q, got %q", "literal", got.Literal)
+	}
+	if got.A.B != "" {
+		t.Errorf("expected nested field untouched, got %q", got.A.B)
+	}
+}
+
+func TestDecodeDefaultForMissingProperty(t *testing.T) {
+	type account struct {
+		Name    string `datastore:"name"`
+		Status  string `datastore:"status" default:"active"`
+		Retries int64  `datastore:"retries" default:"3"`
+		Premium *bool  `datastore:"premium" default:"true"`
+	}
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, ":lookup") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(t, w, map[string]any{
+			"found": []any{map[string]any{"entity": map[string]any{
+				"key": map[string]any{"path": []any{map[string]any{"kind": "Account", "name": "a"}}},
+				"properties": map[string]any{
+					"name":    map[string]any{"stringValue": "a"},
+					"retries": map[string]any{"integerValue": "0"},
+				},
+			}}},
+		})
+	})
+
+	var got account
+	if err := client.Get(context.Background(), datastore.NameKey("Account", "a", nil), &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Status != "active" {
+		t.Errorf("expected missing status to default to %q, got %q", "active", got.Status)
+	}
+	if got.Retries != 0 {
+		t.Errorf("expected a stored zero to be kept over the default, got %d", got.Retries)
+	}
+	if got.Premium == nil || !*got.Premium {
+		t.Errorf("expected missing premium to default to true, got %v", got.Premium)
+	}
+}
+
+func TestGeoPointLatLngFields(t *testing.T) {
+	type place struct {
+		Name string  `datastore:"name"`
+		Lat  float64 `datastore:"loc,lat"`
+		Lng  float64 `datastore:"loc,lng"`
+	}
+
+	var stored map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, ":commit"):
+			mutations, _ := req["mutations"].([]any)             //nolint:errcheck // checked by the Get below
+			upsert, _ := mutations[0].(map[string]any)["upsert"] //nolint:errcheck // checked by the Get below
+			stored, _ = upsert.(map[string]any)                  //nolint:errcheck // checked by the Get below
+			writeJSON(t, w, map[string]any{"mutationResults": []any{map[string]any{}}})
+		case strings.HasSuffix(r.URL.Path, ":lookup"):
+			writeJSON(t, w, map[string]any{"found": []any{map[string]any{"entity": stored}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ctx := context.Background()
+	key := datastore.NameKey("Place", "office", nil)
+	want := place{Name: "office", Lat: 52.52, Lng: 13.405}
+	if _, err := client.Put(ctx, key, &want); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	props, _ := stored["properties"].(map[string]any) //nolint:errcheck // checked below
+	loc, ok := props["loc"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected a single loc property, got %v", props)
+	}
+	if gp, ok := loc["geoPointValue"].(map[string]any); !ok || gp["latitude"] != 52.52 || gp["longitude"] != 13.405 {
+		t.Errorf("expected loc to be a geo point, got %v", loc)
+	}
+
+	var got place
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("round trip: expected %+v, got %+v", want, got)
+	}
+
+	// Zero coordinates are omitted from geo points on the wire
+	stored["properties"] = map[string]any{"loc": map[string]any{"geoPointValue": map[string]any{"longitude": -0.1}}}
+	got = place{Lat: 1}
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Lat != 0 || got.Lng != -0.1 {
+		t.Errorf("expected (0, -0.1), got (%v, %v)", got.Lat, got.Lng)
+	}
+}
+
+func TestDateRoundTrip(t *testing.T) {
+	type task struct {
+		Name string         `datastore:"name"`
+		Due  datastore.Date `datastore:"due"`
+		Done datastore.Date `datastore:"done,omitempty"`
+	}
+
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// DST starts in the US on 2024-03-10 and ends on 2024-11-03. Late evening
+	// local time is already the next day in UTC.
+	edt := time.FixedZone("EDT", -4*3600)
+	dates := []datastore.Date{
+		datastore.DateOf(time.Date(2024, 3, 10, 23, 30, 0, 0, edt)),
+		datastore.DateOf(time.Date(2024, 11, 3, 23, 30, 0, 0, edt)),
+		{Year: 2024, Month: time.March, Day: 9},
+	}
+	if dates[0].String() != "2024-03-10" {
+		t.Fatalf("DateOf used the wrong day: %s", dates[0])
+	}
+
+	for i, d := range dates {
+		key := datastore.NameKey("Task", fmt.Sprintf("t%d", i), nil)
+		if _, err := client.Put(ctx, key, &task{Name: key.Name, Due: d}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		var got task
+		if err := client.Get(ctx, key, &got); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got.Due != d {
+			t.Errorf("expected %s to round trip, got %s", d, got.Due)
+		}
+		if !got.Done.IsZero() {
+			t.Errorf("expected zero Done date, got %s", got.Done)
+		}
+	}
+
+	// Dates are stored as timestamps, so they filter and sort as dates
+	var got []task
+	query := datastore.NewQuery("Task").FilterField("due", ">=", dates[0]).Order("due")
+	if _, err := client.GetAll(ctx, query, &got); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Due != dates[0] || got[1].Due != dates[1] {
+		t.Errorf("expected the March 10 and November 3 tasks in order, got %+v", got)
+	}
+}
+
+// color is an enum stored by name through encoding.TextMarshaler when a
+// field opts in with the text option.
+type color int
+
+const (
+	red color = iota
+	green
+	blue
+)
+
+var colorNames = []string{"red", "green", "blue"}
+
+func (c color) MarshalText() ([]byte, error) {
+	if c < 0 || int(c) >= len(colorNames) {
+		return nil, fmt.Errorf("invalid color %d", int(c))
+	}
+	return []byte(colorNames[c]), nil
+}
+
+func (c *color) UnmarshalText(text []byte) error {
+	for i, name := range colorNames {
+		if name == string(text) {
+			*c = color(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown color %q", text)
+}
+
+func TestTextMarshalerRoundTrip(t *testing.T) {
+	type paint struct {
+		Color   color   `datastore:"color,text"`
+		Accent  *color  `datastore:"accent,text"`
+		Palette []color `datastore:"palette,text"`
+		Shade   color   `datastore:"shade"`
+	}
+
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	accent := blue
+	key := datastore.NameKey("Paint", "p1", nil)
+	want := paint{Color: green, Accent: &accent, Palette: []color{red, blue}, Shade: blue}
+	if _, err := client.Put(ctx, key, &want); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Values are stored in their text form
+	var pl datastore.PropertyList
+	if err := client.Get(ctx, key, &pl); err != nil {
+		t.Fatalf("Get into PropertyList failed: %v", err)
+	}
+	stored := map[string]any{}
+	for _, p := range pl {
+		stored[p.Name] = p.Value
+	}
+	if stored["color"] != "green" || stored["accent"] != "blue" {
+		t.Errorf("expected colors stored by name, got %v", stored)
+	}
+	// Without the text option the field keeps its native encoding
+	if stored["shade"] != int64(blue) {
+		t.Errorf("expected untagged color stored as an integer, got %v (%T)", stored["shade"], stored["shade"])
+	}
+	if palette, ok := stored["palette"].([]any); !ok || len(palette) != 2 || palette[0] != "red" || palette[1] != "blue" {
+		t.Errorf("expected palette stored by name, got %v", stored["palette"])
+	}
+
+	var got paint
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Color != want.Color || got.Accent == nil || *got.Accent != accent || len(got.Palette) != 2 || got.Palette[0] != red || got.Palette[1] != blue || got.Shade != blue {
+		t.Errorf("expected %+v to round trip, got %+v", want, got)
+	}
+
+	// Errors from either side are reported
+	if _, err := client.Put(ctx, key, &paint{Color: color(7)}); err == nil || !strings.Contains(err.Error(), "invalid color 7") {
+		t.Errorf("expected MarshalText error, got %v", err)
+	}
+	if _, err := client.Put(ctx, key, &datastore.PropertyList{{Name: "color", Value: "mauve"}}); err != nil {
+		t.Fatalf("Put PropertyList failed: %v", err)
+	}
+	if err := client.Get(ctx, key, &got); err == nil || !strings.Contains(err.Error(), `unknown color "mauve"`) {
+		t.Errorf("expected UnmarshalText error, got %v", err)
+	}
+}
+
+func TestTextMarshalerKeepsNativeEncoding(t *testing.T) {
+	type endpoint struct {
+		Level slog.Level `datastore:"level"`
+		Addr  net.IP     `datastore:"addr"`
+	}
+
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	key := datastore.NameKey("Endpoint", "e1", nil)
+	want := endpoint{Level: slog.LevelWarn, Addr: net.IPv4(10, 0, 0, 1).To4()}
+	if _, err := client.Put(ctx, key, &want); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var pl datastore.PropertyList
+	if err := client.Get(ctx, key, &pl); err != nil {
+		t.Fatalf("Get into PropertyList failed: %v", err)
+	}
+	stored := map[string]any{}
+	for _, p := range pl {
+		stored[p.Name] = p.Value
+	}
+	if stored["level"] != int64(slog.LevelWarn) {
+		t.Errorf("expected level stored as an integer, got %v (%T)", stored["level"], stored["level"])
+	}
+	if addr, ok := stored["addr"].([]byte); !ok || !bytes.Equal(addr, want.Addr) {
+		t.Errorf("expected addr stored as a blob, got %v (%T)", stored["addr"], stored["addr"])
+	}
+
+	var got endpoint
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Level != want.Level || !got.Addr.Equal(want.Addr) {
+		t.Errorf("expected %+v to round trip, got %+v", want, got)
+	}
+}
+
+func TestJSONTagRoundTrip(t *testing.T) {
+	type service struct {
+		Name   string         `datastore:"name"`
+		Config map[string]any `datastore:"config,json"`
+	}
+
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	key := datastore.NameKey("Service", "api", nil)
+	want := service{Name: "api", Config: map[string]any{
+		"retries": float64(3),
+		"hosts":   []any{"a.example.com", "b.example.com"},
+		"tls":     map[string]any{"enabled": true, "ciphers": []any{}},
+	}}
+	if _, err := client.Put(ctx, key, &want); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// The field is stored as an unindexed JSON string
+	var pl datastore.PropertyList
+	if err := client.Get(ctx, key, &pl); err != nil {
+		t.Fatalf("Get into PropertyList failed: %v", err)
+	}
+	for _, p := range pl {
+		if p.Name != "config" {
+			continue
+		}
+		s, ok := p.Value.(string)
+		if !ok || !p.NoIndex || !json.Valid([]byte(s)) {
+			t.Errorf("expected config stored as an unindexed JSON string, got %+v", p)
+		}
+	}
+
+	got := service{Config: map[string]any{"stale": true}}
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, want)
+	}
+
+	// A nil map round trips as nil
+	if _, err := client.Put(ctx, key, &service{Name: "empty"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Config != nil {
+		t.Errorf("expected nil config, got %v", got.Config)
+	}
+}
+
+func TestDecodeIntegerRepresentations(t *testing.T) {
+	for name, raw := range map[string]any{"string": "9007199254740993", "number": json.Number("9007199254740993")} {
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+				// Written by hand so the number keeps full precision on the wire
+				body := fmt.Sprintf(`{"found":[{"entity":{
+					"key":{"path":[{"kind":"TestKind","id":"7"}]},
+					"properties":{"count":{"integerValue":%s}}}}]}`, jsonLiteral(t, raw))
+				if _, err := w.Write([]byte(body)); err != nil {
+					t.Errorf("write failed: %v", err)
+				}
+			})
+			var got testEntity
+			if err := client.Get(context.Background(), datastore.IDKey("TestKind", 7, nil), &got); err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if got.Count != 9007199254740993 {
+				t.Errorf("expected 9007199254740993, got %d", got.Count)
+			}
+		})
+	}
+}
+
+// jsonLiteral returns v encoded as JSON.
+func jsonLiteral(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	return string(b)
+}
@@ -1,8 +1,15 @@
 package datastore
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/codeGROOVE-dev/ds9/auth"
 )
 
 var (
@@ -13,12 +20,68 @@ var (
 	// ErrInvalidKey is returned when an invalid key is presented.
 	ErrInvalidKey = errors.New("datastore: invalid key")
 
-	// ErrNoSuchEntity is returned when no entity was found for a given key.
+	// ErrUnindexedProperty is returned by GetAll when a query filters or orders
+	// on a property that dst's struct type stores unindexed. Datastore would
+	// match no entities rather than report an error.
+	ErrUnindexedProperty = errors.New("datastore: query uses an unindexed property")
+
+	// ErrFieldNotIndexed is returned when a query filters or orders on a
+	// property that RegisterIndexedFields did not declare indexed for the
+	// query's kind. The query is not sent. It wraps ErrUnindexedProperty, so
+	// errors.Is matches either sentinel.
+	ErrFieldNotIndexed = fmt.Errorf("%w: field not registered as indexed", ErrUnindexedProperty)
+
+	// ErrRequestTooLarge is returned, wrapped with the offending size, when a
+	// commit request body exceeds the limit set with WithMaxRequestSize. The
+	// request is not sent.
+	ErrRequestTooLarge = errors.New("datastore: request too large")
+
+	// ErrTooManyProperties is returned by clients created with WithMaxProperties
+	// when an entity to be written has more property values than the limit.
+	ErrTooManyProperties = errors.New("datastore: entity has too many properties")
+
+	// ErrInvalidQuery is returned, wrapped with the reason, when a query is
+	// malformed in a way Datastore would reject. The query is not sent.
+	ErrInvalidQuery = errors.New("datastore: invalid query")
+
+	// ErrMultipleInequality is returned when a query has inequality filters,
+	// such as < or !=, on more than one property, which Datastore rejects
+	// unless it runs in Firestore mode; see WithInequalityCheck.
+	// The query is not sent.
+	ErrMultipleInequality = errors.New("datastore: inequality filters on more than one property")
+
+	// ErrEmptyEntity is returned by clients created with WithStrictEmptyEntities
+	// when an entity to be written has no properties.
+	ErrEmptyEntity = errors.New("datastore: entity has no properties")
+
+	// ErrNoSuchEntity is returned when no entity was found for a given key,
+	// including by an update mutation.
 	ErrNoSuchEntity = errors.New("datastore: no such entity")
 
+	// ErrAlreadyExists is returned when an insert targets a key that already exists.
+	ErrAlreadyExists = errors.New("datastore: entity already exists")
+
 	// ErrConcurrentTransaction is returned when a transaction is used concurrently.
 	ErrConcurrentTransaction = errors.New("datastore: concurrent transaction")
 
+	// ErrAbort can be returned from a RunInTransaction callback to roll back
+	// the transaction without RunInTransaction reporting an error.
+	ErrAbort = errors.New("datastore: transaction aborted by caller")
+
+	// ErrAuth is returned, wrapped, when the credentials used to get an access
+	// token are rejected with 401 or 403, or when Datastore rejects the access
+	// token as unauthenticated or lacking permission. It is the same error as
+	// auth.ErrAuth.
+	ErrAuth = auth.ErrAuth
+
+	// ErrUnavailable is returned, wrapped, by Ping when Datastore can't be
+	// reached or fails for a reason other than rejected credentials.
+	ErrUnavailable = errors.New("datastore: unavailable")
+
+	// ErrNestedTransaction is returned when RunInTransaction is called with a
+	// context belonging to a transaction that is already running.
+	ErrNestedTransaction = errors.New("datastore: nested transactions are not supported")
+
 	// Done is returned by Iterator.Next when no more results are available.
 	// This matches the official cloud.google.com/go/datastore API.
 	//
@@ -51,3 +114,165 @@ func (m MultiError) Error() string {
 	}
 	return fmt.Sprintf("%s (and %d other errors)", s, n-1)
 }
+
+// apiError is a non-success response from the Datastore API.
+type apiError struct {
+	status     string // Canonical code, e.g. "FAILED_PRECONDITION"
+	message    string
+	body       string
+	details    []any
+	statusCode int
+}
+
+// newAPIError builds an apiError from an HTTP status code and response body.
+// Bodies that aren't in the standard Google API error format are kept verbatim.
+func newAPIError(statusCode int, body []byte) *apiError {
+	e := &apiError{statusCode: statusCode, body: string(body)}
+
+	var resp struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Details []any  `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil {
+		e.status = resp.Error.Status
+		e.message = resp.Error.Message
+		e.details = resp.Error.Details
+	}
+
+	return e
+}
+
+// code returns the canonical code of the error, derived from the HTTP status
+// if the body didn't carry one.
+func (e *apiError) code() string {
+	if e.status != "" {
+		return e.status
+	}
+	switch e.statusCode {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "ABORTED"
+	case http.StatusPreconditionFailed:
+		return "FAILED_PRECONDITION"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusNotImplemented:
+		return "UNIMPLEMENTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	}
+	if e.statusCode >= 500 {
+		return "INTERNAL"
+	}
+	return "UNKNOWN"
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.statusCode, e.body)
+}
+
+// Unwrap maps canonical error codes to the package's sentinel errors.
+// NOT_FOUND is not mapped, since for most RPCs it means the project or
+// database doesn't exist; see missingEntity.
+func (e *apiError) Unwrap() error {
+	if e.status == "ALREADY_EXISTS" {
+		return ErrAlreadyExists
+	}
+	if code := e.code(); code == "UNAUTHENTICATED" || code == "PERMISSION_DENIED" {
+		return ErrAuth
+	}
+	return nil
+}
+
+// missingEntity wraps err with ErrNoSuchEntity if it is a NOT_FOUND error
+// from a commit with update mutations, where it means an entity to update
+// doesn't exist.
+func missingEntity(err error) error {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.code() == "NOT_FOUND" {
+		return fmt.Errorf("%w: %w", ErrNoSuchEntity, err)
+	}
+	return err
+}
+
+// hasUpdate reports whether mutations include an update.
+func hasUpdate(mutations []map[string]any) bool {
+	return slices.ContainsFunc(mutations, func(m map[string]any) bool {
+		_, ok := m["update"]
+		return ok
+	})
+}
+
+// IndexCreationHint extracts instructions for creating a missing composite index
+// from an error returned by a query. The hint is whatever the server provided:
+// typically index.yaml content, a gcloud command, or a console URL.
+// Returns false if err is not a missing-index error or carries no hint.
+func IndexCreationHint(err error) (string, bool) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.status != "FAILED_PRECONDITION" {
+		return "", false
+	}
+
+	// Prefer structured details over parsing the human-readable message
+	for _, detail := range apiErr.details {
+		if hint := indexHintFromDetail(detail); hint != "" {
+			return hint, true
+		}
+	}
+
+	const marker = "recommended index is:"
+	if i := strings.Index(apiErr.message, marker); i >= 0 {
+		if hint := strings.TrimSpace(apiErr.message[i+len(marker):]); hint != "" {
+			return hint, true
+		}
+	}
+
+	return "", false
+}
+
+// indexHintFromDetail searches an error detail for an index definition,
+// a gcloud command, or a help link.
+func indexHintFromDetail(detail any) string {
+	switch d := detail.(type) {
+	case string:
+		if strings.HasPrefix(strings.TrimSpace(d), "gcloud ") ||
+			(strings.Contains(d, "kind:") && strings.Contains(d, "properties:")) {
+			return strings.TrimSpace(d)
+		}
+	case []any:
+		for _, v := range d {
+			if hint := indexHintFromDetail(v); hint != "" {
+				return hint
+			}
+		}
+	case map[string]any:
+		// google.rpc.Help links point at the console page that creates the index
+		if links, ok := d["links"].([]any); ok {
+			for _, l := range links {
+				if link, ok := l.(map[string]any); ok {
+					if url, ok := link["url"].(string); ok && url != "" {
+						return url
+					}
+				}
+			}
+		}
+		for _, k := range slices.Sorted(maps.Keys(d)) {
+			if hint := indexHintFromDetail(d[k]); hint != "" {
+				return hint
+			}
+		}
+	}
+	return ""
+}
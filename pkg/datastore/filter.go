@@ -0,0 +1,163 @@
+package datastore
+
+// Filter is a condition on query results that can be combined with others.
+// Build one with PropertyFilter, AndFilter, and OrFilter, and attach it to a
+// query with Query.FilterEntity.
+type Filter interface {
+	// filterJSON returns the filter for a runQuery request, or nil if it is
+	// empty and should be left out. It fails if a value can't be encoded.
+	filterJSON() (map[string]any, error)
+	// properties returns the names of the properties the filter tests.
+	properties() []string
+	// inequalities returns the names of the properties the filter tests
+	// with an inequality operator.
+	inequalities() []string
+	// rewriteKeys returns a copy of the filter with rewrite applied to the
+	// keys it compares __key__ against.
+	rewriteKeys(rewrite func(*Key) *Key) Filter
+}
+
+// PropertyFilter is a condition on a single property, such as
+// PropertyFilter{FieldName: "status", Operator: "=", Value: "open"}.
+// Operator takes the same values as Query.FilterField.
+type PropertyFilter struct {
+	Value     any
+	FieldName string
+	Operator  string
+}
+
+func (f PropertyFilter) filterJSON() (map[string]any, error) {
+	op, ok := operatorMap[f.Operator]
+	if !ok {
+		op = f.Operator // Might already be EQUAL, etc.
+	}
+	return queryFilter{property: f.FieldName, operator: op, value: f.Value}.filterJSON()
+}
+
+func (f PropertyFilter) properties() []string {
+	return []string{f.FieldName}
+}
+
+func (f PropertyFilter) rewriteKeys(rewrite func(*Key) *Key) Filter {
+	if f.FieldName == "__key__" {
+		f.Value = rewriteKeyValue(f.Value, rewrite)
+	}
+	return f
+}
+
+func (f PropertyFilter) inequalities() []string {
+	op, ok := operatorMap[f.Operator]
+	if !ok {
+		op = f.Operator
+	}
+	if !inequalityOperators[op] {
+		return nil
+	}
+	return []string{f.FieldName}
+}
+
+// AndFilter returns a Filter matching entities that match all of filters.
+func AndFilter(filters ...Filter) Filter {
+	return compositeFilter{op: "AND", filters: filters}
+}
+
+// OrFilter returns a Filter matching entities that match any of filters.
+func OrFilter(filters ...Filter) Filter {
+	return compositeFilter{op: "OR", filters: filters}
+}
+
+type compositeFilter struct {
+	op      string // "AND" or "OR"
+	filters []Filter
+}
+
+func (f compositeFilter) filterJSON() (map[string]any, error) {
+	var filters []map[string]any
+	for _, sub := range f.filters {
+		if sub == nil {
+			continue
+		}
+		m, err := sub.filterJSON()
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			filters = append(filters, m)
+		}
+	}
+	switch len(filters) {
+	case 0:
+		return nil, nil
+	case 1:
+		return filters[0], nil
+	default:
+		return map[string]any{
+			"compositeFilter": map[string]any{
+				"op":      f.op,
+				"filters": filters,
+			},
+		}, nil
+	}
+}
+
+func (f compositeFilter) properties() []string {
+	var names []string
+	for _, sub := range f.filters {
+		if sub != nil {
+			names = append(names, sub.properties()...)
+		}
+	}
+	return names
+}
+
+func (f compositeFilter) rewriteKeys(rewrite func(*Key) *Key) Filter {
+	filters := make([]Filter, len(f.filters))
+	for i, sub := range f.filters {
+		if sub != nil {
+			filters[i] = sub.rewriteKeys(rewrite)
+		}
+	}
+	return compositeFilter{op: f.op, filters: filters}
+}
+
+func (f compositeFilter) inequalities() []string {
+	var names []string
+	for _, sub := range f.filters {
+		if sub != nil {
+			names = append(names, sub.inequalities()...)
+		}
+	}
+	return names
+}
+
+// FilterEntity adds a filter, such as an OrFilter, to the query. Like other
+// filters on the query, it must hold for every result.
+func (q *Query) FilterEntity(f Filter) *Query {
+	if f != nil {
+		q.entityFilters = append(q.entityFilters, f)
+	}
+	return q
+}
+
+// rewriteKeyValue applies rewrite to a __key__ filter value: a key, or each
+// key in the list for IN or NOT_IN. Other values are returned unchanged.
+func rewriteKeyValue(v any, rewrite func(*Key) *Key) any {
+	switch v := v.(type) {
+	case *Key:
+		return rewrite(v)
+	case []*Key:
+		keys := make([]*Key, len(v))
+		for i, k := range v {
+			keys[i] = rewrite(k)
+		}
+		return keys
+	case []any:
+		values := make([]any, len(v))
+		for i, elem := range v {
+			values[i] = rewriteKeyValue(elem, rewrite)
+		}
+		return values
+	default:
+		return v
+	}
+}
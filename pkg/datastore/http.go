@@ -2,10 +2,11 @@ package datastore
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log/slog"
 	"math"
 	"math/rand/v2"
 	"net/http"
@@ -13,15 +14,73 @@ import (
 	"time"
 )
 
-// doRequest performs an HTTP request with exponential backoff retries.
+// unmarshalResponse parses a response body into v, keeping JSON numbers as
+// json.Number so integers sent as numbers rather than strings decode losslessly.
+func unmarshalResponse(body []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// doRequest performs an RPC within a tracing span covering all of its attempts,
+// bounded by the client's timeout if it has one.
+// entities is the number of entities in the request, or unknownEntities.
+// A commit body over the client's maximum request size is not sent.
+func (c *Client) doRequest(
+	ctx context.Context, url string, jsonData []byte, token string, retry RetryConfig, entities int,
+) ([]byte, error) {
+	if c.maxRequestSize > 0 && len(jsonData) > c.maxRequestSize && rpcMethod(url) == "commit" {
+		return nil, fmt.Errorf("%w: commit body is %d bytes, limit is %d", ErrRequestTooLarge, len(jsonData), c.maxRequestSize)
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	ctx, end := c.startSpan(ctx, url, entities)
+	body, err := c.doRequestWithRetry(ctx, url, jsonData, token, retry)
+	end(err)
+	return body, err
+}
+
+// doRequestWithRetry performs an HTTP request with exponential backoff retries per retry.
 // Returns an error if the status code is not 200 OK.
-func doRequest(ctx context.Context, logger *slog.Logger, url string, jsonData []byte, token, projectID, databaseID string) ([]byte, error) {
+func (c *Client) doRequestWithRetry(ctx context.Context, url string, jsonData []byte, token string, retry RetryConfig) ([]byte, error) {
+	logger, projectID, databaseID := c.logger, c.projectID, c.databaseID
+	maxAttempts := max(retry.MaxAttempts, 1)
+	// An unset backoff would retry in a tight loop, so fall back to the defaults
+	if retry.BaseBackoff <= 0 {
+		retry.BaseBackoff = baseBackoff
+	}
+	if retry.MaxBackoff <= 0 {
+		retry.MaxBackoff = maxBackoff
+	}
 	var lastErr error
+	var lastStatus int // status code of the last failed attempt, if it got a response
 
-	for attempt := range maxRetries {
+	// Compress once up front; every attempt resends the same body
+	reqBody, gzipped := jsonData, false
+	if c.compress && len(jsonData) > compressionThreshold {
+		zipped, err := gzipBytes(jsonData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress request: %w", err)
+		}
+		reqBody, gzipped = zipped, true
+	}
+
+	method := rpcMethod(url)
+	observe := func(status int, start time.Time) {
+		if c.metrics != nil {
+			c.metrics.ObserveRequest(method, status, time.Since(start))
+		}
+	}
+
+	for attempt := range maxAttempts {
 		if attempt > 0 {
-			// Exponential backoff: 100ms, 200ms, 400ms... capped at maxBackoffMS
-			backoffMS := math.Min(float64(baseBackoffMS)*math.Pow(2, float64(attempt-1)), float64(maxBackoffMS))
+			// Exponential backoff: 100ms, 200ms, 400ms... capped at MaxBackoff
+			backoffMS := math.Min(
+				float64(retry.BaseBackoff.Milliseconds())*math.Pow(2, float64(attempt-1)),
+				float64(retry.MaxBackoff.Milliseconds()))
 			// Add jitter: ±25% randomness
 			jitter := backoffMS * jitterFraction * (2*rand.Float64() - 1) //nolint:gosec // Weak random is acceptable for jitter
 			sleepMS := backoffMS + jitter
@@ -29,9 +88,13 @@ func doRequest(ctx context.Context, logger *slog.Logger, url string, jsonData []
 
 			logger.DebugContext(ctx, "retrying request",
 				"attempt", attempt+1,
-				"max_attempts", maxRetries,
+				"max_attempts", maxAttempts,
 				"backoff_ms", int(sleepMS),
+				"status_code", lastStatus,
 				"last_error", lastErr)
+			if c.metrics != nil {
+				c.metrics.IncRetry(method)
+			}
 
 			select {
 			case <-time.After(sleepDuration):
@@ -40,13 +103,21 @@ func doRequest(ctx context.Context, logger *slog.Logger, url string, jsonData []
 			}
 		}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
 
 		req.Header.Set("Authorization", "Bearer "+token)
 		req.Header.Set("Content-Type", "application/json")
+		setRequestID(req)
+		if gzipped {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		if c.compress {
+			// Setting this ourselves disables the transport's transparent decompression
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
 
 		// Add routing header for named databases
 		if databaseID != "" {
@@ -55,14 +126,21 @@ func doRequest(ctx context.Context, logger *slog.Logger, url string, jsonData []
 			req.Header.Set("X-Goog-Request-Params", routingHeader)
 		}
 
-		logger.DebugContext(ctx, "sending request", "url", url, "attempt", attempt+1)
+		logger.DebugContext(ctx, "sending request", "method", req.Method, "path", req.URL.Path, "attempt", attempt+1)
 
+		start := time.Now()
 		resp, err := httpClient.Do(req)
 		if err != nil {
-			lastErr = err
+			observe(0, start)
+			// Don't retry once the caller gave up; err wraps context.Canceled or
+			// context.DeadlineExceeded so callers can tell the two apart
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("request aborted: %w", err)
+			}
+			lastErr, lastStatus = err, 0
 			logger.WarnContext(ctx, "request failed", "error", err, "attempt", attempt+1)
-			if attempt == maxRetries-1 {
-				return nil, fmt.Errorf("request failed after %d attempts: %w", maxRetries, err)
+			if attempt == maxAttempts-1 {
+				return nil, fmt.Errorf("request failed after %d attempts: %w", maxAttempts, err)
 			}
 			continue
 		}
@@ -74,12 +152,13 @@ func doRequest(ctx context.Context, logger *slog.Logger, url string, jsonData []
 			}
 		}()
 
-		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		body, err := readBody(resp)
+		observe(resp.StatusCode, start)
 		if err != nil {
-			lastErr = err
+			lastErr, lastStatus = err, resp.StatusCode
 			logger.WarnContext(ctx, "failed to read response body", "error", err, "attempt", attempt+1)
-			if attempt == maxRetries-1 {
-				return nil, fmt.Errorf("failed to read response after %d attempts: %w", maxRetries, err)
+			if attempt == maxAttempts-1 {
+				return nil, fmt.Errorf("failed to read response after %d attempts: %w", maxAttempts, err)
 			}
 			continue
 		}
@@ -91,17 +170,13 @@ func doRequest(ctx context.Context, logger *slog.Logger, url string, jsonData []
 
 		// Success
 		if resp.StatusCode == http.StatusOK {
-			return body, nil
-		}
-
-		// Don't retry on 4xx errors (client errors)
-		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-			if resp.StatusCode == http.StatusNotFound {
-				logger.DebugContext(ctx, "entity not found", "status_code", resp.StatusCode)
-			} else {
-				logger.WarnContext(ctx, "client error", "status_code", resp.StatusCode, "body", string(body))
+			if attempt > 0 {
+				logger.DebugContext(ctx, "request succeeded after retries", "method", method, "attempts", attempt+1)
 			}
-			return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+			if o, ok := c.metrics.(AttemptsObserver); ok {
+				o.ObserveAttempts(method, attempt+1)
+			}
+			return body, nil
 		}
 
 		// Unexpected 2xx/3xx status codes
@@ -110,13 +185,57 @@ func doRequest(ctx context.Context, logger *slog.Logger, url string, jsonData []
 			return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
 		}
 
-		// 5xx errors - retry
-		lastErr = fmt.Errorf("server error: status %d", resp.StatusCode)
-		logger.WarnContext(ctx, "server error, will retry",
+		apiErr := newAPIError(resp.StatusCode, body)
+		if !c.retryable(apiErr) {
+			logger.WarnContext(ctx, "request failed, not retrying", "status_code", resp.StatusCode, "code", apiErr.code(), "body", string(body))
+			return nil, apiErr
+		}
+
+		// Retryable errors, by default 5xx
+		lastErr, lastStatus = apiErr, resp.StatusCode
+		logger.WarnContext(ctx, "request failed, will retry",
 			"status_code", resp.StatusCode,
+			"code", apiErr.code(),
 			"attempt", attempt+1,
 			"body", string(body))
 	}
 
-	return nil, fmt.Errorf("all %d attempts failed: %w", maxRetries, lastErr)
+	return nil, fmt.Errorf("all %d attempts failed: %w", maxAttempts, lastErr)
+}
+
+// retryable reports whether a request that failed with e should be retried.
+// Without WithRetryableCodes, 5xx responses other than 501 (never going to
+// work) are retried and client errors aren't.
+func (c *Client) retryable(e *apiError) bool {
+	if c.retryableCodes != nil {
+		return c.retryableCodes[e.code()]
+	}
+	return e.statusCode >= 500 && e.statusCode != http.StatusNotImplemented
+}
+
+// readBody reads a response body, decompressing it if it is gzip-encoded.
+func readBody(resp *http.Response) ([]byte, error) {
+	var r io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress response: %w", err)
+		}
+		defer gz.Close() //nolint:errcheck // Reader close only reports checksum errors already seen by Read
+		r = gz
+	}
+	return io.ReadAll(io.LimitReader(r, maxBodySize))
+}
+
+// gzipBytes compresses data with gzip.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
@@ -1,12 +1,17 @@
 package datastore_test
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -239,6 +244,34 @@ func TestDoRequestContextCancellation(t *testing.T) {
 	}
 }
 
+func TestDoRequestContextDeadline(t *testing.T) {
+	var attempts atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		// Outlast the caller's deadline
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var dst testEntity
+	err := client.Get(ctx, datastore.NameKey("TestKind", "deadline-test", nil), &dst)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got: %v", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Errorf("deadline error should not match context.Canceled: %v", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("expected no retries after the deadline passed, got %d attempts", n)
+	}
+}
+
 func TestGetWithHTTPError(t *testing.T) {
 	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Metadata-Flavor") != "Google" {
@@ -434,6 +467,120 @@ func TestDoRequestAllRetriesFail(t *testing.T) {
 	}
 }
 
+func TestSeparateReadAndCommitRetries(t *testing.T) {
+	var lookups, commits int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, ":lookup"):
+			lookups++
+		case strings.HasSuffix(r.URL.Path, ":commit"):
+			commits++
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	},
+		datastore.WithReadRetry(datastore.RetryConfig{MaxAttempts: 5, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+		datastore.WithCommitRetry(datastore.RetryConfig{MaxAttempts: 1, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+	)
+
+	ctx := context.Background()
+	key := datastore.NameKey("TestKind", "test", nil)
+
+	var dst testEntity
+	if err := client.Get(ctx, key, &dst); err == nil {
+		t.Fatal("expected Get to fail")
+	}
+	if lookups != 5 {
+		t.Errorf("expected 5 lookup attempts, got %d", lookups)
+	}
+
+	if _, err := client.Put(ctx, key, &testEntity{Name: "test"}); err == nil {
+		t.Fatal("expected Put to fail")
+	}
+	if commits != 1 {
+		t.Errorf("expected 1 commit attempt, got %d", commits)
+	}
+}
+
+func TestRetryZeroBackoffUsesDefaults(t *testing.T) {
+	var attempts atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(t, w, map[string]any{"missing": []any{}})
+	}, datastore.WithReadRetry(datastore.RetryConfig{MaxAttempts: 2}))
+
+	start := time.Now()
+	var dst testEntity
+	if err := client.Get(context.Background(), datastore.NameKey("TestKind", "k", nil), &dst); !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Fatalf("expected ErrNoSuchEntity after a retry, got %v", err)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Fatalf("expected 2 attempts, got %d", n)
+	}
+	// The default 100ms base backoff, less at most 25% jitter
+	if elapsed := time.Since(start); elapsed < 75*time.Millisecond {
+		t.Errorf("expected the retry to back off by the default, took %v", elapsed)
+	}
+}
+
+func TestWithRetryableCodes(t *testing.T) {
+	retry := datastore.WithReadRetry(datastore.RetryConfig{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	ctx := context.Background()
+	key := datastore.NameKey("TestKind", "test", nil)
+
+	// respond fails every lookup with status and canonical code
+	respond := func(attempts *atomic.Int32, status int, code string) http.HandlerFunc {
+		return func(w http.ResponseWriter, _ *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(status)
+			writeJSON(t, w, map[string]any{"error": map[string]any{"code": status, "status": code, "message": code}})
+		}
+	}
+
+	t.Run("UnavailableRemoved", func(t *testing.T) {
+		var attempts atomic.Int32
+		client := newTestClient(t, respond(&attempts, http.StatusServiceUnavailable, "UNAVAILABLE"),
+			retry, datastore.WithRetryableCodes("ABORTED", "DEADLINE_EXCEEDED"))
+		var dst testEntity
+		if err := client.Get(ctx, key, &dst); err == nil {
+			t.Fatal("expected Get to fail")
+		}
+		if n := attempts.Load(); n != 1 {
+			t.Errorf("expected UNAVAILABLE to fail after 1 attempt, got %d", n)
+		}
+	})
+
+	t.Run("AbortedAdded", func(t *testing.T) {
+		var attempts atomic.Int32
+		client := newTestClient(t, respond(&attempts, http.StatusConflict, "ABORTED"),
+			retry, datastore.WithRetryableCodes("ABORTED"))
+		var dst testEntity
+		if err := client.Get(ctx, key, &dst); err == nil {
+			t.Fatal("expected Get to fail")
+		}
+		if n := attempts.Load(); n != 3 {
+			t.Errorf("expected ABORTED to be retried to 3 attempts, got %d", n)
+		}
+	})
+
+	t.Run("CodeFromHTTPStatus", func(t *testing.T) {
+		var attempts atomic.Int32
+		client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}, retry, datastore.WithRetryableCodes("DEADLINE_EXCEEDED"))
+		var dst testEntity
+		if err := client.Get(ctx, key, &dst); err == nil {
+			t.Fatal("expected Get to fail")
+		}
+		if n := attempts.Load(); n != 3 {
+			t.Errorf("expected a bare 504 to be retried as DEADLINE_EXCEEDED, got %d attempts", n)
+		}
+	})
+}
+
 func TestDoRequestUnexpectedSuccess(t *testing.T) {
 	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Metadata-Flavor") != "Google" {
@@ -554,3 +701,523 @@ func TestDoRequestWithReadBodyError(t *testing.T) {
 		t.Logf("Got expected error with incomplete response: %v", err)
 	}
 }
+
+type logEntry struct {
+	level string
+	msg   string
+	args  []any
+}
+
+type capturingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *capturingLogger) log(level, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, args: args})
+}
+
+func (l *capturingLogger) Debug(msg string, args ...any) { l.log("debug", msg, args) }
+func (l *capturingLogger) Warn(msg string, args ...any)  { l.log("warn", msg, args) }
+func (l *capturingLogger) Error(msg string, args ...any) { l.log("error", msg, args) }
+
+// arg returns the value logged for key, if any.
+func (e logEntry) arg(key string) (any, bool) {
+	for i := 0; i+1 < len(e.args); i += 2 {
+		if e.args[i] == key {
+			return e.args[i+1], true
+		}
+	}
+	return nil, false
+}
+
+func TestWithLoggerCapturesRetries(t *testing.T) {
+	var attempts atomic.Int32
+	logger := &capturingLogger{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(t, w, map[string]any{"mutationResults": []any{map[string]any{}}})
+	},
+		datastore.WithLogger(logger),
+		datastore.WithCommitRetry(datastore.RetryConfig{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+	)
+
+	if _, err := client.Put(context.Background(), datastore.NameKey("TestKind", "log", nil), &testEntity{Name: "log"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	var retries, requests int
+	for _, e := range logger.entries {
+		switch e.msg {
+		case "retrying request":
+			retries++
+			if status, _ := e.arg("status_code"); status != int64(http.StatusServiceUnavailable) {
+				t.Errorf("expected retry logged with status 503, got %v", status)
+			}
+			if _, ok := e.arg("backoff_ms"); !ok {
+				t.Error("expected retry logged with backoff")
+			}
+		case "sending request":
+			requests++
+			if path, _ := e.arg("path"); path != "/projects/test-project:commit" {
+				t.Errorf("unexpected request path %v", path)
+			}
+		}
+	}
+	if retries != 1 {
+		t.Errorf("expected 1 logged retry, got %d", retries)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 logged requests, got %d", requests)
+	}
+}
+
+type observedRequest struct {
+	method string
+	status int
+}
+
+type fakeCollector struct {
+	mu       sync.Mutex
+	requests []observedRequest
+	retries  map[string]int
+	attempts map[string][]int
+}
+
+func (f *fakeCollector) ObserveRequest(method string, status int, _ time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, observedRequest{method: method, status: status})
+}
+
+func (f *fakeCollector) ObserveAttempts(method string, attempts int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.attempts == nil {
+		f.attempts = make(map[string][]int)
+	}
+	f.attempts[method] = append(f.attempts[method], attempts)
+}
+
+func (f *fakeCollector) IncRetry(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.retries == nil {
+		f.retries = make(map[string]int)
+	}
+	f.retries[method]++
+}
+
+func TestWithMetrics(t *testing.T) {
+	var attempts atomic.Int32
+	collector := &fakeCollector{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(t, w, map[string]any{"mutationResults": []any{map[string]any{}}})
+	},
+		datastore.WithMetrics(collector),
+		datastore.WithCommitRetry(datastore.RetryConfig{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+	)
+
+	if _, err := client.Put(context.Background(), datastore.NameKey("TestKind", "metrics", nil), &testEntity{Name: "m"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+
+	want := []observedRequest{
+		{method: "commit", status: http.StatusServiceUnavailable},
+		{method: "commit", status: http.StatusOK},
+	}
+	if !slices.Equal(collector.requests, want) {
+		t.Errorf("expected observed requests %v, got %v", want, collector.requests)
+	}
+	if collector.retries["commit"] != 1 || len(collector.retries) != 1 {
+		t.Errorf("expected one commit retry, got %v", collector.retries)
+	}
+}
+
+func TestWithMetricsTransaction(t *testing.T) {
+	var lookups atomic.Int32
+	collector := &fakeCollector{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/projects/test-project:beginTransaction":
+			writeJSON(t, w, map[string]any{"transaction": "tx-1"})
+		case "/projects/test-project:lookup":
+			if lookups.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeJSON(t, w, map[string]any{"missing": []any{}})
+		default:
+			writeJSON(t, w, map[string]any{"mutationResults": []any{map[string]any{}}})
+		}
+	},
+		datastore.WithMetrics(collector),
+		datastore.WithReadRetry(datastore.RetryConfig{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+	)
+
+	key := datastore.NameKey("TestKind", "metrics", nil)
+	_, err := client.RunInTransaction(context.Background(), func(tx *datastore.Transaction) error {
+		var got testEntity
+		if err := tx.Get(key, &got); !errors.Is(err, datastore.ErrNoSuchEntity) {
+			return err
+		}
+		_, err := tx.Put(key, &testEntity{Name: "m"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+
+	want := []observedRequest{
+		{method: "beginTransaction", status: http.StatusOK},
+		{method: "lookup", status: http.StatusServiceUnavailable},
+		{method: "lookup", status: http.StatusOK},
+		{method: "commit", status: http.StatusOK},
+	}
+	if !slices.Equal(collector.requests, want) {
+		t.Errorf("expected observed requests %v, got %v", want, collector.requests)
+	}
+	if collector.retries["lookup"] != 1 || len(collector.retries) != 1 {
+		t.Errorf("expected one lookup retry, got %v", collector.retries)
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	var mu sync.Mutex
+	var encodings []string
+	var mutations int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("request body is not valid gzip: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = gz
+		}
+		var req struct {
+			Mutations []any `json:"mutations"`
+		}
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			t.Errorf("decode failed: %v", err)
+		}
+		mu.Lock()
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		mutations += len(req.Mutations)
+		mu.Unlock()
+
+		results := make([]any, len(req.Mutations))
+		for i := range results {
+			results[i] = map[string]any{}
+		}
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Error("expected Accept-Encoding: gzip")
+			writeJSON(t, w, map[string]any{"mutationResults": results})
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		if err := json.NewEncoder(gz).Encode(map[string]any{"mutationResults": results}); err != nil {
+			t.Errorf("encode failed: %v", err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Errorf("gzip close failed: %v", err)
+		}
+	}, datastore.WithCompression(true))
+
+	ctx := context.Background()
+	const n = 300
+	keys := make([]*datastore.Key, n)
+	entities := make([]testEntity, n)
+	for i := range n {
+		keys[i] = datastore.IDKey("Compressed", int64(i+1), nil)
+		entities[i] = testEntity{Name: strings.Repeat("x", 100), Count: int64(i)}
+	}
+	if _, err := client.PutMulti(ctx, keys, entities); err != nil {
+		t.Fatalf("PutMulti failed: %v", err)
+	}
+	if _, err := client.Put(ctx, datastore.NameKey("Compressed", "small", nil), &testEntity{Name: "small"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"gzip", ""}; !slices.Equal(encodings, want) {
+		t.Errorf("expected request encodings %v, got %v", want, encodings)
+	}
+	if mutations != n+1 {
+		t.Errorf("expected %d mutations decoded server-side, got %d", n+1, mutations)
+	}
+}
+
+func TestWithCompressionTransaction(t *testing.T) {
+	var encoding string
+	var mutations int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/projects/test-project:beginTransaction" {
+			writeJSON(t, w, map[string]any{"transaction": "tx-1"})
+			return
+		}
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("request body is not valid gzip: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = gz
+		}
+		var req struct {
+			Mutations []any `json:"mutations"`
+		}
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			t.Errorf("decode failed: %v", err)
+		}
+		encoding, mutations = r.Header.Get("Content-Encoding"), len(req.Mutations)
+		writeJSON(t, w, map[string]any{"mutationResults": make([]any, len(req.Mutations))})
+	}, datastore.WithCompression(true))
+
+	const n = 300
+	keys := make([]*datastore.Key, n)
+	entities := make([]testEntity, n)
+	for i := range n {
+		keys[i] = datastore.IDKey("Compressed", int64(i+1), nil)
+		entities[i] = testEntity{Name: strings.Repeat("x", 100), Count: int64(i)}
+	}
+	_, err := client.RunInTransaction(context.Background(), func(tx *datastore.Transaction) error {
+		_, err := tx.PutMulti(keys, entities)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+	if encoding != "gzip" {
+		t.Errorf("expected the transactional commit to be gzip-encoded, got %q", encoding)
+	}
+	if mutations != n {
+		t.Errorf("expected %d mutations decoded server-side, got %d", n, mutations)
+	}
+}
+
+func TestMetricsObserveAttempts(t *testing.T) {
+	var calls atomic.Int32
+	collector := &fakeCollector{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeJSON(t, w, map[string]any{"mutationResults": []any{map[string]any{}}})
+	},
+		datastore.WithMetrics(collector),
+		datastore.WithCommitRetry(datastore.RetryConfig{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+	)
+
+	if _, err := client.Put(context.Background(), datastore.NameKey("TestKind", "attempts", nil), &testEntity{Name: "a"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if got := collector.attempts["commit"]; !slices.Equal(got, []int{3}) {
+		t.Errorf("expected one commit observed with 3 attempts, got %v", got)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		writeJSON(t, w, map[string]any{"mutationResults": []any{map[string]any{}}})
+	}
+	ctx := context.Background()
+	key := datastore.NameKey("TestKind", "slow", nil)
+
+	t.Run("Exceeded", func(t *testing.T) {
+		client := newTestClient(t, slow, datastore.WithTimeout(50*time.Millisecond))
+		start := time.Now()
+		_, err := client.Put(ctx, key, &testEntity{Name: "slow"})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+			t.Errorf("expected the call to stop at the timeout, took %v", elapsed)
+		}
+	})
+
+	t.Run("Generous", func(t *testing.T) {
+		client := newTestClient(t, slow, datastore.WithTimeout(5*time.Second))
+		if _, err := client.Put(ctx, key, &testEntity{Name: "slow"}); err != nil {
+			t.Fatalf("expected success within a generous timeout, got %v", err)
+		}
+	})
+
+	t.Run("TransactionCommit", func(t *testing.T) {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/projects/test-project:beginTransaction":
+				writeJSON(t, w, map[string]any{"transaction": "tx-1"})
+			case "/projects/test-project:commit":
+				slow(w, r)
+			default:
+				writeJSON(t, w, map[string]any{})
+			}
+		}, datastore.WithTimeout(50*time.Millisecond))
+		start := time.Now()
+		_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+			_, err := tx.Put(key, &testEntity{Name: "slow"})
+			return err
+		}, datastore.MaxAttempts(1))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+			t.Errorf("expected the commit to stop at the timeout, took %v", elapsed)
+		}
+	})
+}
+
+func TestTransactionRetryLogsCarryRequestID(t *testing.T) {
+	var begins, commits atomic.Int32
+	logger := &capturingLogger{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/projects/test-project:beginTransaction":
+			if begins.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				writeJSON(t, w, map[string]any{"error": map[string]any{"status": "UNAVAILABLE"}})
+				return
+			}
+			writeJSON(t, w, map[string]any{"transaction": "tx-1"})
+		case "/projects/test-project:commit":
+			if commits.Add(1) == 1 {
+				w.WriteHeader(http.StatusConflict)
+				writeJSON(t, w, map[string]any{"error": map[string]any{"status": "ABORTED"}})
+				return
+			}
+			writeJSON(t, w, map[string]any{"mutationResults": []any{}})
+		default:
+			writeJSON(t, w, map[string]any{})
+		}
+	}, datastore.WithLogger(logger))
+
+	ctx := datastore.WithRequestID(context.Background(), "req-456")
+	if _, err := client.RunInTransaction(ctx, func(*datastore.Transaction) error { return nil }); err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logged := map[string]bool{}
+	for _, e := range logger.entries {
+		if !strings.HasPrefix(e.msg, "transaction") && e.msg != "sleeping before retry" {
+			continue
+		}
+		if id, _ := e.arg("request_id"); id != "req-456" {
+			t.Errorf("expected %q logged with request_id req-456, got %v", e.msg, id)
+		}
+		logged[e.msg] = true
+	}
+	for _, msg := range []string{
+		"transaction begin failed, will retry",
+		"transaction commit failed",
+		"transaction aborted, will retry",
+		"sleeping before retry",
+		"transaction committed successfully",
+	} {
+		if !logged[msg] {
+			t.Errorf("expected %q to be logged", msg)
+		}
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	var mu sync.Mutex
+	headers := map[string]string{} // path -> X-Request-ID
+	logger := &capturingLogger{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers[r.URL.Path] = r.Header.Get("X-Request-ID")
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/projects/test-project:beginTransaction":
+			writeJSON(t, w, map[string]any{"transaction": "tx-1"})
+		case "/projects/test-project:lookup":
+			writeJSON(t, w, map[string]any{"missing": []any{map[string]any{"entity": map[string]any{"key": map[string]any{
+				"path": []any{map[string]any{"kind": "TestKind", "name": "a"}},
+			}}}}})
+		default:
+			writeJSON(t, w, map[string]any{"mutationResults": []any{map[string]any{}}})
+		}
+	}, datastore.WithLogger(logger))
+
+	ctx := datastore.WithRequestID(context.Background(), "req-123")
+	key := datastore.NameKey("TestKind", "a", nil)
+	if _, err := client.Put(ctx, key, &testEntity{Name: "a"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if got := headers["/projects/test-project:commit"]; got != "req-123" {
+		t.Errorf("commit X-Request-ID = %q, want req-123", got)
+	}
+
+	// Transactional requests carry the ID too
+	_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var dst testEntity
+		if err := tx.Get(key, &dst); !errors.Is(err, datastore.ErrNoSuchEntity) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+	for _, path := range []string{"beginTransaction", "lookup", "commit"} {
+		if got := headers["/projects/test-project:"+path]; got != "req-123" {
+			t.Errorf("%s X-Request-ID = %q, want req-123", path, got)
+		}
+	}
+
+	logger.mu.Lock()
+	var tagged int
+	for _, e := range logger.entries {
+		if id, _ := e.arg("request_id"); id == "req-123" {
+			tagged++
+		}
+	}
+	logger.mu.Unlock()
+	if tagged == 0 {
+		t.Error("expected logs to include request_id")
+	}
+
+	// Without an ID, no header is sent
+	if _, err := client.Put(context.Background(), key, &testEntity{Name: "a"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if got := headers["/projects/test-project:commit"]; got != "" {
+		t.Errorf("expected no X-Request-ID without an ID, got %q", got)
+	}
+}
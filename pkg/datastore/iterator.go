@@ -21,60 +21,84 @@ type Iterator struct {
 	results   []iteratorResult
 	index     int
 	err       error
-	cursor    Cursor
+	cursor    Cursor // position after the most recently returned result
+	endCursor Cursor // position after the last fetched batch, where the next one starts
 	fetchNext bool
+	atLimit   bool // the last batch ended at the query's limit
+	done      bool // Next has returned Done
+	fetched   int  // results received so far, to carry the limit across batches
+	skipped   int  // results skipped so far, to carry the offset across batches
 }
 
 type iteratorResult struct {
-	key    *Key
-	entity map[string]any
-	cursor Cursor
+	key     *Key
+	entity  map[string]any
+	cursor  Cursor
+	version int64
 }
 
 // Next advances the iterator and returns the next key and destination.
 // It returns Done when no more results are available.
 // API compatible with cloud.google.com/go/datastore.
 func (it *Iterator) Next(dst any) (*Key, error) {
-	// Check if we need to fetch more results
-	if it.index >= len(it.results) {
+	result, err := it.nextResult()
+	if err != nil {
+		return nil, err
+	}
+
+	// For KeysOnly queries, skip entity decoding - just return the key
+	// The Datastore API returns entities without properties for keys-only queries
+	if !it.query.keysOnly {
+		if err := decodeEntity(result.entity, dst); err != nil {
+			return nil, err
+		}
+		setVersion(dst, result.version)
+	}
+
+	return result.key, nil
+}
+
+// nextResult advances the iterator and returns the next raw result,
+// fetching further batches as needed. It returns Done when no more results are available.
+func (it *Iterator) nextResult() (iteratorResult, error) {
+	// Fetch batches until one has results; a batch may legitimately be empty
+	// (e.g. entirely skipped by an offset) while more results remain.
+	for it.index >= len(it.results) {
 		if it.err != nil {
-			return nil, it.err
+			return iteratorResult{}, it.err
 		}
 		if !it.fetchNext {
-			return nil, Done
+			// Exhausted: the end cursor resumes after everything returned
+			if it.endCursor != "" {
+				it.cursor = it.endCursor
+			}
+			it.done = true
+			return iteratorResult{}, Done
 		}
 
 		// Fetch next batch
 		if err := it.fetch(); err != nil {
 			it.err = err
-			return nil, err
-		}
-
-		if len(it.results) == 0 {
-			return nil, Done
+			return iteratorResult{}, err
 		}
 	}
 
 	result := it.results[it.index]
 	it.index++
 
-	// Only update cursor if the result has one
+	// The per-result cursor resumes right after this result. Without one, the
+	// batch end cursor is the best available position.
 	if result.cursor != "" {
 		it.cursor = result.cursor
+	} else if it.endCursor != "" {
+		it.cursor = it.endCursor
 	}
 
-	// For KeysOnly queries, skip entity decoding - just return the key
-	// The Datastore API returns entities without properties for keys-only queries
-	if !it.query.keysOnly {
-		if err := decodeEntity(result.entity, dst); err != nil {
-			return nil, err
-		}
-	}
-
-	return result.key, nil
+	return result, nil
 }
 
-// Cursor returns the cursor for the iterator's current position.
+// Cursor returns the cursor for the iterator's current position: a query
+// started from it resumes with the result after the one Next last returned.
 // API compatible with cloud.google.com/go/datastore.
 func (it *Iterator) Cursor() (Cursor, error) {
 	if it.cursor == "" {
@@ -83,24 +107,80 @@ func (it *Iterator) Cursor() (Cursor, error) {
 	return it.cursor, nil
 }
 
+// LimitReached reports whether the iterator ended because the query's limit was
+// reached rather than because no results remain, telling "page full" apart from
+// "no more data" once Next returns Done. Datastore may report that a limit was
+// reached even when no results remain beyond it.
+func (it *Iterator) LimitReached() bool {
+	return it.done && it.atLimit
+}
+
+// KeyIterator is an iterator over the keys of query results.
+// Use Client.RunKeysOnly to create one.
+type KeyIterator struct {
+	it *Iterator
+}
+
+// Next returns the next key. It returns Done when no more results are available.
+func (ki *KeyIterator) Next() (*Key, error) {
+	return ki.it.Next(nil)
+}
+
+// Cursor returns the cursor for the iterator's current position.
+func (ki *KeyIterator) Cursor() (Cursor, error) {
+	return ki.it.Cursor()
+}
+
+// LimitReached reports whether the iterator ended at the query's limit.
+// See Iterator.LimitReached.
+func (ki *KeyIterator) LimitReached() bool {
+	return ki.it.LimitReached()
+}
+
 // fetch retrieves the next batch of results.
 func (it *Iterator) fetch() error {
+	if err := it.client.validateQuery(it.query); err != nil {
+		return err
+	}
+
 	token, err := auth.AccessToken(it.ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get access token: %w", err)
 	}
 
-	// Build query with current cursor as start
-	q := *it.query
-	if it.cursor != "" {
-		q.startCursor = it.cursor
+	// Build query with the end of the last batch as start. Follow-up batches
+	// resume from there, so limit and offset only cover what is still outstanding.
+	q := *it.client.outQuery(it.query)
+	if it.endCursor != "" {
+		q.startCursor = it.endCursor
+	}
+	if q.limit > 0 {
+		q.limit -= it.fetched
+		if q.limit <= 0 {
+			it.results = nil
+			it.fetchNext = false
+			it.atLimit = true
+			return nil
+		}
+	}
+	if q.offset > 0 {
+		q.offset = max(q.offset-it.skipped, 0)
 	}
 
-	queryObj := buildQueryMap(&q)
+	queryObj, err := buildQueryMap(&q)
+	if err != nil {
+		return err
+	}
 	reqBody := map[string]any{"query": queryObj}
 	if it.client.databaseID != "" {
 		reqBody["databaseId"] = it.client.databaseID
 	}
+	if q.namespace != "" {
+		reqBody["partitionId"] = map[string]any{"namespaceId": q.namespace}
+	}
+	if ro := readOptions(it.ctx, it.query.eventual); ro != nil {
+		reqBody["readOptions"] = ro
+	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -109,7 +189,7 @@ func (it *Iterator) fetch() error {
 
 	// URL-encode project ID to prevent injection attacks
 	reqURL := fmt.Sprintf("%s/projects/%s:runQuery", it.client.baseURL, neturl.PathEscape(it.client.projectID))
-	body, err := doRequest(it.ctx, it.client.logger, reqURL, jsonData, token, it.client.projectID, it.client.databaseID)
+	body, err := it.client.doRequest(it.ctx, reqURL, jsonData, token, it.client.readRetry, unknownEntities)
 	if err != nil {
 		return err
 	}
@@ -117,44 +197,52 @@ func (it *Iterator) fetch() error {
 	var result struct {
 		Batch struct { //nolint:govet // Local anonymous struct for JSON unmarshaling
 			EntityResults []struct {
-				Entity map[string]any `json:"entity"`
-				Cursor string         `json:"cursor"`
+				Entity  map[string]any `json:"entity"`
+				Cursor  string         `json:"cursor"`
+				Version int64          `json:"version,string"`
 			} `json:"entityResults"`
 			MoreResults    string `json:"moreResults"`
 			EndCursor      string `json:"endCursor"`
+			ReadTime       string `json:"readTime"`
 			SkippedResults int    `json:"skippedResults"`
 		} `json:"batch"`
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := unmarshalResponse(body, &result); err != nil {
 		return fmt.Errorf("failed to parse response: %w", err)
 	}
+	recordReadTime(it.ctx, result.Batch.ReadTime)
 
 	// Convert results to iterator format
 	it.results = make([]iteratorResult, 0, len(result.Batch.EntityResults))
 	for _, er := range result.Batch.EntityResults {
-		key, err := keyFromJSON(er.Entity["key"])
+		entity := it.client.inEntity(er.Entity)
+		key, err := keyFromJSON(entity["key"])
 		if err != nil {
 			return err
 		}
 
 		it.results = append(it.results, iteratorResult{
-			key:    key,
-			entity: er.Entity,
-			cursor: Cursor(er.Cursor),
+			key:     key,
+			entity:  entity,
+			cursor:  Cursor(er.Cursor),
+			version: er.Version,
 		})
 	}
 
 	it.index = 0
+	it.fetched += len(it.results)
+	it.skipped += result.Batch.SkippedResults
 
 	// Check if there are more results
 	// MORE_RESULTS_AFTER_LIMIT means we hit the query limit - don't auto-fetch more
 	// NOT_FINISHED and MORE_RESULTS_AFTER_CURSOR mean we should continue fetching
 	moreResults := result.Batch.MoreResults
 	it.fetchNext = moreResults == "NOT_FINISHED" || moreResults == "MORE_RESULTS_AFTER_CURSOR"
+	it.atLimit = moreResults == "MORE_RESULTS_AFTER_LIMIT"
 
 	if result.Batch.EndCursor != "" {
-		it.cursor = Cursor(result.Batch.EndCursor)
+		it.endCursor = Cursor(result.Batch.EndCursor)
 	}
 
 	return nil
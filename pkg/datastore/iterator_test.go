@@ -2,8 +2,12 @@ package datastore_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/codeGROOVE-dev/ds9/pkg/datastore"
@@ -94,3 +98,217 @@ func TestIterator(t *testing.T) {
 		}
 	})
 }
+
+func TestRunKeysOnly(t *testing.T) {
+	const total, pageSize = 100, 30
+
+	requests := 0
+	entity := func(i int) map[string]any {
+		return map[string]any{"key": map[string]any{"path": []any{map[string]any{"kind": "Big", "id": strconv.Itoa(i + 1)}}}}
+	}
+	client := newTestClient(t, pagingHandler(t, total, pageSize, entity, func(req pagedQuery) {
+		requests++
+		if len(req.Query.Projection) != 1 {
+			t.Errorf("expected __key__ projection, got %v", req.Query.Projection)
+		}
+	}))
+
+	it := client.RunKeysOnly(context.Background(), datastore.NewQuery("Big"))
+	count := 0
+	for {
+		key, err := it.Next()
+		if errors.Is(err, datastore.Done) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		count++
+		if key.ID != int64(count) {
+			t.Errorf("expected key ID %d, got %d", count, key.ID)
+		}
+	}
+
+	if count != total {
+		t.Errorf("expected %d keys, got %d", total, count)
+	}
+	if requests != 4 {
+		t.Errorf("expected 4 runQuery requests, got %d", requests)
+	}
+}
+
+func TestIteratorStreamsPages(t *testing.T) {
+	page := func(start int, more, endCursor string) map[string]any {
+		results := make([]any, 0, 3)
+		for i := start; i < start+3; i++ {
+			results = append(results, map[string]any{
+				"entity": map[string]any{
+					"key": map[string]any{
+						"partitionId": map[string]any{"namespaceId": "tenant"},
+						"path":        []any{map[string]any{"kind": "Page", "name": fmt.Sprintf("e%d", i)}},
+					},
+					"properties": map[string]any{
+						"name":  map[string]any{"stringValue": fmt.Sprintf("entity-%d", i)},
+						"count": map[string]any{"integerValue": strconv.Itoa(i)},
+					},
+				},
+			})
+		}
+		return map[string]any{"batch": map[string]any{
+			"entityResults": results,
+			"endCursor":     endCursor,
+			"moreResults":   more,
+		}}
+	}
+
+	type runQuery struct {
+		PartitionID struct {
+			NamespaceID string `json:"namespaceId"`
+		} `json:"partitionId"`
+		Query struct {
+			StartCursor string `json:"startCursor"`
+			Limit       int    `json:"limit"`
+		} `json:"query"`
+	}
+	var mu sync.Mutex
+	var requests []runQuery
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req runQuery
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+
+		switch req.Query.StartCursor {
+		case "":
+			writeJSON(t, w, page(0, "NOT_FINISHED", "page-2"))
+		case "page-2":
+			writeJSON(t, w, page(3, "NO_MORE_RESULTS", "end"))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	q := datastore.NewQuery("Page").Namespace("tenant").Limit(10)
+	it := client.Run(context.Background(), q)
+
+	var names []string
+	for {
+		var e testEntity
+		_, err := it.Next(&e)
+		if errors.Is(err, datastore.Done) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		names = append(names, e.Name)
+	}
+
+	if len(names) != 6 {
+		t.Fatalf("expected 6 entities across both pages, got %d: %v", len(names), names)
+	}
+	for i, name := range names {
+		if want := fmt.Sprintf("entity-%d", i); name != want {
+			t.Errorf("entity %d: expected %q, got %q", i, want, name)
+		}
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 runQuery requests, got %d", len(requests))
+	}
+	second := requests[1]
+	if second.Query.Limit != 7 {
+		t.Errorf("expected follow-up limit 7, got %d", second.Query.Limit)
+	}
+	if second.PartitionID.NamespaceID != "tenant" {
+		t.Errorf("expected follow-up namespace %q, got %q", "tenant", second.PartitionID.NamespaceID)
+	}
+}
+
+func TestIteratorCursorResumesAfterLastResult(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for i := range 5 {
+		key := datastore.NameKey("ResumeKind", fmt.Sprintf("e%d", i), nil)
+		if _, err := client.Put(ctx, key, &testEntity{Name: fmt.Sprintf("e%d", i)}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	query := datastore.NewQuery("ResumeKind").Order("name")
+	it := client.Run(ctx, query)
+	for range 2 {
+		var e testEntity
+		if _, err := it.Next(&e); err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+	}
+	cursor, err := it.Cursor()
+	if err != nil {
+		t.Fatalf("Cursor failed: %v", err)
+	}
+
+	var rest []testEntity
+	if _, err := client.GetAll(ctx, datastore.NewQuery("ResumeKind").Order("name").Start(cursor), &rest); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(rest) != 3 || rest[0].Name != "e2" {
+		t.Errorf("expected to resume at e2 with 3 results, got %+v", rest)
+	}
+}
+
+func TestIteratorLimitReached(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for i := range 5 {
+		if _, err := client.Put(ctx, datastore.NameKey("Page", fmt.Sprintf("p%d", i), nil), &testEntity{Count: int64(i)}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	drain := func(it *datastore.Iterator) int {
+		n := 0
+		for {
+			var e testEntity
+			_, err := it.Next(&e)
+			if errors.Is(err, datastore.Done) {
+				return n
+			}
+			if err != nil {
+				t.Fatalf("Next failed: %v", err)
+			}
+			if it.LimitReached() {
+				t.Error("LimitReached should be false before the iterator ends")
+			}
+			n++
+		}
+	}
+
+	t.Run("PageFull", func(t *testing.T) {
+		it := client.Run(ctx, datastore.NewQuery("Page").Limit(3))
+		if n := drain(it); n != 3 {
+			t.Errorf("expected 3 results, got %d", n)
+		}
+		if !it.LimitReached() {
+			t.Error("expected the iterator to end at the limit")
+		}
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		it := client.Run(ctx, datastore.NewQuery("Page"))
+		if n := drain(it); n != 5 {
+			t.Errorf("expected 5 results, got %d", n)
+		}
+		if it.LimitReached() {
+			t.Error("expected natural exhaustion, not the limit")
+		}
+	})
+}
@@ -62,12 +62,83 @@ func IncompleteKey(kind string, parent *Key) *Key {
 	return k
 }
 
+// KeyFromPath builds a key in namespace from alternating kinds and identifiers,
+// root first, so KeyFromPath("", "Org", "acme", "User", 42) is the key
+// IDKey("User", 42, NameKey("Org", "acme", nil)). Integer identifiers make ID
+// keys and strings make name keys. It returns an error wrapping ErrInvalidKey
+// if path is empty or has an odd length, a kind isn't a non-empty string, or
+// an identifier is of another type or empty.
+func KeyFromPath(namespace string, path ...any) (*Key, error) {
+	if len(path) == 0 || len(path)%2 != 0 {
+		return nil, fmt.Errorf("%w: path must be kind and identifier pairs, got %d elements", ErrInvalidKey, len(path))
+	}
+
+	var key *Key
+	for i := 0; i < len(path); i += 2 {
+		kind, ok := path[i].(string)
+		if !ok || kind == "" {
+			return nil, fmt.Errorf("%w: path element %d must be a non-empty kind, got %v", ErrInvalidKey, i, path[i])
+		}
+		k := &Key{Namespace: namespace, Kind: kind, Parent: key}
+		switch id := path[i+1].(type) {
+		case string:
+			k.Name = id
+		case int:
+			k.ID = int64(id)
+		case int32:
+			k.ID = int64(id)
+		case int64:
+			k.ID = id
+		default:
+			return nil, fmt.Errorf("%w: path element %d must be a string name or integer ID, got %T", ErrInvalidKey, i+1, id)
+		}
+		if k.Incomplete() {
+			return nil, fmt.Errorf("%w: path element %d has an empty identifier", ErrInvalidKey, i+1)
+		}
+		key = k
+	}
+	return key, nil
+}
+
 // Incomplete returns true if the key does not have an ID or Name.
 // API compatible with cloud.google.com/go/datastore.
 func (k *Key) Incomplete() bool {
 	return k.ID == 0 && k.Name == ""
 }
 
+// Root returns the topmost ancestor of k, which is k itself for a key with no
+// parent. The parent and namespace are the Parent and Namespace fields.
+func (k *Key) Root() *Key {
+	if k == nil {
+		return nil
+	}
+	for k.Parent != nil {
+		k = k.Parent
+	}
+	return k
+}
+
+// Valid reports whether the key can be sent to Datastore, returning an error
+// wrapping ErrInvalidKey that describes the problem if not. A key must have a
+// kind, must not set both ID and Name, and every ancestor must be complete.
+// The key itself may be incomplete.
+func (k *Key) Valid() error {
+	if k == nil {
+		return ErrInvalidKey
+	}
+	for curr := k; curr != nil; curr = curr.Parent {
+		switch {
+		case curr.Kind == "":
+			return fmt.Errorf("%w: key has an empty kind: %s", ErrInvalidKey, k)
+		case curr.ID != 0 && curr.Name != "":
+			return fmt.Errorf("%w: key has both ID and Name: %s", ErrInvalidKey, k)
+		case curr != k && curr.Incomplete():
+			return fmt.Errorf("%w: key has an incomplete ancestor: %s", ErrInvalidKey, k)
+		}
+	}
+	return nil
+}
+
 // Equal returns true if this key is equal to the other key.
 // API compatible with cloud.google.com/go/datastore.
 func (k *Key) Equal(other *Key) bool {
@@ -161,6 +232,52 @@ func DecodeKey(encoded string) (*Key, error) {
 	return key, nil
 }
 
+// GobEncode encodes k, with its ancestry and namespace, in the same path form
+// as Encode, so gob-encoded keys don't depend on the layout of Key.
+func (k *Key) GobEncode() ([]byte, error) {
+	return json.Marshal(keyToJSON(k))
+}
+
+// GobDecode decodes a key written by GobEncode.
+func (k *Key) GobDecode(data []byte) error {
+	var keyData any
+	if err := json.Unmarshal(data, &keyData); err != nil {
+		return fmt.Errorf("failed to unmarshal key: %w", err)
+	}
+	key, err := keyFromJSON(keyData)
+	if err != nil {
+		return fmt.Errorf("failed to parse key: %w", err)
+	}
+	*k = *key
+	return nil
+}
+
+// keyJSON is the JSON form of a Key used by MarshalJSON and UnmarshalJSON.
+type keyJSON struct {
+	Kind      string `json:"kind"`
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Parent    *Key   `json:"parent,omitempty"`
+	Namespace string `json:"namespace"`
+}
+
+// MarshalJSON encodes k as a readable object with its parent nested, e.g.
+// {"kind":"Task","id":123,"name":"","parent":{...},"namespace":""}, for use in
+// logs and API responses. Encode gives a compact opaque form instead.
+func (k *Key) MarshalJSON() ([]byte, error) {
+	return json.Marshal(keyJSON{Kind: k.Kind, ID: k.ID, Name: k.Name, Parent: k.Parent, Namespace: k.Namespace})
+}
+
+// UnmarshalJSON decodes a key in the form written by MarshalJSON.
+func (k *Key) UnmarshalJSON(data []byte) error {
+	var v keyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*k = Key{Kind: v.Kind, ID: v.ID, Name: v.Name, Parent: v.Parent, Namespace: v.Namespace}
+	return nil
+}
+
 // keyToJSON converts a Key to its JSON representation.
 // Supports hierarchical keys with parent relationships.
 func keyToJSON(key *Key) map[string]any {
@@ -210,8 +327,17 @@ func keyFromJSON(keyData any) (*Key, error) {
 		return nil, errors.New("invalid key format")
 	}
 
-	path, ok := keyMap["path"].([]any)
-	if !ok || len(path) == 0 {
+	// Decoded JSON holds []any; keys built by keyToJSON hold []map[string]any.
+	var path []any
+	switch p := keyMap["path"].(type) {
+	case []any:
+		path = p
+	case []map[string]any:
+		for _, elem := range p {
+			path = append(path, elem)
+		}
+	}
+	if len(path) == 0 {
 		return nil, errors.New("invalid key path")
 	}
 
@@ -242,14 +368,11 @@ func keyFromJSON(keyData any) (*Key, error) {
 		if name, ok := elemMap["name"].(string); ok {
 			newKey.Name = name
 		} else if idVal, exists := elemMap["id"]; exists {
-			switch id := idVal.(type) {
-			case string:
-				if _, err := fmt.Sscanf(id, "%d", &newKey.ID); err != nil {
-					return nil, fmt.Errorf("invalid ID format: %w", err)
-				}
-			case float64:
-				newKey.ID = int64(id)
+			id, err := parseInteger(idVal)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ID format: %w", err)
 			}
+			newKey.ID = id
 		}
 
 		key = newKey
@@ -1,6 +1,11 @@
 package datastore
 
 import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -115,6 +120,128 @@ func TestKeyIncomplete(t *testing.T) {
 	}
 }
 
+func TestKeyRoot(t *testing.T) {
+	grandparent := NameKey("Org", "acme", nil)
+	grandparent.Namespace = "tenant"
+	parent := NameKey("Team", "core", grandparent)
+	child := IDKey("Member", 7, parent)
+
+	if child.Parent != parent || child.Parent.Parent != grandparent || grandparent.Parent != nil {
+		t.Fatalf("unexpected parent chain: %s", child)
+	}
+	if got := child.Root(); got != grandparent {
+		t.Errorf("child.Root() = %s, want %s", got, grandparent)
+	}
+	if got := parent.Root(); got != grandparent {
+		t.Errorf("parent.Root() = %s, want %s", got, grandparent)
+	}
+	if got := grandparent.Root(); got != grandparent {
+		t.Errorf("grandparent.Root() = %s, want itself", got)
+	}
+	if child.Namespace != "tenant" {
+		t.Errorf("expected child to inherit namespace tenant, got %q", child.Namespace)
+	}
+	if (*Key)(nil).Root() != nil {
+		t.Error("expected nil key to have nil root")
+	}
+}
+
+func TestKeyValid(t *testing.T) {
+	tests := []struct {
+		key     *Key
+		name    string
+		wantErr string
+	}{
+		{
+			name: "valid hierarchical key",
+			key:  NameKey("Child", "c", IDKey("Parent", 1, NameKey("Root", "r", nil))),
+		},
+		{
+			name: "incomplete key with complete parent",
+			key:  IncompleteKey("Child", NameKey("Parent", "p", nil)),
+		},
+		{
+			name:    "nil key",
+			key:     nil,
+			wantErr: "invalid key",
+		},
+		{
+			name:    "empty kind",
+			key:     NameKey("", "name", nil),
+			wantErr: "empty kind",
+		},
+		{
+			name:    "empty parent kind",
+			key:     NameKey("Child", "c", NameKey("", "p", nil)),
+			wantErr: "empty kind",
+		},
+		{
+			name:    "both ID and Name",
+			key:     &Key{Kind: "Kind", ID: 1, Name: "name"},
+			wantErr: "key has both ID and Name",
+		},
+		{
+			name:    "incomplete parent",
+			key:     NameKey("Child", "c", IncompleteKey("Parent", nil)),
+			wantErr: "incomplete ancestor",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.key.Valid()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected valid key, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Fatalf("Expected ErrInvalidKey, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %q", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKeyFromPath(t *testing.T) {
+	nested := NameKey("Doc", "readme", IDKey("Repo", 42, NameKey("Org", "acme", nil)))
+	for k := nested; k != nil; k = k.Parent {
+		k.Namespace = "tenant"
+	}
+
+	key, err := KeyFromPath("tenant", "Org", "acme", "Repo", int64(42), "Doc", "readme")
+	if err != nil {
+		t.Fatalf("KeyFromPath failed: %v", err)
+	}
+	if !key.Equal(nested) {
+		t.Errorf("expected %s, got %s", nested, key)
+	}
+
+	// Untyped integer constants are ints
+	if key, err := KeyFromPath("", "Task", 7); err != nil || !key.Equal(IDKey("Task", 7, nil)) {
+		t.Errorf("expected Task ID key, got %v, %v", key, err)
+	}
+
+	invalid := map[string][]any{
+		"empty":       {},
+		"odd length":  {"Org", "acme", "Repo"},
+		"kind type":   {42, "acme"},
+		"empty kind":  {"", "acme"},
+		"id type":     {"Org", 1.5},
+		"empty name":  {"Org", ""},
+		"zero id":     {"Org", "acme", "Repo", 0},
+		"nil element": {"Org", nil},
+	}
+	for name, path := range invalid {
+		if _, err := KeyFromPath("", path...); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("%s: expected ErrInvalidKey, got %v", name, err)
+		}
+	}
+}
+
 func TestIncompleteKey(t *testing.T) {
 	key := IncompleteKey("TestKind", nil)
 
@@ -234,6 +361,91 @@ func TestKeyEncodeDecode(t *testing.T) {
 	}
 }
 
+func TestKeyJSON(t *testing.T) {
+	parent := NameKey("Project", "apollo", nil)
+	parent.Namespace = "tenant"
+	keys := []*Key{
+		IDKey("Task", 123, nil),
+		NameKey("Task", "launch", parent),
+	}
+
+	for _, key := range keys {
+		data, err := json.Marshal(key)
+		if err != nil {
+			t.Fatalf("Marshal(%s) failed: %v", key, err)
+		}
+		var got *Key
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s) failed: %v", data, err)
+		}
+		if !got.Equal(key) {
+			t.Errorf("round trip of %s gave %s", key, got)
+		}
+	}
+
+	data, err := json.Marshal(keys[0])
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `{"kind":"Task","id":123,"name":"","namespace":""}`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	data, err = json.Marshal(keys[1])
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `{"kind":"Task","id":0,"name":"launch","parent":{"kind":"Project","id":0,"name":"apollo","namespace":"tenant"},"namespace":"tenant"}`
+	if string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	// Keys nested in other values use the same form
+	data, err = json.Marshal(map[string]*Key{"key": keys[0], "none": nil})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `{"key":{"kind":"Task","id":123,"name":"","namespace":""},"none":null}`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+}
+
+func TestKeyGob(t *testing.T) {
+	root := NameKey("Org", "acme", nil)
+	root.Namespace = "tenant"
+	key := IDKey("Member", 42, NameKey("Team", "core", root))
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(key); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	var got Key
+	if err := gob.NewDecoder(&buf).Decode(&got); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !got.Equal(key) {
+		t.Errorf("round trip of %s gave %s", key, &got)
+	}
+
+	keys := []*Key{key, NameKey("Task", "a", nil), IDKey("Task", 7, nil)}
+	buf.Reset()
+	if err := gob.NewEncoder(&buf).Encode(keys); err != nil {
+		t.Fatalf("Encode slice failed: %v", err)
+	}
+	var gotKeys []*Key
+	if err := gob.NewDecoder(&buf).Decode(&gotKeys); err != nil {
+		t.Fatalf("Decode slice failed: %v", err)
+	}
+	if len(gotKeys) != len(keys) {
+		t.Fatalf("expected %d keys, got %d", len(keys), len(gotKeys))
+	}
+	for i := range keys {
+		if !gotKeys[i].Equal(keys[i]) {
+			t.Errorf("key %d: round trip of %s gave %s", i, keys[i], gotKeys[i])
+		}
+	}
+}
+
 func TestDecodeKeyErrors(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,109 @@
+package datastore
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Logger receives the client's diagnostic logs: requests, retries with their
+// status codes and backoff, and transaction begin/commit/rollback.
+// Arguments after the message are alternating keys and values.
+// *slog.Logger satisfies this interface.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// newSlogLogger adapts l for the client's internal use.
+// A nil Logger discards all logs.
+func newSlogLogger(l Logger) *slog.Logger {
+	switch l := l.(type) {
+	case nil:
+		return slog.New(slog.DiscardHandler)
+	case *slog.Logger:
+		if l == nil {
+			return slog.New(slog.DiscardHandler)
+		}
+		return l
+	default:
+		return slog.New(&loggerHandler{logger: l})
+	}
+}
+
+// withRequestIDs returns l with a request_id attribute added to every record
+// logged with a context carrying a request ID.
+func withRequestIDs(l *slog.Logger) *slog.Logger {
+	return slog.New(requestIDHandler{l.Handler()})
+}
+
+// requestIDHandler is a slog.Handler that adds the context's request ID, if
+// any, to each record before passing it on.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+// loggerHandler is a slog.Handler that forwards records to a Logger.
+// Info records are forwarded as Debug, since Logger has no Info level.
+type loggerHandler struct {
+	logger Logger
+	group  string // prefix for attribute keys, including the trailing dot
+	attrs  []any
+}
+
+func (*loggerHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *loggerHandler) Handle(_ context.Context, r slog.Record) error {
+	args := make([]any, len(h.attrs), len(h.attrs)+2*r.NumAttrs())
+	copy(args, h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		args = append(args, h.group+a.Key, a.Value.Any())
+		return true
+	})
+
+	switch {
+	case r.Level >= slog.LevelError:
+		h.logger.Error(r.Message, args...)
+	case r.Level >= slog.LevelWarn:
+		h.logger.Warn(r.Message, args...)
+	default:
+		h.logger.Debug(r.Message, args...)
+	}
+	return nil
+}
+
+func (h *loggerHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	nh := *h
+	nh.attrs = make([]any, len(h.attrs), len(h.attrs)+2*len(attrs))
+	copy(nh.attrs, h.attrs)
+	for _, a := range attrs {
+		nh.attrs = append(nh.attrs, h.group+a.Key, a.Value.Any())
+	}
+	return &nh
+}
+
+func (h *loggerHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	nh := *h
+	nh.group = h.group + name + "."
+	return &nh
+}
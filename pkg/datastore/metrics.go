@@ -0,0 +1,27 @@
+package datastore
+
+import "time"
+
+// MetricsCollector receives request metrics, so they can be exported to a
+// metrics system such as Prometheus without ds9 depending on it.
+// method is the RPC name, e.g. "commit" or "lookup".
+type MetricsCollector interface {
+	// ObserveRequest is called after each HTTP attempt with its status code,
+	// or 0 if no response was received, and how long the attempt took.
+	ObserveRequest(method string, status int, dur time.Duration)
+	// IncRetry is called each time a failed attempt is retried.
+	IncRetry(method string)
+}
+
+// AttemptsObserver may optionally be implemented by a MetricsCollector to
+// learn how many attempts each successful request took, including the first.
+type AttemptsObserver interface {
+	ObserveAttempts(method string, attempts int)
+}
+
+// WithMetrics returns a ClientOption that reports request metrics to m.
+func WithMetrics(m MetricsCollector) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.metrics = m
+	}
+}
@@ -0,0 +1,126 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	neturl "net/url"
+	"strconv"
+
+	"github.com/codeGROOVE-dev/ds9/auth"
+)
+
+// errMigrationConflict reports that an entity was written after it was read
+// for migration, so the migrated entity was not saved.
+var errMigrationConflict = errors.New("entity changed since it was read")
+
+// WithLoadMigration returns a ClientOption that registers fn to migrate
+// entities of kind as Get and GetMulti load them, for example to backfill a
+// new property from an old one. fn receives the stored properties and returns
+// the properties to decode, and whether to save them back. Returning false
+// changes only what this read returns, such as for a default applied in
+// memory. A saved entity is written only if it hasn't been written since it
+// was read, so a concurrent update is never overwritten; if the save fails,
+// the failure is logged and the read still returns the migrated entity.
+func WithLoadMigration(kind string, fn func(PropertyList) (PropertyList, bool)) ClientOption {
+	return func(o *clientOptionsInternal) {
+		if o.loadMigrations == nil {
+			o.loadMigrations = make(map[string]func(PropertyList) (PropertyList, bool))
+		}
+		o.loadMigrations[kind] = fn
+	}
+}
+
+// migrateOnLoad applies the load migration registered for entity's kind, if
+// any, returning the entity to decode. entity must already be mapped by
+// inEntity, and version is the version it was read at.
+func (c *Client) migrateOnLoad(ctx context.Context, entity map[string]any, version int64) (map[string]any, error) {
+	if len(c.loadMigrations) == 0 {
+		return entity, nil
+	}
+	key, err := keyFromJSON(entity["key"])
+	if err != nil {
+		return entity, nil //nolint:nilerr // Decoding reports the bad key
+	}
+	migrate, ok := c.loadMigrations[key.Kind]
+	if !ok {
+		return entity, nil
+	}
+
+	properties, _ := entity["properties"].(map[string]any) //nolint:errcheck // Missing properties mean an empty entity
+	pl, err := propertiesToList(properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load properties for migration: %w", err)
+	}
+	pl, save := migrate(pl)
+	if properties, err = listToProperties(pl); err != nil {
+		return nil, fmt.Errorf("invalid properties from %s load migration: %w", key.Kind, err)
+	}
+
+	migrated := maps.Clone(entity)
+	migrated["properties"] = properties
+	if !save {
+		return migrated, nil
+	}
+	err = c.saveMigrated(ctx, key, properties, version)
+	switch {
+	case errors.Is(err, errMigrationConflict):
+		c.logger.DebugContext(ctx, "migrated entity not saved", "kind", key.Kind, "error", err)
+	case err != nil:
+		c.logger.WarnContext(ctx, "failed to save migrated entity", "kind", key.Kind, "error", err)
+	default:
+		c.logger.DebugContext(ctx, "saved migrated entity", "kind", key.Kind)
+	}
+	return migrated, nil
+}
+
+// saveMigrated upserts an entity's migrated properties if the entity is still
+// at version, returning errMigrationConflict if it isn't.
+func (c *Client) saveMigrated(ctx context.Context, key *Key, properties map[string]any, version int64) error {
+	token, err := auth.AccessToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	entity := map[string]any{
+		"key":        keyToJSON(c.outKey(key)),
+		"properties": properties,
+	}
+	reqBody := map[string]any{
+		"mode": "NON_TRANSACTIONAL",
+		"mutations": []map[string]any{{
+			"upsert":      entity,
+			"baseVersion": strconv.FormatInt(version, 10),
+		}},
+	}
+	if c.databaseID != "" {
+		reqBody["databaseId"] = c.databaseID
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// URL-encode project ID to prevent injection attacks
+	reqURL := fmt.Sprintf("%s/projects/%s:commit", c.baseURL, neturl.PathEscape(c.projectID))
+	body, err := c.doRequest(ctx, reqURL, jsonData, token, c.commitRetry, 1)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		MutationResults []struct {
+			ConflictDetected bool `json:"conflictDetected"`
+		} `json:"mutationResults"`
+	}
+	if err := unmarshalResponse(body, &result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(result.MutationResults) > 0 && result.MutationResults[0].ConflictDetected {
+		return errMigrationConflict
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package datastore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codeGROOVE-dev/ds9/auth"
+	"github.com/codeGROOVE-dev/ds9/pkg/datastore"
+	"github.com/codeGROOVE-dev/ds9/pkg/mock"
+)
+
+func TestWithLoadMigration(t *testing.T) {
+	type userV1 struct {
+		FullName string `datastore:"full_name"`
+	}
+	type userV2 struct {
+		FullName    string `datastore:"full_name"`
+		DisplayName string `datastore:"display_name"`
+	}
+
+	metadataURL, apiURL, cleanup := mock.NewMockServers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	authConfig := &auth.Config{MetadataURL: metadataURL, SkipADC: true}
+
+	plain, err := datastore.NewClient(ctx, "test-project", datastore.WithEndpoint(apiURL), datastore.WithAuth(authConfig))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	migrations := 0
+	backfill := func(pl datastore.PropertyList) (datastore.PropertyList, bool) {
+		migrations++
+		var fullName any
+		for _, p := range pl {
+			switch p.Name {
+			case "display_name":
+				return pl, false
+			case "full_name":
+				fullName = p.Value
+			}
+		}
+		return append(pl, datastore.Property{Name: "display_name", Value: fullName}), true
+	}
+	migrating, err := datastore.NewClient(ctx, "test-project",
+		datastore.WithEndpoint(apiURL),
+		datastore.WithAuth(authConfig),
+		datastore.WithLoadMigration("User", backfill),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	key := datastore.NameKey("User", "ada", nil)
+	if _, err := plain.Put(ctx, key, &userV1{FullName: "Ada Lovelace"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var got userV2
+	if err := migrating.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.DisplayName != "Ada Lovelace" {
+		t.Errorf("expected display_name to be backfilled, got %q", got.DisplayName)
+	}
+
+	// The migrated entity was saved, so a client without the migration sees it
+	var stored userV2
+	if err := plain.Get(ctx, key, &stored); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if stored.DisplayName != "Ada Lovelace" || stored.FullName != "Ada Lovelace" {
+		t.Errorf("expected migrated entity to be persisted, got %+v", stored)
+	}
+
+	var multi []userV2
+	if err := migrating.GetMulti(ctx, []*datastore.Key{key}, &multi); err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	if multi[0].DisplayName != "Ada Lovelace" {
+		t.Errorf("expected GetMulti to decode the migrated entity, got %+v", multi[0])
+	}
+	if migrations != 2 {
+		t.Errorf("expected the migration to run for each load, got %d runs", migrations)
+	}
+}
+
+func TestLoadMigrationOnlySavesChangedEntities(t *testing.T) {
+	type place struct {
+		Owner   *datastore.Key `datastore:"owner"`
+		Name    string         `datastore:"name"`
+		Lat     float64        `datastore:"loc,lat"`
+		Lng     float64        `datastore:"loc,lng"`
+		Version int64          `datastore:",version"`
+	}
+
+	metadataURL, apiURL, cleanup := mock.NewMockServers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	authConfig := &auth.Config{MetadataURL: metadataURL, SkipADC: true}
+
+	plain, err := datastore.NewClient(ctx, "test-project", datastore.WithEndpoint(apiURL), datastore.WithAuth(authConfig))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	key := datastore.NameKey("Place", "london", nil)
+	current := datastore.NameKey("Place", "paris", nil)
+	// The migration renames "London" and, standing in for a concurrent
+	// writer, updates the entity after it was read
+	rename := func(pl datastore.PropertyList) (datastore.PropertyList, bool) {
+		for i, p := range pl {
+			if p.Name == "name" && p.Value == "London" {
+				if _, err := plain.Put(ctx, key, &place{Name: "Londres"}); err != nil {
+					t.Errorf("concurrent Put failed: %v", err)
+				}
+				pl[i].Value = "London, UK"
+				return pl, true
+			}
+		}
+		return pl, false
+	}
+	migrating, err := datastore.NewClient(ctx, "test-project",
+		datastore.WithEndpoint(apiURL),
+		datastore.WithAuth(authConfig),
+		datastore.WithLoadMigration("Place", rename),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	// An entity that needs no migration, including key and geo point
+	// properties, is decoded as stored and not saved
+	owner := datastore.NameKey("User", "ada", nil)
+	if _, err := plain.Put(ctx, current, &place{Owner: owner, Name: "Paris", Lat: 48.9, Lng: 2.35}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	var before, got place
+	if err := plain.Get(ctx, current, &before); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if err := migrating.Get(ctx, current, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !got.Owner.Equal(owner) || got.Lat != 48.9 || got.Lng != 2.35 || got.Version != before.Version {
+		t.Errorf("expected the stored entity at version %d, got %+v", before.Version, got)
+	}
+
+	// A migrated entity isn't saved over a write made since it was read
+	if _, err := plain.Put(ctx, key, &place{Name: "London"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := migrating.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Name != "London, UK" {
+		t.Errorf("expected the migrated entity to be returned, got %q", got.Name)
+	}
+	var stored place
+	if err := plain.Get(ctx, key, &stored); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if stored.Name != "Londres" {
+		t.Errorf("expected the concurrent write to be kept, got %q", stored.Name)
+	}
+}
+
+func TestLoadMigrationWithoutSave(t *testing.T) {
+	metadataURL, apiURL, cleanup := mock.NewMockServers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	authConfig := &auth.Config{MetadataURL: metadataURL, SkipADC: true}
+
+	plain, err := datastore.NewClient(ctx, "test-project", datastore.WithEndpoint(apiURL), datastore.WithAuth(authConfig))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	// Fills in a missing note for readers without writing it back
+	withDefault := func(pl datastore.PropertyList) (datastore.PropertyList, bool) {
+		for _, p := range pl {
+			if p.Name == "notes" {
+				return pl, false
+			}
+		}
+		return append(pl, datastore.Property{Name: "notes", Value: "none"}), false
+	}
+	migrating, err := datastore.NewClient(ctx, "test-project",
+		datastore.WithEndpoint(apiURL),
+		datastore.WithAuth(authConfig),
+		datastore.WithLoadMigration("Task", withDefault),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	key := datastore.NameKey("Task", "a", nil)
+	if _, err := plain.Put(ctx, key, &datastore.PropertyList{{Name: "name", Value: "a"}}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var got testEntity
+	if err := migrating.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Name != "a" || got.Notes != "none" {
+		t.Errorf("expected the migrated entity to be returned, got %+v", got)
+	}
+
+	var stored datastore.PropertyList
+	if err := plain.Get(ctx, key, &stored); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Name != "name" {
+		t.Errorf("expected the stored entity to be unchanged, got %v", stored)
+	}
+}
@@ -10,12 +10,21 @@ import (
 
 // NewMockClient creates a datastore client connected to mock servers with in-memory storage.
 // This is a convenience wrapper for testing.
+// Options such as mock.WithLatency configure the mock server.
 // Returns the client and a cleanup function that should be deferred.
-func NewMockClient(t *testing.T) (client *Client, cleanup func()) {
+func NewMockClient(t *testing.T, opts ...mock.Option) (client *Client, cleanup func()) {
+	t.Helper()
+	return NewMockClientWithStore(t, mock.NewStore(opts...))
+}
+
+// NewMockClientWithStore is like NewMockClient, but connects to mock servers
+// backed by store, so the test can inject faults, such as with
+// store.FailNext, partway through.
+func NewMockClientWithStore(t *testing.T, store *mock.Store) (client *Client, cleanup func()) {
 	t.Helper()
 
 	// Create mock servers
-	metadataURL, apiURL, cleanup := mock.NewMockServers(t)
+	metadataURL, apiURL, cleanup := mock.NewMockServersWithStore(t, store)
 
 	// Create client with mock endpoints
 	var err error
@@ -70,6 +70,11 @@ func NewDelete(k *Key) *Mutation {
 }
 
 // Mutate applies one or more mutations atomically.
+// The returned keys are positionally aligned with muts: incomplete keys are
+// completed with their allocated IDs, and all other mutations (including
+// deletes) return their input key.
+// An insert of an existing key fails with ErrAlreadyExists, and an update of a
+// missing key fails with ErrNoSuchEntity.
 // API compatible with cloud.google.com/go/datastore.
 func (c *Client) Mutate(ctx context.Context, muts ...*Mutation) ([]*Key, error) {
 	ctx = c.withClientConfig(ctx)
@@ -105,7 +110,7 @@ func (c *Client) Mutate(ctx context.Context, muts ...*Mutation) ([]*Key, error)
 				c.logger.ErrorContext(ctx, "nil entity for insert", "index", i)
 				return nil, fmt.Errorf("insert mutation at index %d has nil entity", i)
 			}
-			entity, err := encodeEntity(mut.key, mut.entity)
+			entity, err := c.encodeForWrite(ctx, mut.key, mut.entity)
 			if err != nil {
 				c.logger.ErrorContext(ctx, "failed to encode entity", "index", i, "error", err)
 				return nil, fmt.Errorf("failed to encode entity at index %d: %w", i, err)
@@ -117,7 +122,7 @@ func (c *Client) Mutate(ctx context.Context, muts ...*Mutation) ([]*Key, error)
 				c.logger.ErrorContext(ctx, "nil entity for update", "index", i)
 				return nil, fmt.Errorf("update mutation at index %d has nil entity", i)
 			}
-			entity, err := encodeEntity(mut.key, mut.entity)
+			entity, err := c.encodeForWrite(ctx, mut.key, mut.entity)
 			if err != nil {
 				c.logger.ErrorContext(ctx, "failed to encode entity", "index", i, "error", err)
 				return nil, fmt.Errorf("failed to encode entity at index %d: %w", i, err)
@@ -129,7 +134,7 @@ func (c *Client) Mutate(ctx context.Context, muts ...*Mutation) ([]*Key, error)
 				c.logger.ErrorContext(ctx, "nil entity for upsert", "index", i)
 				return nil, fmt.Errorf("upsert mutation at index %d has nil entity", i)
 			}
-			entity, err := encodeEntity(mut.key, mut.entity)
+			entity, err := c.encodeForWrite(ctx, mut.key, mut.entity)
 			if err != nil {
 				c.logger.ErrorContext(ctx, "failed to encode entity", "index", i, "error", err)
 				return nil, fmt.Errorf("failed to encode entity at index %d: %w", i, err)
@@ -137,7 +142,7 @@ func (c *Client) Mutate(ctx context.Context, muts ...*Mutation) ([]*Key, error)
 			mutMap["upsert"] = entity
 
 		case MutationDelete:
-			mutMap["delete"] = keyToJSON(mut.key)
+			mutMap["delete"] = keyToJSON(c.outKey(mut.key))
 
 		default:
 			c.logger.ErrorContext(ctx, "unknown mutation operation", "index", i, "op", mut.op)
@@ -148,6 +153,7 @@ func (c *Client) Mutate(ctx context.Context, muts ...*Mutation) ([]*Key, error)
 	}
 
 	reqBody := map[string]any{
+		"mode":      "NON_TRANSACTIONAL",
 		"mutations": mutations,
 	}
 	if c.databaseID != "" {
@@ -162,9 +168,12 @@ func (c *Client) Mutate(ctx context.Context, muts ...*Mutation) ([]*Key, error)
 
 	// URL-encode project ID to prevent injection attacks
 	reqURL := fmt.Sprintf("%s/projects/%s:commit", c.baseURL, neturl.PathEscape(c.projectID))
-	body, err := doRequest(ctx, c.logger, reqURL, jsonData, token, c.projectID, c.databaseID)
+	body, err := c.doRequest(ctx, reqURL, jsonData, token, c.commitRetry, len(mutations))
 	if err != nil {
 		c.logger.ErrorContext(ctx, "mutate request failed", "error", err)
+		if hasUpdate(mutations) {
+			return nil, missingEntity(err)
+		}
 		return nil, err
 	}
 
@@ -178,20 +187,26 @@ func (c *Client) Mutate(ctx context.Context, muts ...*Mutation) ([]*Key, error)
 		return nil, fmt.Errorf("failed to parse mutate response: %w", err)
 	}
 
-	// Extract resulting keys
-	keys := make([]*Key, len(resp.MutationResults))
-	for i, result := range resp.MutationResults {
-		if result.Key != nil {
-			key, err := keyFromJSON(result.Key)
-			if err != nil {
-				c.logger.ErrorContext(ctx, "failed to parse key", "index", i, "error", err)
-				return nil, fmt.Errorf("failed to parse key at index %d: %w", i, err)
-			}
-			keys[i] = key
-		} else {
-			// For deletes, use the original key
-			keys[i] = muts[i].key
+	// The commit response holds one result per mutation, in request order.
+	if len(resp.MutationResults) > len(muts) {
+		c.logger.ErrorContext(ctx, "unexpected mutation result count", "results", len(resp.MutationResults), "mutations", len(muts))
+		return nil, fmt.Errorf("commit returned %d mutation results for %d mutations", len(resp.MutationResults), len(muts))
+	}
+
+	// Align resulting keys with the input mutations. Results only carry a key
+	// when the server allocated one, so fall back to the mutation's own key.
+	keys := make([]*Key, len(muts))
+	for i, mut := range muts {
+		if i >= len(resp.MutationResults) || resp.MutationResults[i].Key == nil || mut.op == MutationDelete {
+			keys[i] = mut.key
+			continue
+		}
+		key, err := keyFromJSON(resp.MutationResults[i].Key)
+		if err != nil {
+			c.logger.ErrorContext(ctx, "failed to parse key", "index", i, "error", err)
+			return nil, fmt.Errorf("failed to parse key at index %d: %w", i, err)
 		}
+		keys[i] = c.inKey(key)
 	}
 
 	c.logger.DebugContext(ctx, "mutations applied successfully", "count", len(keys))
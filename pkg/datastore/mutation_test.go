@@ -3,6 +3,7 @@ package datastore_test
 import (
 	"context"
 	"errors"
+	"net/http"
 	"testing"
 
 	"github.com/codeGROOVE-dev/ds9/pkg/datastore"
@@ -178,3 +179,114 @@ func TestMutate(t *testing.T) {
 		}
 	})
 }
+
+func TestMutateReturnsKeysInInputOrder(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	updateKey := datastore.NameKey("MutateOrder", "update", nil)
+	deleteKey := datastore.NameKey("MutateOrder", "delete", nil)
+	for _, k := range []*datastore.Key{updateKey, deleteKey} {
+		if _, err := client.Put(ctx, k, &testEntity{Name: "seed"}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	insertKey := datastore.IncompleteKey("MutateOrder", nil)
+	keys, err := client.Mutate(ctx,
+		datastore.NewInsert(insertKey, &testEntity{Name: "inserted"}),
+		datastore.NewUpdate(updateKey, &testEntity{Name: "updated"}),
+		datastore.NewDelete(deleteKey),
+	)
+	if err != nil {
+		t.Fatalf("Mutate failed: %v", err)
+	}
+
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 keys, got %d", len(keys))
+	}
+	if keys[0].Kind != "MutateOrder" || keys[0].Incomplete() {
+		t.Errorf("expected allocated insert key at index 0, got %v", keys[0])
+	}
+	if !keys[1].Equal(updateKey) {
+		t.Errorf("expected update key at index 1, got %v", keys[1])
+	}
+	if !keys[2].Equal(deleteKey) {
+		t.Errorf("expected delete key at index 2, got %v", keys[2])
+	}
+
+	var got testEntity
+	if err := client.Get(ctx, keys[0], &got); err != nil || got.Name != "inserted" {
+		t.Errorf("expected inserted entity at returned key, got %+v (err=%v)", got, err)
+	}
+}
+
+func TestMutateConflicts(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	t.Run("InsertExisting", func(t *testing.T) {
+		key := datastore.NameKey("MutateTest", "insert-twice", nil)
+		if _, err := client.Mutate(ctx, datastore.NewInsert(key, &testEntity{Name: "first"})); err != nil {
+			t.Fatalf("first insert failed: %v", err)
+		}
+
+		_, err := client.Mutate(ctx, datastore.NewInsert(key, &testEntity{Name: "second"}))
+		if !errors.Is(err, datastore.ErrAlreadyExists) {
+			t.Fatalf("expected ErrAlreadyExists, got %v", err)
+		}
+
+		var result testEntity
+		if err := client.Get(ctx, key, &result); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if result.Name != "first" {
+			t.Errorf("Expected Name 'first', got '%s'", result.Name)
+		}
+	})
+
+	t.Run("UpdateMissing", func(t *testing.T) {
+		key := datastore.NameKey("MutateTest", "update-missing", nil)
+		_, err := client.Mutate(ctx, datastore.NewUpdate(key, &testEntity{Name: "updated"}))
+		if !errors.Is(err, datastore.ErrNoSuchEntity) {
+			t.Fatalf("expected ErrNoSuchEntity, got %v", err)
+		}
+
+		var result testEntity
+		if err := client.Get(ctx, key, &result); !errors.Is(err, datastore.ErrNoSuchEntity) {
+			t.Errorf("expected update to leave no entity, got %v", err)
+		}
+	})
+}
+
+func TestNotFoundOnlyMeansMissingEntityForUpdates(t *testing.T) {
+	// Datastore reports a missing database as NOT_FOUND on every RPC
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(t, w, map[string]any{"error": map[string]any{
+			"code": 404, "status": "NOT_FOUND", "message": "The database (default) does not exist",
+		}})
+	})
+	ctx := context.Background()
+	key := datastore.NameKey("MutateTest", "k", nil)
+
+	if _, err := client.Put(ctx, key, &testEntity{Name: "a"}); err == nil || errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("expected Put to fail with a plain error, got %v", err)
+	}
+	var results []testEntity
+	if _, err := client.GetAll(ctx, datastore.NewQuery("MutateTest"), &results); err == nil || errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("expected GetAll to fail with a plain error, got %v", err)
+	}
+	if err := client.Ping(ctx); !errors.Is(err, datastore.ErrUnavailable) || errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("expected Ping to report ErrUnavailable, got %v", err)
+	}
+
+	// For an update, NOT_FOUND is the entity to update
+	if _, err := client.Mutate(ctx, datastore.NewUpdate(key, &testEntity{Name: "a"})); !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("expected ErrNoSuchEntity from an update, got %v", err)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	neturl "net/url"
 	"reflect"
+	"slices"
+	"sync"
 
 	"github.com/codeGROOVE-dev/ds9/auth"
 )
@@ -18,8 +20,11 @@ const (
 )
 
 // Get retrieves an entity by key and stores it in dst.
-// dst must be a pointer to a struct.
+// dst must be a pointer to a struct, or a *PropertyList to read an entity of
+// unknown schema; GetMulti and GetAll accept slices of PropertyList likewise.
 // Returns ErrNoSuchEntity if the key is not found.
+// An int64 field tagged `datastore:",version"` receives the entity's commit
+// version, as it does from GetMulti, Transaction.Get, GetAll and Iterator.Next.
 func (c *Client) Get(ctx context.Context, key *Key, dst any) error {
 	ctx = c.withClientConfig(ctx)
 
@@ -27,6 +32,10 @@ func (c *Client) Get(ctx context.Context, key *Key, dst any) error {
 		c.logger.WarnContext(ctx, "Get called with nil key")
 		return ErrInvalidKey
 	}
+	if err := key.Valid(); err != nil {
+		c.logger.WarnContext(ctx, "Get called with invalid key", "error", err)
+		return err
+	}
 
 	if dst == nil {
 		return fmt.Errorf("%w: dst cannot be nil", ErrInvalidEntityType)
@@ -41,11 +50,14 @@ func (c *Client) Get(ctx context.Context, key *Key, dst any) error {
 	}
 
 	reqBody := map[string]any{
-		"keys": []map[string]any{keyToJSON(key)},
+		"keys": []map[string]any{keyToJSON(c.outKey(key))},
 	}
 	if c.databaseID != "" {
 		reqBody["databaseId"] = c.databaseID
 	}
+	if ro := readOptions(ctx, false); ro != nil {
+		reqBody["readOptions"] = ro
+	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -55,22 +67,25 @@ func (c *Client) Get(ctx context.Context, key *Key, dst any) error {
 
 	// URL-encode project ID to prevent injection attacks
 	reqURL := fmt.Sprintf("%s/projects/%s:lookup", c.baseURL, neturl.PathEscape(c.projectID))
-	body, err := doRequest(ctx, c.logger, reqURL, jsonData, token, c.projectID, c.databaseID)
+	body, err := c.doRequest(ctx, reqURL, jsonData, token, c.readRetry, 1)
 	if err != nil {
 		c.logger.ErrorContext(ctx, "lookup request failed", "error", err, "kind", key.Kind)
 		return err
 	}
 
 	var result struct {
-		Found []struct {
-			Entity map[string]any `json:"entity"`
+		ReadTime string `json:"readTime"`
+		Found    []struct {
+			Entity  map[string]any `json:"entity"`
+			Version int64          `json:"version,string"`
 		} `json:"found"`
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := unmarshalResponse(body, &result); err != nil {
 		c.logger.ErrorContext(ctx, "failed to parse response", "error", err)
 		return fmt.Errorf("failed to parse response: %w", err)
 	}
+	recordReadTime(ctx, result.ReadTime)
 
 	if len(result.Found) == 0 {
 		c.logger.DebugContext(ctx, "entity not found", "kind", key.Kind, "name", key.Name, "id", key.ID)
@@ -78,7 +93,46 @@ func (c *Client) Get(ctx context.Context, key *Key, dst any) error {
 	}
 
 	c.logger.DebugContext(ctx, "entity retrieved successfully", "kind", key.Kind)
-	return decodeEntity(result.Found[0].Entity, dst)
+	entity, err := c.migrateOnLoad(ctx, c.inEntity(result.Found[0].Entity), result.Found[0].Version)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "load migration failed", "kind", key.Kind, "error", err)
+		return err
+	}
+	if err := decodeEntity(entity, dst); err != nil {
+		return err
+	}
+	setVersion(dst, result.Found[0].Version)
+	return nil
+}
+
+// Exists reports whether an entity with the given key exists.
+// It runs a keys-only query on the key, so no entity properties are
+// transferred. A missing entity returns (false, nil), not ErrNoSuchEntity.
+func (c *Client) Exists(ctx context.Context, key *Key) (bool, error) {
+	ctx = c.withClientConfig(ctx)
+
+	if key == nil {
+		c.logger.WarnContext(ctx, "Exists called with nil key")
+		return false, ErrInvalidKey
+	}
+	if err := key.Valid(); err != nil {
+		c.logger.WarnContext(ctx, "Exists called with invalid key", "error", err)
+		return false, err
+	}
+	if key.Incomplete() {
+		c.logger.WarnContext(ctx, "Exists called with incomplete key", "kind", key.Kind)
+		return false, fmt.Errorf("%w: key is incomplete: %s", ErrInvalidKey, key)
+	}
+
+	q := NewQuery(key.Kind).Namespace(key.Namespace).
+		FilterField("__key__", "=", key).
+		KeysOnly().
+		Limit(1)
+	keys, err := c.AllKeys(ctx, q)
+	if err != nil {
+		return false, err
+	}
+	return len(keys) > 0, nil
 }
 
 // Put stores an entity with the given key.
@@ -90,6 +144,10 @@ func (c *Client) Put(ctx context.Context, key *Key, src any) (*Key, error) {
 		c.logger.WarnContext(ctx, "Put called with nil key")
 		return nil, ErrInvalidKey
 	}
+	if err := key.Valid(); err != nil {
+		c.logger.WarnContext(ctx, "Put called with invalid key", "error", err)
+		return nil, err
+	}
 
 	c.logger.DebugContext(ctx, "putting entity", "kind", key.Kind, "name", key.Name, "id", key.ID)
 
@@ -99,7 +157,7 @@ func (c *Client) Put(ctx context.Context, key *Key, src any) (*Key, error) {
 		return nil, fmt.Errorf("failed to get access token: %w", err)
 	}
 
-	entity, err := encodeEntity(key, src)
+	entity, err := c.encodeForWrite(ctx, key, src)
 	if err != nil {
 		c.logger.ErrorContext(ctx, "failed to encode entity", "error", err, "kind", key.Kind)
 		return nil, err
@@ -121,7 +179,7 @@ func (c *Client) Put(ctx context.Context, key *Key, src any) (*Key, error) {
 
 	// URL-encode project ID to prevent injection attacks
 	reqURL := fmt.Sprintf("%s/projects/%s:commit", c.baseURL, neturl.PathEscape(c.projectID))
-	if _, err := doRequest(ctx, c.logger, reqURL, jsonData, token, c.projectID, c.databaseID); err != nil {
+	if _, err := c.doRequest(ctx, reqURL, jsonData, token, c.commitRetry, 1); err != nil {
 		c.logger.ErrorContext(ctx, "commit request failed", "error", err, "kind", key.Kind)
 		return nil, err
 	}
@@ -137,6 +195,10 @@ func (c *Client) Delete(ctx context.Context, key *Key) error {
 		c.logger.WarnContext(ctx, "Delete called with nil key")
 		return ErrInvalidKey
 	}
+	if err := key.Valid(); err != nil {
+		c.logger.WarnContext(ctx, "Delete called with invalid key", "error", err)
+		return err
+	}
 
 	c.logger.DebugContext(ctx, "deleting entity", "kind", key.Kind, "name", key.Name, "id", key.ID)
 
@@ -148,7 +210,7 @@ func (c *Client) Delete(ctx context.Context, key *Key) error {
 
 	reqBody := map[string]any{
 		"mode":      "NON_TRANSACTIONAL",
-		"mutations": []map[string]any{{"delete": keyToJSON(key)}},
+		"mutations": []map[string]any{{"delete": keyToJSON(c.outKey(key))}},
 	}
 	if c.databaseID != "" {
 		reqBody["databaseId"] = c.databaseID
@@ -162,7 +224,7 @@ func (c *Client) Delete(ctx context.Context, key *Key) error {
 
 	// URL-encode project ID to prevent injection attacks
 	reqURL := fmt.Sprintf("%s/projects/%s:commit", c.baseURL, neturl.PathEscape(c.projectID))
-	if _, err := doRequest(ctx, c.logger, reqURL, jsonData, token, c.projectID, c.databaseID); err != nil {
+	if _, err := c.doRequest(ctx, reqURL, jsonData, token, c.commitRetry, 1); err != nil {
 		c.logger.ErrorContext(ctx, "delete request failed", "error", err, "kind", key.Kind)
 		return err
 	}
@@ -172,12 +234,18 @@ func (c *Client) Delete(ctx context.Context, key *Key) error {
 }
 
 // GetMulti retrieves multiple entities by their keys.
-// dst must be a pointer to a slice of structs.
+// dst must be a pointer to a slice of structs, or a slice with len(keys)
+// elements. Either way, result i holds the entity for keys[i]: a pointer's
+// existing contents are replaced by a fresh slice of len(keys) results, while a
+// sized slice is filled in place. Slots for missing entities are left zero.
 // Returns MultiError with ErrNoSuchEntity for missing keys, or other errors for specific items.
 // This matches the API of cloud.google.com/go/datastore.
 func (c *Client) GetMulti(ctx context.Context, keys []*Key, dst any) error {
 	ctx = c.withClientConfig(ctx)
 	if len(keys) == 0 {
+		if c.emptyBatchNoOp {
+			return nil
+		}
 		c.logger.WarnContext(ctx, "GetMulti called with no keys")
 		return fmt.Errorf("%w: keys cannot be empty", ErrInvalidKey)
 	}
@@ -199,11 +267,18 @@ func (c *Client) GetMulti(ctx context.Context, keys []*Key, dst any) error {
 
 	// Decode into slice
 	dstValue := reflect.ValueOf(dst)
-	if dstValue.Kind() != reflect.Ptr || dstValue.Elem().Kind() != reflect.Slice {
-		return fmt.Errorf("%w: dst must be a pointer to slice", ErrInvalidEntityType)
+	switch {
+	case dstValue.Kind() == reflect.Slice:
+		if dstValue.Len() != len(keys) {
+			return fmt.Errorf("keys and dst slices must have same length: %d vs %d", len(keys), dstValue.Len())
+		}
+	case dstValue.Kind() == reflect.Ptr && dstValue.Elem().Kind() == reflect.Slice:
+		dstValue = dstValue.Elem()
+	default:
+		return fmt.Errorf("%w: dst must be a slice or a pointer to slice", ErrInvalidEntityType)
 	}
 
-	sliceType := dstValue.Elem().Type()
+	sliceType := dstValue.Type()
 	resultSlice := reflect.MakeSlice(sliceType, len(keys), len(keys))
 
 	token, err := auth.AccessToken(ctx)
@@ -252,8 +327,12 @@ func (c *Client) GetMulti(ctx context.Context, keys []*Key, dst any) error {
 		}
 	}
 
-	// Set the result slice
-	dstValue.Elem().Set(resultSlice)
+	// Set the result slice, or fill the caller's sized slice
+	if reflect.ValueOf(dst).Kind() == reflect.Slice {
+		reflect.Copy(dstValue, resultSlice)
+	} else {
+		dstValue.Set(resultSlice)
+	}
 
 	if hasErr {
 		return multiErr
@@ -281,8 +360,10 @@ func (c *Client) getMultiBatch(
 		if key == nil {
 			continue
 		}
-		jsonKeys = append(jsonKeys, keyToJSON(key))
-		keyStr := key.String()
+		// Index by the key as sent, since that is what the response echoes
+		outKey := c.outKey(key)
+		jsonKeys = append(jsonKeys, keyToJSON(outKey))
+		keyStr := outKey.String()
 		idx := batchOffset + k
 		keyMap[keyStr] = append(keyMap[keyStr], idx)
 	}
@@ -293,6 +374,9 @@ func (c *Client) getMultiBatch(
 	if c.databaseID != "" {
 		reqBody["databaseId"] = c.databaseID
 	}
+	if ro := readOptions(ctx, false); ro != nil {
+		reqBody["readOptions"] = ro
+	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -301,7 +385,7 @@ func (c *Client) getMultiBatch(
 	}
 
 	reqURL := fmt.Sprintf("%s/projects/%s:lookup", c.baseURL, neturl.PathEscape(c.projectID))
-	body, err := doRequest(ctx, c.logger, reqURL, jsonData, token, c.projectID, c.databaseID)
+	body, err := c.doRequest(ctx, reqURL, jsonData, token, c.readRetry, len(jsonKeys))
 	if err != nil {
 		c.logger.ErrorContext(ctx, "lookup request failed for batch", "batch_start", batchOffset, "error", err)
 		// Mark all keys in this batch as failed
@@ -315,15 +399,17 @@ func (c *Client) getMultiBatch(
 	}
 
 	var result struct {
-		Found []struct {
-			Entity map[string]any `json:"entity"`
+		ReadTime string `json:"readTime"`
+		Found    []struct {
+			Entity  map[string]any `json:"entity"`
+			Version int64          `json:"version,string"`
 		} `json:"found"`
 		Missing []struct {
 			Entity map[string]any `json:"entity"`
 		} `json:"missing"`
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := unmarshalResponse(body, &result); err != nil {
 		c.logger.ErrorContext(ctx, "failed to parse response", "error", err)
 		// Mark batch as failed
 		for _, idx := range batchIndices {
@@ -333,6 +419,7 @@ func (c *Client) getMultiBatch(
 		}
 		return err
 	}
+	recordReadTime(ctx, result.ReadTime)
 
 	// Process found entities
 	for _, found := range result.Found {
@@ -348,12 +435,21 @@ func (c *Client) getMultiBatch(
 			continue
 		}
 
+		entity, err := c.migrateOnLoad(ctx, c.inEntity(found.Entity), found.Version)
+		if err != nil {
+			c.logger.ErrorContext(ctx, "load migration failed", "error", err)
+			for _, index := range indices {
+				multiErr[index] = err
+			}
+			continue
+		}
 		for _, index := range indices {
-			elem := resultSlice.Index(index)
-			if err := decodeEntity(found.Entity, elem.Addr().Interface()); err != nil {
+			elem := resultSlice.Index(index).Addr().Interface()
+			if err := decodeEntity(entity, elem); err != nil {
 				c.logger.ErrorContext(ctx, "failed to decode entity", "index", index, "error", err)
 				multiErr[index] = err
 			} else {
+				setVersion(elem, found.Version)
 				multiErr[index] = nil // Success
 			}
 		}
@@ -363,9 +459,179 @@ func (c *Client) getMultiBatch(
 	return nil
 }
 
+// DecodeInto decodes an entity fetched by GetMultiFunc into dst, which must be
+// a pointer to a struct. For a missing key it returns ErrNoSuchEntity.
+type DecodeInto func(dst any) error
+
+// GetMultiFunc retrieves multiple entities by their keys, calling fn for each
+// key as soon as its batch of results arrives instead of waiting for all of
+// them. Batches are fetched in parallel, so keys are not reported in input
+// order, but fn is never called concurrently.
+// For a missing key, fn receives a DecodeInto that returns ErrNoSuchEntity.
+// If fn returns an error, no further calls are made and that error is returned.
+func (c *Client) GetMultiFunc(ctx context.Context, keys []*Key, fn func(key *Key, dst DecodeInto) error) error {
+	ctx = c.withClientConfig(ctx)
+	if len(keys) == 0 {
+		if c.emptyBatchNoOp {
+			return nil
+		}
+		c.logger.WarnContext(ctx, "GetMultiFunc called with no keys")
+		return fmt.Errorf("%w: keys cannot be empty", ErrInvalidKey)
+	}
+	for i, key := range keys {
+		if key == nil {
+			c.logger.WarnContext(ctx, "GetMultiFunc called with nil key", "index", i)
+			return fmt.Errorf("%w: key at index %d cannot be nil", ErrInvalidKey, i)
+		}
+	}
+
+	c.logger.DebugContext(ctx, "streaming multiple entities", "count", len(keys))
+
+	token, err := auth.AccessToken(ctx)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "failed to get access token", "error", err)
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex // serializes calls to fn and guards firstErr and the ReadMeta
+		firstErr error
+	)
+	for i := 0; i < len(keys); i += maxLookupBatch {
+		batch := keys[i:min(i+maxLookupBatch, len(keys))]
+		wg.Go(func() {
+			results, readTime, err := c.lookupBatch(ctx, token, batch)
+			mu.Lock()
+			defer mu.Unlock()
+			if firstErr != nil {
+				return
+			}
+			if err != nil {
+				firstErr = err
+				cancel()
+				return
+			}
+			recordReadTime(ctx, readTime)
+			for _, r := range results {
+				if err := fn(r.key, r.decode); err != nil {
+					firstErr = err
+					cancel()
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	c.logger.DebugContext(ctx, "entities streamed successfully", "count", len(keys))
+	return nil
+}
+
+// lookupResult is a key passed to a GetMultiFunc callback with its decoder.
+type lookupResult struct {
+	key    *Key
+	decode DecodeInto
+}
+
+// lookupBatch looks up a batch of keys for GetMultiFunc, returning a result
+// for every key in keys, in order, with missing keys last, and the read time
+// echoed by Datastore. Batches run concurrently, so the caller records the
+// read time.
+func (c *Client) lookupBatch(ctx context.Context, token string, keys []*Key) ([]lookupResult, string, error) {
+	// Send each distinct key once, indexed by the key as sent since that is
+	// what the response echoes
+	jsonKeys := make([]map[string]any, 0, len(keys))
+	keyStrs := make([]string, len(keys))
+	sent := make(map[string]bool, len(keys))
+	for i, key := range keys {
+		outKey := c.outKey(key)
+		keyStrs[i] = outKey.String()
+		if !sent[keyStrs[i]] {
+			sent[keyStrs[i]] = true
+			jsonKeys = append(jsonKeys, keyToJSON(outKey))
+		}
+	}
+
+	reqBody := map[string]any{
+		"keys": jsonKeys,
+	}
+	if c.databaseID != "" {
+		reqBody["databaseId"] = c.databaseID
+	}
+	if ro := readOptions(ctx, false); ro != nil {
+		reqBody["readOptions"] = ro
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "failed to marshal request", "error", err)
+		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	reqURL := fmt.Sprintf("%s/projects/%s:lookup", c.baseURL, neturl.PathEscape(c.projectID))
+	body, err := c.doRequest(ctx, reqURL, jsonData, token, c.readRetry, len(jsonKeys))
+	if err != nil {
+		c.logger.ErrorContext(ctx, "lookup request failed for batch", "error", err)
+		return nil, "", err
+	}
+
+	var result struct {
+		ReadTime string `json:"readTime"`
+		Found    []struct {
+			Entity  map[string]any `json:"entity"`
+			Version int64          `json:"version,string"`
+		} `json:"found"`
+	}
+	if err := unmarshalResponse(body, &result); err != nil {
+		c.logger.ErrorContext(ctx, "failed to parse response", "error", err)
+		return nil, "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	decoders := make(map[string]DecodeInto, len(result.Found))
+	for _, found := range result.Found {
+		key, err := keyFromJSON(found.Entity["key"])
+		if err != nil {
+			c.logger.ErrorContext(ctx, "failed to parse key from response", "error", err)
+			return nil, "", fmt.Errorf("failed to parse key from response: %w", err)
+		}
+		entity, version := c.inEntity(found.Entity), found.Version
+		decoders[key.String()] = func(dst any) error {
+			if err := decodeEntity(entity, dst); err != nil {
+				return err
+			}
+			setVersion(dst, version)
+			return nil
+		}
+	}
+
+	results := make([]lookupResult, 0, len(keys))
+	var missing []lookupResult
+	for i, key := range keys {
+		if decode, ok := decoders[keyStrs[i]]; ok {
+			results = append(results, lookupResult{key: key, decode: decode})
+		} else {
+			missing = append(missing, lookupResult{key: key, decode: decodeMissing})
+		}
+	}
+	return append(results, missing...), result.ReadTime, nil
+}
+
+// decodeMissing is the DecodeInto for keys that were not found.
+func decodeMissing(any) error {
+	return ErrNoSuchEntity
+}
+
 // PutMulti stores multiple entities with their keys.
 // keys and src must have the same length.
-// Returns the keys (same as input) and MultiError if any operations failed.
+// Returns keys aligned with the input, with incomplete keys completed by the IDs
+// the server allocated, and MultiError if any operations failed.
 // This matches the API of cloud.google.com/go/datastore.
 func (c *Client) PutMulti(ctx context.Context, keys []*Key, src any) ([]*Key, error) {
 	ctx = c.withClientConfig(ctx)
@@ -390,6 +656,8 @@ func (c *Client) PutMulti(ctx context.Context, keys []*Key, src any) ([]*Key, er
 
 	multiErr := make(MultiError, len(keys))
 	hasErr := false
+	// Incomplete keys are replaced with the keys the server allocates
+	result := slices.Clone(keys)
 
 	token, err := auth.AccessToken(ctx)
 	if err != nil {
@@ -420,7 +688,7 @@ func (c *Client) PutMulti(ctx context.Context, keys []*Key, src any) ([]*Key, er
 				continue
 			}
 
-			entity, err := encodeEntity(key, v.Index(idx).Interface())
+			entity, err := c.encodeForWrite(ctx, key, v.Index(idx).Interface())
 			if err != nil {
 				c.logger.ErrorContext(ctx, "failed to encode entity", "error", err, "index", idx)
 				multiErr[idx] = err
@@ -453,21 +721,57 @@ func (c *Client) PutMulti(ctx context.Context, keys []*Key, src any) ([]*Key, er
 		}
 
 		reqURL := fmt.Sprintf("%s/projects/%s:commit", c.baseURL, neturl.PathEscape(c.projectID))
-		if _, err := doRequest(ctx, c.logger, reqURL, jsonData, token, c.projectID, c.databaseID); err != nil {
-			c.logger.ErrorContext(ctx, "commit request failed", "error", err)
-			// Mark valid keys in this batch as failed
-			for _, idx := range batchIndices {
-				multiErr[idx] = err
-				hasErr = true
+		body, err := c.doRequest(ctx, reqURL, jsonData, token, c.commitRetry, len(mutations))
+		if err == nil {
+			var allocated []*Key
+			if allocated, err = c.allocatedKeys(body, len(mutations)); err == nil {
+				for j, idx := range batchIndices {
+					if allocated[j] != nil && keys[idx].Incomplete() {
+						result[idx] = allocated[j]
+					}
+				}
+				continue
 			}
 		}
+		c.logger.ErrorContext(ctx, "commit request failed", "error", err)
+		// Mark valid keys in this batch as failed
+		for _, idx := range batchIndices {
+			multiErr[idx] = err
+			hasErr = true
+		}
 	}
 
 	if hasErr {
-		return keys, multiErr
+		return result, multiErr
 	}
 
 	c.logger.DebugContext(ctx, "entities stored successfully", "count", len(keys))
+	return result, nil
+}
+
+// allocatedKeys parses a commit response of n mutations, returning for each
+// mutation the key the server allocated for it, or nil if it allocated none.
+func (c *Client) allocatedKeys(body []byte, n int) ([]*Key, error) {
+	var resp struct {
+		MutationResults []struct {
+			Key map[string]any `json:"key"`
+		} `json:"mutationResults"`
+	}
+	if err := unmarshalResponse(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse commit response: %w", err)
+	}
+
+	keys := make([]*Key, n)
+	for i, r := range resp.MutationResults {
+		if i >= n || r.Key == nil {
+			continue
+		}
+		key, err := keyFromJSON(r.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key at index %d: %w", i, err)
+		}
+		keys[i] = c.inKey(key)
+	}
 	return keys, nil
 }
 
@@ -514,7 +818,7 @@ func (c *Client) DeleteMulti(ctx context.Context, keys []*Key) error {
 			}
 
 			mutations = append(mutations, map[string]any{
-				"delete": keyToJSON(key),
+				"delete": keyToJSON(c.outKey(key)),
 			})
 			batchIndices = append(batchIndices, idx)
 		}
@@ -538,7 +842,7 @@ func (c *Client) DeleteMulti(ctx context.Context, keys []*Key) error {
 		}
 
 		reqURL := fmt.Sprintf("%s/projects/%s:commit", c.baseURL, neturl.PathEscape(c.projectID))
-		if _, err := doRequest(ctx, c.logger, reqURL, jsonData, token, c.projectID, c.databaseID); err != nil {
+		if _, err := c.doRequest(ctx, reqURL, jsonData, token, c.commitRetry, len(mutations)); err != nil {
 			c.logger.ErrorContext(ctx, "delete request failed", "error", err)
 			// Mark valid keys in this batch as failed
 			for _, idx := range batchIndices {
@@ -556,33 +860,71 @@ func (c *Client) DeleteMulti(ctx context.Context, keys []*Key) error {
 	return nil
 }
 
-// DeleteAllByKind deletes all entities of a given kind.
-// This method queries for all keys and then deletes them in batches.
-func (c *Client) DeleteAllByKind(ctx context.Context, kind string) error {
-	ctx = c.withClientConfig(ctx)
-	c.logger.InfoContext(ctx, "deleting all entities by kind", "kind", kind)
-
-	// Query for all keys of this kind
-	q := NewQuery(kind).KeysOnly()
-	keys, err := c.AllKeys(ctx, q)
-	if err != nil {
-		c.logger.ErrorContext(ctx, "failed to query keys", "kind", kind, "error", err)
-		return fmt.Errorf("failed to query keys: %w", err)
+// DeleteAllByKind deletes all entities of a given kind and returns how many
+// were deleted. ns optionally names the namespace to delete from; without it,
+// the client's default namespace is used. See DeleteByQuery.
+func (c *Client) DeleteAllByKind(ctx context.Context, kind string, ns ...string) (int, error) {
+	q := NewQuery(kind)
+	if len(ns) > 0 {
+		q = q.Namespace(ns[0])
 	}
+	return c.DeleteByQuery(ctx, q)
+}
 
-	if len(keys) == 0 {
-		c.logger.InfoContext(ctx, "no entities found to delete", "kind", kind)
+// DeleteByQuery deletes the entities matching q and returns how many were
+// deleted. q is run keys-only, so projection and distinct queries, whose
+// results don't correspond one-to-one with entities, are rejected.
+// Keys are read page by page and deleted in batches of up to 500, so any
+// number of entities can be deleted without holding all of their keys in
+// memory. On error, the count covers the batches already deleted.
+func (c *Client) DeleteByQuery(ctx context.Context, q *Query) (int, error) {
+	ctx = c.withClientConfig(ctx)
+	if len(q.projection) > 0 || len(q.distinctOn) > 0 {
+		return 0, fmt.Errorf("%w: cannot delete by a projection or distinct query", ErrInvalidQuery)
+	}
+	c.logger.InfoContext(ctx, "deleting entities by query", "kind", q.kind, "namespace", q.namespace)
+
+	deleted := 0
+	batch := make([]*Key, 0, maxMutationBatch)
+	flush := func() error {
+		if err := c.DeleteMulti(ctx, batch); err != nil {
+			c.logger.ErrorContext(ctx, "failed to delete entities", "kind", q.kind, "count", len(batch), "error", err)
+			return fmt.Errorf("failed to delete entities: %w", err)
+		}
+		deleted += len(batch)
+		batch = batch[:0]
 		return nil
 	}
 
-	// Delete all keys
-	if err := c.DeleteMulti(ctx, keys); err != nil {
-		c.logger.ErrorContext(ctx, "failed to delete entities", "kind", kind, "count", len(keys), "error", err)
-		return fmt.Errorf("failed to delete entities: %w", err)
+	it := c.RunKeysOnly(ctx, q)
+	for {
+		key, err := it.Next()
+		if errors.Is(err, Done) {
+			break
+		}
+		if err != nil {
+			c.logger.ErrorContext(ctx, "failed to query keys", "kind", q.kind, "error", err)
+			return deleted, fmt.Errorf("failed to query keys: %w", err)
+		}
+		batch = append(batch, key)
+		if len(batch) == maxMutationBatch {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return deleted, err
+		}
 	}
 
-	c.logger.InfoContext(ctx, "deleted all entities", "kind", kind, "count", len(keys))
-	return nil
+	if deleted == 0 {
+		c.logger.InfoContext(ctx, "no entities found to delete", "kind", q.kind)
+		return 0, nil
+	}
+	c.logger.InfoContext(ctx, "deleted entities", "kind", q.kind, "count", deleted)
+	return deleted, nil
 }
 
 // AllocateIDs allocates IDs for incomplete keys.
@@ -630,7 +972,7 @@ func (c *Client) AllocateIDs(ctx context.Context, keys []*Key) ([]*Key, error) {
 		batchKeys := incompleteKeys[i:end]
 		reqKeys := make([]map[string]any, len(batchKeys))
 		for k, key := range batchKeys {
-			reqKeys[k] = keyToJSON(key)
+			reqKeys[k] = keyToJSON(c.outKey(key))
 		}
 
 		reqBody := map[string]any{
@@ -647,7 +989,7 @@ func (c *Client) AllocateIDs(ctx context.Context, keys []*Key) ([]*Key, error) {
 		}
 
 		reqURL := fmt.Sprintf("%s/projects/%s:allocateIds", c.baseURL, neturl.PathEscape(c.projectID))
-		body, err := doRequest(ctx, c.logger, reqURL, jsonData, token, c.projectID, c.databaseID)
+		body, err := c.doRequest(ctx, reqURL, jsonData, token, c.readRetry, len(reqKeys))
 		if err != nil {
 			c.logger.ErrorContext(ctx, "allocateIds request failed", "error", err)
 			return nil, err
@@ -667,7 +1009,7 @@ func (c *Client) AllocateIDs(ctx context.Context, keys []*Key) ([]*Key, error) {
 				c.logger.ErrorContext(ctx, "failed to parse allocated key", "index", i+k, "error", err)
 				return nil, fmt.Errorf("failed to parse allocated key at index %d: %w", i+k, err)
 			}
-			allocatedKeys[i+k] = key
+			allocatedKeys[i+k] = c.inKey(key)
 		}
 	}
 
@@ -681,3 +1023,92 @@ func (c *Client) AllocateIDs(ctx context.Context, keys []*Key) ([]*Key, error) {
 	c.logger.DebugContext(ctx, "IDs allocated successfully", "count", len(allocatedKeys))
 	return result, nil
 }
+
+// ReserveIDs marks the IDs of complete keys as used, so AllocateIDs never hands
+// them out. Use it after writing entities with manually assigned numeric IDs.
+// Every key must have a numeric ID; name keys and incomplete keys are rejected
+// before any request is sent.
+func (c *Client) ReserveIDs(ctx context.Context, keys []*Key) error {
+	ctx = c.withClientConfig(ctx)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	for i, key := range keys {
+		if key == nil || key.ID == 0 {
+			c.logger.WarnContext(ctx, "ReserveIDs called with key lacking a numeric ID", "index", i)
+			return fmt.Errorf("%w: key at index %d must have a numeric ID", ErrInvalidKey, i)
+		}
+	}
+
+	c.logger.DebugContext(ctx, "reserving IDs", "count", len(keys))
+
+	token, err := auth.AccessToken(ctx)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "failed to get access token", "error", err)
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	for i := 0; i < len(keys); i += maxAllocationBatch {
+		end := min(i+maxAllocationBatch, len(keys))
+
+		reqKeys := make([]map[string]any, 0, end-i)
+		for _, key := range keys[i:end] {
+			reqKeys = append(reqKeys, keyToJSON(c.outKey(key)))
+		}
+
+		reqBody := map[string]any{
+			"keys": reqKeys,
+		}
+		if c.databaseID != "" {
+			reqBody["databaseId"] = c.databaseID
+		}
+
+		jsonData, err := json.Marshal(reqBody)
+		if err != nil {
+			c.logger.ErrorContext(ctx, "failed to marshal request", "error", err)
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+
+		reqURL := fmt.Sprintf("%s/projects/%s:reserveIds", c.baseURL, neturl.PathEscape(c.projectID))
+		if _, err := c.doRequest(ctx, reqURL, jsonData, token, c.readRetry, len(reqKeys)); err != nil {
+			c.logger.ErrorContext(ctx, "reserveIds request failed", "error", err)
+			return err
+		}
+	}
+
+	c.logger.DebugContext(ctx, "IDs reserved successfully", "count", len(keys))
+	return nil
+}
+
+// AllocateIDBlock allocates n IDs for kind under parent, which may be nil,
+// and returns the resulting complete keys. The IDs are distinct and never
+// handed out again, but Datastore doesn't guarantee they are contiguous.
+func (c *Client) AllocateIDBlock(ctx context.Context, kind string, n int, parent *Key) ([]*Key, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("block size must be positive, got %d", n)
+	}
+	if kind == "" {
+		return nil, fmt.Errorf("%w: kind is required", ErrInvalidKey)
+	}
+
+	keys := make([]*Key, n)
+	for i := range keys {
+		keys[i] = IncompleteKey(kind, parent)
+	}
+	return c.AllocateIDs(ctx, keys)
+}
+
+// AllocateID allocates an ID for a single incomplete key.
+// A complete key is returned unchanged.
+func (c *Client) AllocateID(ctx context.Context, key *Key) (*Key, error) {
+	if key == nil {
+		return nil, ErrInvalidKey
+	}
+
+	keys, err := c.AllocateIDs(ctx, []*Key{key})
+	if err != nil {
+		return nil, err
+	}
+	return keys[0], nil
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/codeGROOVE-dev/ds9/auth" // Add missing import
 	"github.com/codeGROOVE-dev/ds9/pkg/datastore"
+	"github.com/codeGROOVE-dev/ds9/pkg/mock"
 )
 
 func TestDelete(t *testing.T) {
@@ -311,7 +312,7 @@ func TestDeleteAllByKind(t *testing.T) {
 	}
 
 	// Delete all entities of this kind
-	err := client.DeleteAllByKind(ctx, "DeleteKind")
+	_, err := client.DeleteAllByKind(ctx, "DeleteKind")
 	if err != nil {
 		t.Fatalf("DeleteAllByKind failed: %v", err)
 	}
@@ -335,7 +336,7 @@ func TestDeleteAllByKindEmpty(t *testing.T) {
 	ctx := context.Background()
 
 	// Delete from non-existent kind
-	err := client.DeleteAllByKind(ctx, "NonExistentKind")
+	_, err := client.DeleteAllByKind(ctx, "NonExistentKind")
 	if err != nil {
 		t.Errorf("DeleteAllByKind on empty kind should not error, got: %v", err)
 	}
@@ -436,7 +437,7 @@ func TestDeleteAllByKindWithNoEntities(t *testing.T) {
 	ctx := context.Background()
 
 	// Delete from kind with no entities
-	err := client.DeleteAllByKind(ctx, "NonExistentKind")
+	_, err := client.DeleteAllByKind(ctx, "NonExistentKind")
 	if err != nil {
 		t.Errorf("DeleteAllByKind on empty kind should not error, got: %v", err)
 	}
@@ -459,7 +460,7 @@ func TestDeleteAllByKindWithManyEntities(t *testing.T) {
 	}
 
 	// Delete all
-	err := client.DeleteAllByKind(ctx, "ManyDelete")
+	_, err := client.DeleteAllByKind(ctx, "ManyDelete")
 	if err != nil {
 		t.Fatalf("DeleteAllByKind failed: %v", err)
 	}
@@ -530,7 +531,7 @@ func TestDeleteAllByKindQueryFailure(t *testing.T) {
 		t.Fatalf("NewClient failed: %v", err)
 	}
 
-	err = client.DeleteAllByKind(context.Background(), "TestKind")
+	_, err = client.DeleteAllByKind(context.Background(), "TestKind")
 
 	if err == nil {
 		t.Error("expected error when query fails")
@@ -903,7 +904,7 @@ func TestDeleteAllByKindEmptyBatch(t *testing.T) {
 		t.Fatalf("NewClient failed: %v", err)
 	}
 
-	err = client.DeleteAllByKind(context.Background(), "EmptyKind")
+	_, err = client.DeleteAllByKind(context.Background(), "EmptyKind")
 	if err != nil {
 		t.Logf("DeleteAllByKind with empty batch: %v", err)
 	}
@@ -976,3 +977,144 @@ func TestDeleteMultiMixedResults(t *testing.T) {
 		t.Logf("DeleteMulti with mismatched results: %v", err)
 	}
 }
+
+func TestDeleteAllByKindBatchesAndNamespaces(t *testing.T) {
+	metadataURL, apiURL, cleanup := mock.NewMockServers(t)
+	defer cleanup()
+
+	collector := &fakeCollector{}
+	ctx := context.Background()
+	client, err := datastore.NewClient(ctx, "test-project",
+		datastore.WithEndpoint(apiURL),
+		datastore.WithAuth(&auth.Config{MetadataURL: metadataURL, SkipADC: true}),
+		datastore.WithMetrics(collector),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	const n = 1200
+	keys := make([]*datastore.Key, n)
+	entities := make([]testEntity, n)
+	for i := range keys {
+		keys[i] = datastore.IDKey("Bulk", int64(i+1), nil)
+		entities[i] = testEntity{Name: "bulk", Count: int64(i)}
+	}
+	if _, err := client.PutMulti(ctx, keys, entities); err != nil {
+		t.Fatalf("PutMulti failed: %v", err)
+	}
+	// The same kind in another namespace is left alone unless named
+	other := datastore.NameKey("Bulk", "tenant", nil)
+	other.Namespace = "tenant"
+	if _, err := client.Put(ctx, other, &testEntity{Name: "tenant"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	commits := func() int {
+		collector.mu.Lock()
+		defer collector.mu.Unlock()
+		var count int
+		for _, r := range collector.requests {
+			if r.method == "commit" {
+				count++
+			}
+		}
+		return count
+	}
+	before := commits()
+
+	deleted, err := client.DeleteAllByKind(ctx, "Bulk")
+	if err != nil {
+		t.Fatalf("DeleteAllByKind failed: %v", err)
+	}
+	if deleted != n {
+		t.Errorf("expected %d deleted, got %d", n, deleted)
+	}
+	if got := commits() - before; got != 3 {
+		t.Errorf("expected 3 delete batches, got %d", got)
+	}
+	remaining, err := client.AllKeys(ctx, datastore.NewQuery("Bulk").KeysOnly())
+	if err != nil {
+		t.Fatalf("AllKeys failed: %v", err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("expected no entities left, got %d", len(remaining))
+	}
+
+	deleted, err = client.DeleteAllByKind(ctx, "Bulk", "tenant")
+	if err != nil {
+		t.Fatalf("DeleteAllByKind in namespace failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 deleted in namespace, got %d", deleted)
+	}
+	var e testEntity
+	if err := client.Get(ctx, other, &e); !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("expected namespaced entity to be deleted, got %v", err)
+	}
+}
+
+func TestDeleteByQuery(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 10 {
+		key := datastore.NameKey("Session", fmt.Sprintf("s%d", i), nil)
+		e := &testEntity{Name: fmt.Sprintf("s%d", i), UpdatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if _, err := client.Put(ctx, key, e); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// Sessions s0-s3 were last updated before the cutoff
+	cutoff := base.Add(4 * time.Hour)
+	deleted, err := client.DeleteByQuery(ctx, datastore.NewQuery("Session").FilterField("updated_at", "<", cutoff))
+	if err != nil {
+		t.Fatalf("DeleteByQuery failed: %v", err)
+	}
+	if deleted != 4 {
+		t.Errorf("expected 4 deleted, got %d", deleted)
+	}
+
+	var remaining []testEntity
+	if _, err := client.GetAll(ctx, datastore.NewQuery("Session"), &remaining); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(remaining) != 6 {
+		t.Errorf("expected 6 sessions left, got %d", len(remaining))
+	}
+	for _, e := range remaining {
+		if e.UpdatedAt.Before(cutoff) {
+			t.Errorf("expected %s to be deleted", e.Name)
+		}
+	}
+
+	if _, err := client.DeleteByQuery(ctx, datastore.NewQuery("Session").Project("name")); !errors.Is(err, datastore.ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery for a projection query, got %v", err)
+	}
+	if _, err := client.DeleteByQuery(ctx, datastore.NewQuery("Session").DistinctOn("name")); !errors.Is(err, datastore.ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery for a distinct query, got %v", err)
+	}
+
+	// A filter that can't be encoded must fail the delete, not widen it to the whole kind
+	q := datastore.NewQuery("Session").FilterEntity(datastore.OrFilter(
+		datastore.PropertyFilter{FieldName: "name", Operator: "=", Value: "s9"},
+		datastore.PropertyFilter{FieldName: "name", Operator: "=", Value: make(chan int)},
+	))
+	deleted, err = client.DeleteByQuery(ctx, q)
+	if !errors.Is(err, datastore.ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery for an unencodable filter, got %v", err)
+	}
+	if deleted != 0 {
+		t.Errorf("expected nothing deleted, got %d", deleted)
+	}
+	remaining = nil
+	if _, err := client.GetAll(ctx, datastore.NewQuery("Session"), &remaining); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(remaining) != 6 {
+		t.Errorf("expected 6 sessions left, got %d", len(remaining))
+	}
+}
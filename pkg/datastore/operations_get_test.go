@@ -6,8 +6,10 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/codeGROOVE-dev/ds9/auth"
 	"github.com/codeGROOVE-dev/ds9/pkg/datastore"
@@ -142,6 +144,50 @@ func TestMultiGetPartialResults(t *testing.T) {
 	}
 }
 
+func TestMultiGetReplacesPrefilledDst(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	keys := []*datastore.Key{
+		datastore.NameKey("TestKind", "fresh-1", nil),
+		datastore.NameKey("TestKind", "fresh-3", nil),
+	}
+	if _, err := client.PutMulti(ctx, keys, []testEntity{{Name: "fresh-1"}, {Name: "fresh-3"}}); err != nil {
+		t.Fatalf("PutMulti failed: %v", err)
+	}
+
+	getKeys := []*datastore.Key{
+		keys[0],
+		datastore.NameKey("TestKind", "fresh-2", nil), // doesn't exist
+		keys[1],
+	}
+
+	// Longer than the key list and full of stale values.
+	retrieved := []testEntity{
+		{Name: "stale-a", Count: 1},
+		{Name: "stale-b", Count: 2},
+		{Name: "stale-c", Count: 3},
+		{Name: "stale-d", Count: 4},
+	}
+	err := client.GetMulti(ctx, getKeys, &retrieved)
+	var multiErr datastore.MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+
+	if len(retrieved) != len(getKeys) {
+		t.Fatalf("expected %d results, got %d", len(getKeys), len(retrieved))
+	}
+	if retrieved[0].Name != "fresh-1" || retrieved[2].Name != "fresh-3" {
+		t.Errorf("unexpected results: %+v", retrieved)
+	}
+	if retrieved[1] != (testEntity{}) {
+		t.Errorf("expected zero value for missing entity, got %+v", retrieved[1])
+	}
+}
+
 func TestMultiGetEmptySlices(t *testing.T) {
 	client, cleanup := datastore.NewMockClient(t)
 	defer cleanup()
@@ -1067,3 +1113,541 @@ func TestGetJSONUnmarshalError(t *testing.T) {
 		t.Error("expected error with malformed JSON")
 	}
 }
+
+func TestGetRecordsReadTime(t *testing.T) {
+	const echoed = "2024-05-06T07:08:09.123456Z"
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/test-project:lookup" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(t, w, map[string]any{
+			"found": []any{map[string]any{"entity": map[string]any{
+				"key":        map[string]any{"path": []any{map[string]any{"kind": "Meta", "name": "a"}}},
+				"properties": map[string]any{"name": map[string]any{"stringValue": "a"}},
+			}}},
+			"readTime": echoed,
+		})
+	})
+
+	var meta datastore.ReadMeta
+	ctx := datastore.WithReadMeta(context.Background(), &meta)
+
+	var entity testEntity
+	if err := client.Get(ctx, datastore.NameKey("Meta", "a", nil), &entity); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	want := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	if !meta.ReadTime.Equal(want) {
+		t.Errorf("expected read time %v, got %v", want, meta.ReadTime)
+	}
+}
+
+func TestGetPopulatesVersion(t *testing.T) {
+	type auditEntity struct {
+		Name    string `datastore:"name"`
+		Version int64  `datastore:",version"`
+	}
+
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	key := datastore.NameKey("Audit", "a", nil)
+
+	if _, err := client.Put(ctx, key, &auditEntity{Name: "first", Version: 42}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var first auditEntity
+	if err := client.Get(ctx, key, &first); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if first.Version <= 0 {
+		t.Fatalf("expected version to be populated, got %d", first.Version)
+	}
+	if first.Version == 42 {
+		t.Error("version field should not be stored on Put")
+	}
+
+	if _, err := client.Put(ctx, key, &auditEntity{Name: "second"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var second auditEntity
+	if err := client.Get(ctx, key, &second); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if second.Version <= first.Version {
+		t.Errorf("expected version to increase after update: %d -> %d", first.Version, second.Version)
+	}
+
+	var results []auditEntity
+	if err := client.GetMulti(ctx, []*datastore.Key{key}, &results); err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	if results[0].Version != second.Version {
+		t.Errorf("expected GetMulti version %d, got %d", second.Version, results[0].Version)
+	}
+
+	// Transactional reads and queries report the version too
+	_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		for range 2 { // the second read is served from the transaction's cache
+			var e auditEntity
+			if err := tx.Get(key, &e); err != nil {
+				return err
+			}
+			if e.Version != second.Version {
+				t.Errorf("expected Transaction.Get version %d, got %d", second.Version, e.Version)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+
+	var all []auditEntity
+	if _, err := client.GetAll(ctx, datastore.NewQuery("Audit"), &all); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(all) != 1 || all[0].Version != second.Version {
+		t.Errorf("expected GetAll version %d, got %+v", second.Version, all)
+	}
+
+	it := client.Run(ctx, datastore.NewQuery("Audit"))
+	var next auditEntity
+	if _, err := it.Next(&next); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if next.Version != second.Version {
+		t.Errorf("expected Iterator.Next version %d, got %d", second.Version, next.Version)
+	}
+}
+
+func TestExists(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	key := datastore.NameKey("TestKind", "present", nil)
+	if _, err := client.Put(ctx, key, &testEntity{Name: "present"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	ok, err := client.Exists(ctx, key)
+	if err != nil {
+		t.Fatalf("Exists failed: %v", err)
+	}
+	if !ok {
+		t.Error("expected existing key to be reported as present")
+	}
+
+	ok, err = client.Exists(ctx, datastore.NameKey("TestKind", "absent", nil))
+	if err != nil {
+		t.Fatalf("Exists on missing key should not fail, got: %v", err)
+	}
+	if ok {
+		t.Error("expected missing key to be reported as absent")
+	}
+
+	// Keys with the same kind and name under different parents are distinct
+	child := datastore.NameKey("TestKind", "child", datastore.NameKey("Parent", "a", nil))
+	if _, err := client.Put(ctx, child, &testEntity{Name: "child"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if ok, err := client.Exists(ctx, child); err != nil || !ok {
+		t.Errorf("expected child key to be present, got %v, %v", ok, err)
+	}
+	cousin := datastore.NameKey("TestKind", "child", datastore.NameKey("Parent", "b", nil))
+	if ok, err := client.Exists(ctx, cousin); err != nil || ok {
+		t.Errorf("expected key under another parent to be absent, got %v, %v", ok, err)
+	}
+
+	if _, err := client.Exists(ctx, nil); !errors.Is(err, datastore.ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey for nil key, got: %v", err)
+	}
+	if _, err := client.Exists(ctx, datastore.IncompleteKey("TestKind", nil)); !errors.Is(err, datastore.ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey for incomplete key, got: %v", err)
+	}
+	if _, err := client.Exists(ctx, datastore.NameKey("", "x", nil)); !errors.Is(err, datastore.ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey for invalid key, got: %v", err)
+	}
+}
+
+func TestExistsIsKeysOnly(t *testing.T) {
+	var query map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, ":runQuery") {
+			t.Errorf("expected a runQuery request, got %s", r.URL.Path)
+		}
+		var req struct {
+			Query map[string]any `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		query = req.Query
+		writeJSON(t, w, map[string]any{
+			"batch": map[string]any{
+				"entityResultType": "KEY_ONLY",
+				"entityResults": []map[string]any{{
+					"entity": map[string]any{
+						"key": map[string]any{"path": []any{map[string]any{"kind": "TestKind", "name": "present"}}},
+					},
+				}},
+				"moreResults": "NO_MORE_RESULTS",
+			},
+		})
+	})
+
+	key := datastore.NameKey("TestKind", "present", datastore.IDKey("Parent", 7, nil))
+	ok, err := client.Exists(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Exists failed: %v", err)
+	}
+	if !ok {
+		t.Error("expected key to be reported as present")
+	}
+
+	projection, _ := query["projection"].([]any)
+	if len(projection) != 1 {
+		t.Fatalf("expected a single-property projection, got %v", query["projection"])
+	}
+	if name := projection[0].(map[string]any)["property"].(map[string]any)["name"]; name != "__key__" {
+		t.Errorf("expected keys-only projection, got %v", name)
+	}
+	if limit := query["limit"]; limit != float64(1) {
+		t.Errorf("expected limit 1, got %v", limit)
+	}
+	filter, _ := query["filter"].(map[string]any)["propertyFilter"].(map[string]any)
+	if filter["op"] != "EQUAL" || filter["property"].(map[string]any)["name"] != "__key__" {
+		t.Errorf("expected __key__ equality filter, got %v", query["filter"])
+	}
+	keyValue, ok := filter["value"].(map[string]any)["keyValue"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected the filter value to be a keyValue, got %v", filter["value"])
+	}
+	wantPath := []any{
+		map[string]any{"kind": "Parent", "id": "7"},
+		map[string]any{"kind": "TestKind", "name": "present"},
+	}
+	if !reflect.DeepEqual(keyValue["path"], wantPath) {
+		t.Errorf("expected key path %v, got %v", wantPath, keyValue["path"])
+	}
+}
+
+func TestGetMultiFunc(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	var keys []*datastore.Key
+	for _, name := range []string{"a", "b", "c"} {
+		key := datastore.NameKey("TestKind", name, nil)
+		if _, err := client.Put(ctx, key, &testEntity{Name: name}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	keys = append(keys, datastore.NameKey("TestKind", "missing1", nil), datastore.NameKey("TestKind", "missing2", nil))
+
+	found := make(map[string]string)
+	missing := make(map[string]int)
+	err := client.GetMultiFunc(ctx, keys, func(key *datastore.Key, decode datastore.DecodeInto) error {
+		var e testEntity
+		err := decode(&e)
+		switch {
+		case errors.Is(err, datastore.ErrNoSuchEntity):
+			missing[key.Name]++
+		case err != nil:
+			return err
+		default:
+			found[key.Name] = e.Name
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GetMultiFunc failed: %v", err)
+	}
+
+	if len(found) != 3 {
+		t.Errorf("expected 3 found entities, got %v", found)
+	}
+	for name, got := range found {
+		if got != name {
+			t.Errorf("key %q decoded entity named %q", name, got)
+		}
+	}
+	if len(missing) != 2 || missing["missing1"] != 1 || missing["missing2"] != 1 {
+		t.Errorf("expected each missing key reported once, got %v", missing)
+	}
+}
+
+func TestGetMultiFuncRecordsReadTime(t *testing.T) {
+	const echoed = "2024-05-06T07:08:09.123456Z"
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/test-project:lookup" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		keys, ok := req["keys"].([]any)
+		if !ok {
+			t.Errorf("expected keys in request, got %v", req)
+		}
+		var missing []any
+		for _, k := range keys {
+			missing = append(missing, map[string]any{"entity": map[string]any{"key": k}})
+		}
+		writeJSON(t, w, map[string]any{"missing": missing, "readTime": echoed})
+	})
+
+	// Enough keys for several concurrent lookup batches.
+	keys := make([]*datastore.Key, 2500)
+	for i := range keys {
+		keys[i] = datastore.IDKey("Meta", int64(i+1), nil)
+	}
+
+	var meta datastore.ReadMeta
+	ctx := datastore.WithReadMeta(context.Background(), &meta)
+	err := client.GetMultiFunc(ctx, keys, func(*datastore.Key, datastore.DecodeInto) error { return nil })
+	if err != nil {
+		t.Fatalf("GetMultiFunc failed: %v", err)
+	}
+
+	want := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	if !meta.ReadTime.Equal(want) {
+		t.Errorf("expected read time %v, got %v", want, meta.ReadTime)
+	}
+}
+
+func TestGetMultiFuncCallbackError(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	keys := []*datastore.Key{datastore.NameKey("TestKind", "a", nil), datastore.NameKey("TestKind", "b", nil)}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := client.GetMultiFunc(ctx, keys, func(*datastore.Key, datastore.DecodeInto) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("expected callback error, got: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected no calls after the callback failed, got %d", calls)
+	}
+
+	if err := client.GetMultiFunc(ctx, []*datastore.Key{nil}, nil); !errors.Is(err, datastore.ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey for nil key, got: %v", err)
+	}
+}
+
+func TestGetMultiEmptyBatchNoOp(t *testing.T) {
+	ctx := context.Background()
+	noRequests := func(_ http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s for empty input", r.URL.Path)
+	}
+
+	t.Run("DefaultErrors", func(t *testing.T) {
+		client := newTestClient(t, noRequests)
+		var dst []testEntity
+		if err := client.GetMulti(ctx, nil, &dst); !errors.Is(err, datastore.ErrInvalidKey) {
+			t.Errorf("expected ErrInvalidKey for empty keys, got %v", err)
+		}
+	})
+
+	t.Run("NoOp", func(t *testing.T) {
+		client := newTestClient(t, noRequests, datastore.WithEmptyBatchNoOp())
+		var dst []testEntity
+		if err := client.GetMulti(ctx, nil, &dst); err != nil {
+			t.Errorf("expected nil for empty keys, got %v", err)
+		}
+		err := client.GetMultiFunc(ctx, []*datastore.Key{}, func(*datastore.Key, datastore.DecodeInto) error {
+			t.Error("callback should not be called for empty keys")
+			return nil
+		})
+		if err != nil {
+			t.Errorf("expected nil for empty keys, got %v", err)
+		}
+		keys, err := client.PutMulti(ctx, nil, []testEntity{})
+		if err != nil || keys != nil {
+			t.Errorf("expected nil keys and error for empty PutMulti, got %v, %v", keys, err)
+		}
+	})
+}
+
+func TestGetMultiIntoSizedSlice(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	keys := []*datastore.Key{
+		datastore.NameKey("Cache", "a", nil),
+		datastore.NameKey("Cache", "missing", nil),
+		datastore.NameKey("Cache", "c", nil),
+	}
+	for _, k := range []*datastore.Key{keys[0], keys[2]} {
+		if _, err := client.Put(ctx, k, &testEntity{Name: k.Name}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// Stale contents in the missing slot are cleared
+	dst := make([]testEntity, len(keys))
+	dst[1] = testEntity{Name: "stale"}
+	err := client.GetMulti(ctx, keys, dst)
+
+	var me datastore.MultiError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+	if me[0] != nil || !errors.Is(me[1], datastore.ErrNoSuchEntity) || me[2] != nil {
+		t.Errorf("expected only slot 1 to be missing, got %v", me)
+	}
+	if dst[0].Name != "a" || dst[2].Name != "c" {
+		t.Errorf("expected slots 0 and 2 to be populated, got %q and %q", dst[0].Name, dst[2].Name)
+	}
+	if dst[1] != (testEntity{}) {
+		t.Errorf("expected slot 1 to be zero, got %+v", dst[1])
+	}
+
+	if err := client.GetMulti(ctx, keys, make([]testEntity, 2)); err == nil {
+		t.Error("expected error for a slice shorter than keys")
+	}
+}
+
+func TestGetRejectedCredentials(t *testing.T) {
+	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer metadataServer.Close()
+
+	var apiCalls int
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiCalls++
+	}))
+	defer apiServer.Close()
+
+	client, err := datastore.NewClient(
+		context.Background(),
+		"test-project",
+		datastore.WithEndpoint(apiServer.URL),
+		datastore.WithAuth(&auth.Config{MetadataURL: metadataServer.URL, SkipADC: true}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	var dst testEntity
+	err = client.Get(context.Background(), datastore.NameKey("Test", "k", nil), &dst)
+	if !errors.Is(err, datastore.ErrAuth) {
+		t.Fatalf("expected ErrAuth, got %v", err)
+	}
+	if apiCalls != 0 {
+		t.Errorf("expected no API calls without a token, got %d", apiCalls)
+	}
+}
+
+func TestGetIntoPropertyList(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	updated := time.Date(2024, 3, 10, 9, 30, 0, 0, time.UTC)
+	key := datastore.NameKey("Test", "generic", nil)
+	src := &testEntity{UpdatedAt: updated, Name: "widget", Notes: "long text", Count: 3, Score: 1.5, Active: true}
+	if _, err := client.Put(ctx, key, src); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	want := datastore.PropertyList{
+		{Name: "active", Value: true},
+		{Name: "count", Value: int64(3)},
+		{Name: "name", Value: "widget"},
+		{Name: "notes", Value: "long text", NoIndex: true},
+		{Name: "score", Value: 1.5},
+		{Name: "updated_at", Value: updated},
+	}
+
+	var pl datastore.PropertyList
+	if err := client.Get(ctx, key, &pl); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !reflect.DeepEqual(pl, want) {
+		t.Errorf("Get into PropertyList:\n got %+v\nwant %+v", pl, want)
+	}
+
+	lists := make([]datastore.PropertyList, 1)
+	if err := client.GetMulti(ctx, []*datastore.Key{key}, lists); err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	if !reflect.DeepEqual(lists[0], want) {
+		t.Errorf("GetMulti into PropertyList:\n got %+v\nwant %+v", lists[0], want)
+	}
+
+	var all []datastore.PropertyList
+	if _, err := client.GetAll(ctx, datastore.NewQuery("Test"), &all); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(all) != 1 || !reflect.DeepEqual(all[0], want) {
+		t.Errorf("GetAll into PropertyList:\n got %+v\nwant [%+v]", all, want)
+	}
+
+	// A PropertyList can be written back and read into the struct
+	copyKey := datastore.NameKey("Test", "copy", nil)
+	if _, err := client.Put(ctx, copyKey, pl); err != nil {
+		t.Fatalf("Put of PropertyList failed: %v", err)
+	}
+	var got testEntity
+	if err := client.Get(ctx, copyKey, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !reflect.DeepEqual(&got, src) {
+		t.Errorf("struct from PropertyList:\n got %+v\nwant %+v", got, *src)
+	}
+
+	// Keys and geo points round-trip through a PropertyList both ways
+	type place struct {
+		Owner *datastore.Key `datastore:"owner"`
+		Lat   float64        `datastore:"loc,lat"`
+		Lng   float64        `datastore:"loc,lng"`
+	}
+	owner := datastore.IDKey("User", 5, datastore.NameKey("Org", "acme", nil))
+	placeSrc := &place{Owner: owner, Lat: 51.5, Lng: -0.125}
+	placeKey := datastore.NameKey("Place", "london", nil)
+	if _, err := client.Put(ctx, placeKey, placeSrc); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	var placeList datastore.PropertyList
+	if err := client.Get(ctx, placeKey, &placeList); err != nil {
+		t.Fatalf("Get into PropertyList failed: %v", err)
+	}
+	wantPlace := datastore.PropertyList{
+		{Name: "loc", Value: datastore.GeoPoint{Lat: 51.5, Lng: -0.125}},
+		{Name: "owner", Value: owner},
+	}
+	if !reflect.DeepEqual(placeList, wantPlace) {
+		t.Errorf("Get into PropertyList:\n got %+v\nwant %+v", placeList, wantPlace)
+	}
+
+	placeCopy := datastore.NameKey("Place", "copy", nil)
+	if _, err := client.Put(ctx, placeCopy, placeList); err != nil {
+		t.Fatalf("Put of PropertyList failed: %v", err)
+	}
+	var gotPlace place
+	if err := client.Get(ctx, placeCopy, &gotPlace); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !reflect.DeepEqual(&gotPlace, placeSrc) {
+		t.Errorf("struct from PropertyList:\n got %+v\nwant %+v", gotPlace, *placeSrc)
+	}
+}
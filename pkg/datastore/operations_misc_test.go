@@ -1288,4 +1288,126 @@ func TestAllocateIDs(t *testing.T) {
 			t.Errorf("Complete keys should be unchanged")
 		}
 	})
+
+	t.Run("AllocateSingleKey", func(t *testing.T) {
+		parent := datastore.NameKey("Project", "p", nil)
+		key, err := client.AllocateID(ctx, datastore.IncompleteKey("Task", parent))
+		if err != nil {
+			t.Fatalf("AllocateID failed: %v", err)
+		}
+		if key.Incomplete() {
+			t.Error("Key should be allocated")
+		}
+		if !key.Parent.Equal(parent) {
+			t.Errorf("Expected parent %v, got %v", parent, key.Parent)
+		}
+
+		if _, err := client.AllocateID(ctx, nil); !errors.Is(err, datastore.ErrInvalidKey) {
+			t.Errorf("Expected ErrInvalidKey for nil key, got %v", err)
+		}
+	})
+}
+
+func TestReserveIDs(t *testing.T) {
+	var requests int
+	var reserved []any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/projects/test-project:reserveIds" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req struct {
+			Keys []any `json:"keys"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode failed: %v", err)
+		}
+		reserved = req.Keys
+		writeJSON(t, w, map[string]any{})
+	})
+
+	ctx := context.Background()
+
+	t.Run("RejectsNameKey", func(t *testing.T) {
+		keys := []*datastore.Key{datastore.IDKey("Task", 1, nil), datastore.NameKey("Task", "named", nil)}
+		if err := client.ReserveIDs(ctx, keys); !errors.Is(err, datastore.ErrInvalidKey) {
+			t.Errorf("expected ErrInvalidKey, got %v", err)
+		}
+		if err := client.ReserveIDs(ctx, []*datastore.Key{datastore.IncompleteKey("Task", nil)}); !errors.Is(err, datastore.ErrInvalidKey) {
+			t.Errorf("expected ErrInvalidKey for incomplete key, got %v", err)
+		}
+		if requests != 0 {
+			t.Errorf("expected no requests for invalid keys, got %d", requests)
+		}
+	})
+
+	t.Run("SendsKeys", func(t *testing.T) {
+		keys := []*datastore.Key{datastore.IDKey("Task", 5000, nil), datastore.IDKey("Task", 5001, nil)}
+		if err := client.ReserveIDs(ctx, keys); err != nil {
+			t.Fatalf("ReserveIDs failed: %v", err)
+		}
+		if len(reserved) != 2 {
+			t.Fatalf("expected 2 reserved keys in request, got %d", len(reserved))
+		}
+		got, err := json.Marshal(reserved[1])
+		if err != nil {
+			t.Fatalf("marshal failed: %v", err)
+		}
+		if !strings.Contains(string(got), `"id":"5001"`) {
+			t.Errorf("expected reserved key with id 5001, got %s", got)
+		}
+	})
+}
+
+func TestReserveIDsPreventsCollisions(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	if err := client.ReserveIDs(ctx, []*datastore.Key{datastore.IDKey("Task", 1_000_000, nil)}); err != nil {
+		t.Fatalf("ReserveIDs failed: %v", err)
+	}
+
+	key, err := client.AllocateID(ctx, datastore.IncompleteKey("Task", nil))
+	if err != nil {
+		t.Fatalf("AllocateID failed: %v", err)
+	}
+	if key.ID <= 1_000_000 {
+		t.Errorf("expected allocated ID above the reserved one, got %d", key.ID)
+	}
+}
+
+func TestAllocateIDBlock(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	parent := datastore.NameKey("Counter", "visits", nil)
+	keys, err := client.AllocateIDBlock(ctx, "Shard", 10, parent)
+	if err != nil {
+		t.Fatalf("AllocateIDBlock failed: %v", err)
+	}
+	if len(keys) != 10 {
+		t.Fatalf("expected 10 keys, got %d", len(keys))
+	}
+	seen := make(map[int64]bool)
+	for i, key := range keys {
+		if key.ID == 0 || key.Kind != "Shard" || !key.Parent.Equal(parent) {
+			t.Errorf("key %d = %v, want a Shard ID under %v", i, key, parent)
+		}
+		if seen[key.ID] {
+			t.Errorf("key %d repeats ID %d", i, key.ID)
+		}
+		seen[key.ID] = true
+	}
+
+	for _, n := range []int{0, -1} {
+		if _, err := client.AllocateIDBlock(ctx, "Shard", n, nil); err == nil {
+			t.Errorf("expected an error for a block of %d", n)
+		}
+	}
+	if _, err := client.AllocateIDBlock(ctx, "", 1, nil); !errors.Is(err, datastore.ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey for an empty kind, got %v", err)
+	}
 }
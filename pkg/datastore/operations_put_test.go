@@ -3,9 +3,12 @@ package datastore_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -166,6 +169,26 @@ func TestPutWithNilKey(t *testing.T) {
 	}
 }
 
+func TestInvalidKeyRejectedBeforeRequest(t *testing.T) {
+	client := newTestClient(t, func(_ http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s for an invalid key", r.URL.Path)
+	})
+
+	ctx := context.Background()
+	key := datastore.NameKey("Child", "c", datastore.IncompleteKey("Parent", nil))
+
+	if _, err := client.Put(ctx, key, &testEntity{Name: "test"}); !errors.Is(err, datastore.ErrInvalidKey) {
+		t.Errorf("Put: expected ErrInvalidKey, got %v", err)
+	}
+	var dst testEntity
+	if err := client.Get(ctx, key, &dst); !errors.Is(err, datastore.ErrInvalidKey) {
+		t.Errorf("Get: expected ErrInvalidKey, got %v", err)
+	}
+	if err := client.Delete(ctx, key); !errors.Is(err, datastore.ErrInvalidKey) {
+		t.Errorf("Delete: expected ErrInvalidKey, got %v", err)
+	}
+}
+
 func TestMultiPutWithNilKey(t *testing.T) {
 	client, cleanup := datastore.NewMockClient(t)
 	defer cleanup()
@@ -229,12 +252,12 @@ func TestPutWithInvalidEntity(t *testing.T) {
 	ctx := context.Background()
 
 	type InvalidEntity struct {
-		Map map[string]string // maps not supported
+		Map map[int]string // maps need string keys
 	}
 
 	key := datastore.NameKey("TestKind", "invalid", nil)
 	entity := &InvalidEntity{
-		Map: map[string]string{"key": "value"},
+		Map: map[int]string{1: "value"},
 	}
 
 	_, err := client.Put(ctx, key, entity)
@@ -614,3 +637,207 @@ func TestPutMultiLengthValidation(t *testing.T) {
 		t.Error("expected error with mismatched lengths")
 	}
 }
+
+func TestPutMultiCompletesIncompleteKeys(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	named := datastore.NameKey("TestKind", "named", nil)
+	keys := []*datastore.Key{
+		datastore.IncompleteKey("TestKind", nil),
+		datastore.IncompleteKey("TestKind", nil),
+		named,
+		datastore.IncompleteKey("TestKind", nil),
+	}
+	entities := []testEntity{{Name: "first"}, {Name: "second"}, {Name: "named"}, {Name: "third"}}
+
+	got, err := client.PutMulti(ctx, keys, entities)
+	if err != nil {
+		t.Fatalf("PutMulti failed: %v", err)
+	}
+	if len(got) != len(keys) {
+		t.Fatalf("expected %d keys, got %d", len(keys), len(got))
+	}
+
+	seen := make(map[int64]bool)
+	for _, i := range []int{0, 1, 3} {
+		if got[i].Incomplete() || got[i].ID == 0 {
+			t.Errorf("key %d: expected an allocated ID, got %v", i, got[i])
+			continue
+		}
+		if seen[got[i].ID] {
+			t.Errorf("key %d: ID %d was allocated twice", i, got[i].ID)
+		}
+		seen[got[i].ID] = true
+
+		// Each allocated key is aligned with the entity stored under it
+		var e testEntity
+		if err := client.Get(ctx, got[i], &e); err != nil {
+			t.Fatalf("Get %v failed: %v", got[i], err)
+		}
+		if e.Name != entities[i].Name {
+			t.Errorf("key %d: expected entity %q, got %q", i, entities[i].Name, e.Name)
+		}
+	}
+	if !got[2].Equal(named) {
+		t.Errorf("expected named key unchanged, got %v", got[2])
+	}
+	if !keys[0].Incomplete() {
+		t.Error("PutMulti should not modify the caller's keys")
+	}
+}
+
+func TestPutEntityWithNoProperties(t *testing.T) {
+	type allSkipped struct {
+		Name  string `datastore:"-"`
+		Count int    `datastore:"-"`
+		note  string //nolint:unused // Unexported fields are never stored
+	}
+
+	var commits atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		commits.Add(1)
+		writeJSON(t, w, map[string]any{"mutationResults": []any{map[string]any{}}})
+	}
+	ctx := context.Background()
+	key := datastore.NameKey("TestKind", "empty", nil)
+
+	t.Run("Warns", func(t *testing.T) {
+		logger := &capturingLogger{}
+		client := newTestClient(t, handler, datastore.WithLogger(logger))
+		if _, err := client.Put(ctx, key, &allSkipped{Name: "skipped"}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+
+		logger.mu.Lock()
+		defer logger.mu.Unlock()
+		var warned bool
+		for _, e := range logger.entries {
+			if e.level == "warn" && e.msg == "writing entity with no properties" {
+				warned = true
+				if k, _ := e.arg("key"); k != key.String() {
+					t.Errorf("expected warning for key %s, got %v", key, k)
+				}
+			}
+		}
+		if !warned {
+			t.Error("expected a warning for an entity with no properties")
+		}
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		commits.Store(0)
+		client := newTestClient(t, handler, datastore.WithStrictEmptyEntities())
+		if _, err := client.Put(ctx, key, &allSkipped{}); !errors.Is(err, datastore.ErrEmptyEntity) {
+			t.Errorf("expected ErrEmptyEntity, got %v", err)
+		}
+		if n := commits.Load(); n != 0 {
+			t.Errorf("expected no commit, got %d", n)
+		}
+	})
+}
+
+func TestPutRequestTooLarge(t *testing.T) {
+	var requests atomic.Int32
+	handler := func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		writeJSON(t, w, map[string]any{"mutationResults": []map[string]any{{}}})
+	}
+	ctx := context.Background()
+	key := datastore.NameKey("Test", "big", nil)
+	big := &testEntity{Name: "big", Notes: string(make([]byte, 11*1024*1024))}
+
+	// The default limit is Datastore's own 10MB
+	client := newTestClient(t, handler)
+	_, err := client.Put(ctx, key, big)
+	if !errors.Is(err, datastore.ErrRequestTooLarge) {
+		t.Fatalf("expected ErrRequestTooLarge, got %v", err)
+	}
+	if requests.Load() != 0 {
+		t.Fatalf("expected no request to be sent, got %d", requests.Load())
+	}
+
+	small := &testEntity{Name: "small", Notes: string(make([]byte, 2048))}
+	client = newTestClient(t, handler, datastore.WithMaxRequestSize(1024))
+	_, err = client.PutMulti(ctx, []*datastore.Key{key}, []*testEntity{small})
+	var multiErr datastore.MultiError
+	if !errors.As(err, &multiErr) || !errors.Is(multiErr[0], datastore.ErrRequestTooLarge) {
+		t.Fatalf("expected ErrRequestTooLarge from PutMulti, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "limit is 1024") {
+		t.Errorf("expected error to report the size and limit, got %q", err)
+	}
+	if requests.Load() != 0 {
+		t.Fatalf("expected no request to be sent, got %d", requests.Load())
+	}
+
+	// Zero disables the check
+	client = newTestClient(t, handler, datastore.WithMaxRequestSize(0))
+	if _, err := client.Put(ctx, key, big); err != nil {
+		t.Fatalf("Put without a size limit failed: %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", requests.Load())
+	}
+
+	// Transactional commits are checked too
+	var commits atomic.Int32
+	client = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/projects/test-project:beginTransaction":
+			writeJSON(t, w, map[string]any{"transaction": "tx-1"})
+		case "/projects/test-project:commit":
+			commits.Add(1)
+			writeJSON(t, w, map[string]any{"mutationResults": []map[string]any{{}}})
+		default:
+			writeJSON(t, w, map[string]any{})
+		}
+	}, datastore.WithMaxRequestSize(1024))
+	_, err = client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		_, err := tx.Put(key, small)
+		return err
+	})
+	if !errors.Is(err, datastore.ErrRequestTooLarge) {
+		t.Fatalf("expected ErrRequestTooLarge from a transaction, got %v", err)
+	}
+	if commits.Load() != 0 {
+		t.Errorf("expected no commit to be sent, got %d", commits.Load())
+	}
+}
+
+func TestPutTooManyProperties(t *testing.T) {
+	type tagged struct {
+		Name string   `datastore:"name"`
+		Tags []string `datastore:"tags"`
+	}
+
+	var requests atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		writeJSON(t, w, map[string]any{"mutationResults": []map[string]any{{}}})
+	}, datastore.WithMaxProperties(10))
+	ctx := context.Background()
+	key := datastore.NameKey("Tagged", "t1", nil)
+
+	// Each tag is its own index entry: name plus 10 tags is 11 values
+	over := &tagged{Name: "over", Tags: make([]string, 10)}
+	_, err := client.Put(ctx, key, over)
+	if !errors.Is(err, datastore.ErrTooManyProperties) {
+		t.Fatalf("expected ErrTooManyProperties, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "11 property values, limit is 10") {
+		t.Errorf("expected error to report the count and limit, got %q", err)
+	}
+	if requests.Load() != 0 {
+		t.Fatalf("expected no request to be sent, got %d", requests.Load())
+	}
+
+	under := &tagged{Name: "under", Tags: make([]string, 9)}
+	if _, err := client.Put(ctx, key, under); err != nil {
+		t.Fatalf("Put at the limit failed: %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", requests.Load())
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	neturl "net/url"
 	"reflect"
-	"strconv"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/codeGROOVE-dev/ds9/auth"
 )
@@ -17,18 +19,21 @@ import (
 //
 //nolint:govet // Field order prioritizes logical grouping over memory optimization
 type Query struct {
-	filters     []queryFilter
-	orders      []queryOrder
-	projection  []string
-	distinctOn  []string
-	startCursor Cursor
-	endCursor   Cursor
-	kind        string
-	namespace   string
-	ancestor    *Key
-	limit       int
-	offset      int
-	keysOnly    bool
+	filters       []queryFilter
+	entityFilters []Filter // Added by FilterEntity, ANDed with filters
+	orders        []queryOrder
+	projection    []string
+	distinctOn    []string
+	startCursor   Cursor
+	endCursor     Cursor
+	kind          string
+	namespace     string
+	ancestor      *Key
+	limit         int
+	offset        int
+	keysOnly      bool
+	eventual      bool
+	limitSet      bool
 }
 
 type queryFilter struct {
@@ -37,6 +42,22 @@ type queryFilter struct {
 	operator string
 }
 
+// filterJSON returns the filter as a propertyFilter, or an error wrapping
+// ErrInvalidQuery if its value can't be encoded.
+func (f queryFilter) filterJSON() (map[string]any, error) {
+	encodedVal, err := encodeAny(f.value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: filter on %q: %w", ErrInvalidQuery, f.property, err)
+	}
+	return map[string]any{
+		"propertyFilter": map[string]any{
+			"property": map[string]string{"name": f.property},
+			"op":       f.operator,
+			"value":    encodedVal,
+		},
+	}, nil
+}
+
 type queryOrder struct {
 	property  string
 	direction string // "ASCENDING" or "DESCENDING"
@@ -55,9 +76,22 @@ func (q *Query) KeysOnly() *Query {
 	return q
 }
 
+// EventualConsistency configures the query to read with eventual consistency,
+// trading freshness for lower latency. It has no effect on queries run with a
+// snapshot read time (see WithReadTimeContext) or inside a transaction.
+func (q *Query) EventualConsistency() *Query {
+	q.eventual = true
+	return q
+}
+
 // Limit sets the maximum number of results to return.
+// A negative limit, like leaving the limit unset, means no limit.
+//
+// By default Limit(0) also means no limit. Clients created with WithStrictLimit
+// instead return no results for Limit(0), matching cloud.google.com/go/datastore.
 func (q *Query) Limit(limit int) *Query {
 	q.limit = limit
+	q.limitSet = true
 	return q
 }
 
@@ -97,6 +131,14 @@ func (q *Query) Filter(filterStr string, value any) *Query {
 }
 
 // FilterField adds a property filter to the query with explicit operator.
+// The special field name "__key__" filters on the entity key; its value must
+// be a *Key, e.g. FilterField("__key__", ">", lastKey) to resume a key-ordered scan.
+// On an array property the filter matches entities with any element that
+// satisfies it, so FilterField("tags", "=", "urgent") tests membership; value
+// itself must not be a slice.
+// A dotted fieldName such as "address.city" is passed through as is: it names
+// a property of a struct stored with the flatten option, or a property of a
+// nested entity.
 // API compatible with cloud.google.com/go/datastore.
 func (q *Query) FilterField(fieldName, operator string, value any) *Query {
 	dsOperator, ok := operatorMap[operator]
@@ -115,6 +157,7 @@ func (q *Query) FilterField(fieldName, operator string, value any) *Query {
 
 // Order sets the order in which results are returned.
 // Prefix the property name with "-" for descending order (e.g., "-Created").
+// Order("__key__") orders by entity key, giving a stable order for pagination.
 // API compatible with cloud.google.com/go/datastore.
 func (q *Query) Order(fieldName string) *Query {
 	direction := "ASCENDING"
@@ -142,6 +185,7 @@ func (q *Query) Ancestor(ancestor *Key) *Query {
 }
 
 // Project sets the fields to be projected (returned) in the query results.
+// Struct fields for properties that aren't projected are left zero.
 // API compatible with cloud.google.com/go/datastore.
 func (q *Query) Project(fieldNames ...string) *Query {
 	q.projection = fieldNames
@@ -161,6 +205,9 @@ func (q *Query) Distinct() *Query {
 }
 
 // DistinctOn returns a query that removes duplicates based on the given field names.
+// As Datastore requires, the fields must also be projected and, if the query
+// has an order, be ordered on first; otherwise running the query fails with
+// ErrInvalidQuery.
 // API compatible with cloud.google.com/go/datastore.
 func (q *Query) DistinctOn(fieldNames ...string) *Query {
 	q.distinctOn = fieldNames
@@ -188,28 +235,32 @@ func (q *Query) End(c Cursor) *Query {
 	return q
 }
 
-// buildQueryMap creates a Datastore API query map from a Query object.
-func buildQueryMap(query *Query) map[string]any {
+// buildQueryMap creates a Datastore API query map from a Query object. It
+// fails if a filter value can't be encoded, rather than dropping the filter
+// and widening the query.
+func buildQueryMap(query *Query) (map[string]any, error) {
 	queryMap := map[string]any{
 		"kind": []map[string]any{{"name": query.kind}},
 	}
 
 	// Add filters
-	if len(query.filters) > 0 {
+	if len(query.filters) > 0 || len(query.entityFilters) > 0 {
 		var compositeFilters []map[string]any
 		for _, f := range query.filters {
-			encodedVal, err := encodeAny(f.value)
+			m, err := f.filterJSON()
 			if err != nil {
-				// Skip invalid filters
-				continue
+				return nil, err
+			}
+			compositeFilters = append(compositeFilters, m)
+		}
+		for _, f := range query.entityFilters {
+			m, err := f.filterJSON()
+			if err != nil {
+				return nil, err
+			}
+			if m != nil {
+				compositeFilters = append(compositeFilters, m)
 			}
-			compositeFilters = append(compositeFilters, map[string]any{
-				"propertyFilter": map[string]any{
-					"property": map[string]string{"name": f.property},
-					"op":       f.operator,
-					"value":    encodedVal,
-				},
-			})
 		}
 
 		if len(compositeFilters) == 1 {
@@ -308,195 +359,372 @@ func buildQueryMap(query *Query) map[string]any {
 		queryMap["endCursor"] = string(query.endCursor)
 	}
 
-	return queryMap
+	return queryMap, nil
 }
 
-// AllKeys returns all keys matching the query.
-// This is a convenience method for KeysOnly queries.
-func (c *Client) AllKeys(ctx context.Context, q *Query) ([]*Key, error) {
-	ctx = c.withClientConfig(ctx)
-	if !q.keysOnly {
-		c.logger.WarnContext(ctx, "AllKeys called on non-KeysOnly query")
-		return nil, errors.New("AllKeys requires KeysOnly query")
+// indexedFields holds the schema declared with RegisterIndexedFields: for each
+// registered kind, the set of properties that are indexed.
+var indexedFields = struct {
+	mu    sync.RWMutex
+	kinds map[string]map[string]bool
+}{kinds: make(map[string]map[string]bool)}
+
+// RegisterIndexedFields declares fields as the indexed properties of kind.
+// Once a kind is registered, running or counting a query of that kind that
+// filters or orders on any other property fails with ErrFieldNotIndexed
+// before the request is sent, rather than with a confusing error, or no
+// results, from Datastore. Calling it again for the same kind adds to the
+// fields already registered. Kinds never registered are not checked.
+// The special property "__key__" is always indexed.
+func RegisterIndexedFields(kind string, fields ...string) {
+	indexedFields.mu.Lock()
+	defer indexedFields.mu.Unlock()
+	set := indexedFields.kinds[kind]
+	if set == nil {
+		set = make(map[string]bool, len(fields))
+		indexedFields.kinds[kind] = set
+	}
+	for _, f := range fields {
+		set[f] = true
 	}
+}
 
-	c.logger.DebugContext(ctx, "querying for keys", "kind", q.kind, "limit", q.limit)
-
-	token, err := auth.AccessToken(ctx)
-	if err != nil {
-		c.logger.ErrorContext(ctx, "failed to get access token", "error", err)
-		return nil, fmt.Errorf("failed to get access token: %w", err)
+// checkRegisteredIndexes returns an error wrapping ErrFieldNotIndexed if q
+// filters or orders on a property that RegisterIndexedFields did not declare
+// indexed for q's kind.
+func checkRegisteredIndexes(q *Query) error {
+	indexedFields.mu.RLock()
+	defer indexedFields.mu.RUnlock()
+	indexed, ok := indexedFields.kinds[q.kind]
+	if !ok {
+		return nil
 	}
+	for _, name := range filteredProperties(q) {
+		if name != "__key__" && !indexed[name] {
+			return fmt.Errorf("%w: filter on %q, which kind %q does not register as indexed", ErrFieldNotIndexed, name, q.kind)
+		}
+	}
+	for _, o := range q.orders {
+		if o.property != "__key__" && !indexed[o.property] {
+			return fmt.Errorf("%w: order on %q, which kind %q does not register as indexed", ErrFieldNotIndexed, o.property, q.kind)
+		}
+	}
+	return nil
+}
 
-	query := buildQueryMap(q)
+// inequalityOperators are the filter operators Datastore treats as
+// inequalities, which legacy Datastore allows on only one property per query.
+var inequalityOperators = map[string]bool{
+	"LESS_THAN":             true,
+	"LESS_THAN_OR_EQUAL":    true,
+	"GREATER_THAN":          true,
+	"GREATER_THAN_OR_EQUAL": true,
+	"NOT_EQUAL":             true,
+	"NOT_IN":                true,
+}
 
-	reqBody := map[string]any{"query": query}
-	if c.databaseID != "" {
-		reqBody["databaseId"] = c.databaseID
+// validateQuery checks q for mistakes that Datastore would reject or answer
+// misleadingly, so they fail before the query is sent.
+func (c *Client) validateQuery(q *Query) error {
+	if err := checkRegisteredIndexes(q); err != nil {
+		return err
 	}
-	if q.namespace != "" {
-		reqBody["partitionId"] = map[string]any{"namespaceId": q.namespace}
+	if err := checkDistinctOn(q); err != nil {
+		return err
 	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		c.logger.ErrorContext(ctx, "failed to marshal request", "error", err)
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	if c.inequalities {
+		return checkInequalities(q)
 	}
+	return nil
+}
 
-	// URL-encode project ID to prevent injection attacks
-	reqURL := fmt.Sprintf("%s/projects/%s:runQuery", c.baseURL, neturl.PathEscape(c.projectID))
-	body, err := doRequest(ctx, c.logger, reqURL, jsonData, token, c.projectID, c.databaseID)
-	if err != nil {
-		c.logger.ErrorContext(ctx, "query request failed", "error", err, "kind", q.kind)
-		return nil, err
+// checkInequalities returns an error wrapping ErrMultipleInequality if q has
+// inequality filters on more than one property.
+func checkInequalities(q *Query) error {
+	var names []string
+	for _, f := range q.filters {
+		if inequalityOperators[f.operator] {
+			names = append(names, f.property)
+		}
 	}
-
-	var result struct {
-		Batch struct {
-			EntityResults []struct {
-				Entity map[string]any `json:"entity"`
-			} `json:"entityResults"`
-		} `json:"batch"`
+	for _, f := range q.entityFilters {
+		names = append(names, f.inequalities()...)
 	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		c.logger.ErrorContext(ctx, "failed to parse response", "error", err)
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	for _, name := range names {
+		if name != names[0] {
+			return fmt.Errorf("%w: %q and %q", ErrMultipleInequality, names[0], name)
+		}
 	}
+	return nil
+}
 
-	keys := make([]*Key, 0, len(result.Batch.EntityResults))
-	for _, er := range result.Batch.EntityResults {
-		key, err := keyFromJSON(er.Entity["key"])
-		if err != nil {
-			c.logger.ErrorContext(ctx, "failed to parse key from response", "error", err)
-			return nil, err
+// checkDistinctOn returns an error wrapping ErrInvalidQuery if q's distinct-on
+// fields aren't all projected, or if q is ordered and they aren't the first
+// properties it orders on.
+func checkDistinctOn(q *Query) error {
+	if len(q.distinctOn) == 0 {
+		return nil
+	}
+	distinct := make(map[string]bool, len(q.distinctOn))
+	for _, name := range q.distinctOn {
+		if !slices.Contains(q.projection, name) {
+			return fmt.Errorf("%w: distinct-on field %q must also be projected", ErrInvalidQuery, name)
 		}
-		keys = append(keys, key)
+		distinct[name] = true
 	}
-
-	c.logger.DebugContext(ctx, "query completed successfully", "kind", q.kind, "keys_found", len(keys))
-	return keys, nil
+	for i, o := range q.orders[:min(len(q.orders), len(distinct))] {
+		if !distinct[o.property] {
+			return fmt.Errorf("%w: order %d is on %q, but distinct-on fields must be ordered on first", ErrInvalidQuery, i+1, o.property)
+		}
+	}
+	return nil
 }
 
-// GetAll retrieves all entities matching the query and stores them in dst.
-// dst must be a pointer to a slice of structs, or nil for KeysOnly queries.
-// Returns the keys of the retrieved entities and any error.
-// This matches the API of cloud.google.com/go/datastore.
-func (c *Client) GetAll(ctx context.Context, query *Query, dst any) ([]*Key, error) {
-	ctx = c.withClientConfig(ctx)
-	c.logger.DebugContext(ctx, "querying for entities", "kind", query.kind, "limit", query.limit)
-
-	token, err := auth.AccessToken(ctx)
-	if err != nil {
-		c.logger.ErrorContext(ctx, "failed to get access token", "error", err)
-		return nil, fmt.Errorf("failed to get access token: %w", err)
+// filteredProperties returns the properties q filters on, including those in
+// filters added with FilterEntity.
+func filteredProperties(q *Query) []string {
+	filtered := make([]string, 0, len(q.filters))
+	for _, f := range q.filters {
+		filtered = append(filtered, f.property)
 	}
+	for _, f := range q.entityFilters {
+		filtered = append(filtered, f.properties()...)
+	}
+	return filtered
+}
 
-	queryObj := buildQueryMap(query)
-
-	reqBody := map[string]any{"query": queryObj}
-	if c.databaseID != "" {
-		reqBody["databaseId"] = c.databaseID
+// checkIndexed returns an error wrapping ErrUnindexedProperty if q filters or
+// orders on a property that elemType, the type results decode into, stores
+// unindexed.
+func checkIndexed(q *Query, elemType reflect.Type) error {
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
 	}
-	if query.namespace != "" {
-		reqBody["partitionId"] = map[string]any{"namespaceId": query.namespace}
+	if elemType.Kind() != reflect.Struct {
+		return nil
 	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		c.logger.ErrorContext(ctx, "failed to marshal request", "error", err)
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	if len(q.filters) == 0 && len(q.entityFilters) == 0 && len(q.orders) == 0 {
+		return nil
 	}
 
-	// URL-encode project ID to prevent injection attacks
-	reqURL := fmt.Sprintf("%s/projects/%s:runQuery", c.baseURL, neturl.PathEscape(c.projectID))
-	body, err := doRequest(ctx, c.logger, reqURL, jsonData, token, c.projectID, c.databaseID)
-	if err != nil {
-		c.logger.ErrorContext(ctx, "query request failed", "error", err, "kind", query.kind)
-		return nil, err
+	unindexed := unindexedProperties(elemType)
+	for _, name := range filteredProperties(q) {
+		if unindexed[name] {
+			return fmt.Errorf("%w: filter on %q, which is tagged noindex", ErrUnindexedProperty, name)
+		}
 	}
-
-	var result struct {
-		Batch struct {
-			EntityResults []struct {
-				Entity map[string]any `json:"entity"`
-			} `json:"entityResults"`
-		} `json:"batch"`
+	for _, o := range q.orders {
+		if unindexed[o.property] {
+			return fmt.Errorf("%w: order on %q, which is tagged noindex", ErrUnindexedProperty, o.property)
+		}
 	}
+	return nil
+}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		c.logger.ErrorContext(ctx, "failed to parse response", "error", err)
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+// AllKeys returns all keys matching the query.
+// This is a convenience method for KeysOnly queries, equivalent to GetAll
+// with a nil dst.
+// Results spanning multiple batches are fetched by following the batch cursor.
+func (c *Client) AllKeys(ctx context.Context, q *Query) ([]*Key, error) {
+	if !q.keysOnly {
+		c.logger.WarnContext(ctx, "AllKeys called on non-KeysOnly query")
+		return nil, errors.New("AllKeys requires KeysOnly query")
 	}
+	return c.GetAll(ctx, q, nil)
+}
+
+// GetAll retrieves all entities matching the query and stores them in dst.
+// dst must be a pointer to a slice of structs. KeysOnly queries return no
+// properties, so dst is only filled if it is a *[]*Key, which receives the
+// keys; any other dst, including nil, is left untouched.
+// Results spanning multiple batches are fetched by following the batch cursor
+// until the query is exhausted or its limit is reached.
+// Returns the keys of the retrieved entities and any error. Filtering or
+// ordering on a property that dst's struct type tags noindex fails with
+// ErrUnindexedProperty, since Datastore would silently match nothing.
+// Decoding is best-effort: if some entities fail to decode, the rest are
+// still stored in dst and all keys are returned, along with a MultiError
+// indexed like the keys and dst that is nil for each entity that decoded.
+// This matches the API of cloud.google.com/go/datastore.
+func (c *Client) GetAll(ctx context.Context, query *Query, dst any) ([]*Key, error) {
+	ctx = c.withClientConfig(ctx)
+	c.logger.DebugContext(ctx, "querying for entities", "kind", query.kind, "limit", query.limit)
 
 	// For KeysOnly queries, skip entity decoding - just return keys
 	// The Datastore API returns entities without properties for keys-only queries
-	if query.keysOnly {
-		keys := make([]*Key, 0, len(result.Batch.EntityResults))
-		for _, er := range result.Batch.EntityResults {
-			key, err := keyFromJSON(er.Entity["key"])
-			if err != nil {
-				c.logger.ErrorContext(ctx, "failed to parse key from response", "error", err)
-				return nil, err
-			}
-			keys = append(keys, key)
+	var v, slice reflect.Value
+	var elemType reflect.Type
+	keysDst, _ := dst.(*[]*Key) //nolint:errcheck // Any other dst is ignored for KeysOnly queries
+	if !query.keysOnly {
+		// Verify dst is a pointer to slice
+		v = reflect.ValueOf(dst)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+			return nil, fmt.Errorf("%w: dst must be a pointer to slice", ErrInvalidEntityType)
+		}
+		slice = reflect.MakeSlice(v.Elem().Type(), 0, 0)
+		elemType = v.Elem().Type().Elem()
+		if err := checkIndexed(query, elemType); err != nil {
+			c.logger.WarnContext(ctx, "query uses an unindexed property", "kind", query.kind, "error", err)
+			return nil, err
 		}
-		c.logger.DebugContext(ctx, "keys-only query completed successfully", "kind", query.kind, "keys_found", len(keys))
-		return keys, nil
-	}
-
-	// Verify dst is a pointer to slice
-	v := reflect.ValueOf(dst)
-	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
-		return nil, fmt.Errorf("%w: dst must be a pointer to slice", ErrInvalidEntityType)
 	}
 
-	sliceType := v.Elem().Type()
-	elemType := sliceType.Elem()
-
-	// Create new slice of correct size
-	slice := reflect.MakeSlice(sliceType, 0, len(result.Batch.EntityResults))
-	keys := make([]*Key, 0, len(result.Batch.EntityResults))
-
-	for _, er := range result.Batch.EntityResults {
-		// Extract key
-		key, err := keyFromJSON(er.Entity["key"])
+	var keys []*Key
+	var decodeErrs MultiError
+	hasErr := false
+	it := c.Run(ctx, query)
+	for {
+		r, err := it.nextResult()
+		if errors.Is(err, Done) {
+			break
+		}
 		if err != nil {
-			c.logger.ErrorContext(ctx, "failed to parse key from response", "error", err)
+			c.logger.ErrorContext(ctx, "query request failed", "error", err, "kind", query.kind)
 			return nil, err
 		}
-		keys = append(keys, key)
+		keys = append(keys, r.key)
+		if query.keysOnly {
+			continue
+		}
 
-		// Decode entity
+		// Decode entity, keeping its slot on failure so dst stays aligned
+		// with keys
 		elem := reflect.New(elemType).Elem()
-		if err := decodeEntity(er.Entity, elem.Addr().Interface()); err != nil {
-			c.logger.ErrorContext(ctx, "failed to decode entity", "error", err)
-			return nil, err
+		err = decodeEntity(r.entity, elem.Addr().Interface())
+		if err != nil {
+			c.logger.ErrorContext(ctx, "failed to decode entity", "index", len(keys)-1, "error", err)
+			hasErr = true
+		} else {
+			setVersion(elem.Addr().Interface(), r.version)
 		}
+		decodeErrs = append(decodeErrs, err)
 		slice = reflect.Append(slice, elem)
 	}
 
+	if query.keysOnly {
+		if keysDst != nil {
+			*keysDst = keys
+		}
+		c.logger.DebugContext(ctx, "keys-only query completed successfully", "kind", query.kind, "keys_found", len(keys))
+		return keys, nil
+	}
+
 	v.Elem().Set(slice)
+	if hasErr {
+		return keys, decodeErrs
+	}
 	c.logger.DebugContext(ctx, "query completed successfully", "kind", query.kind, "entities_found", len(keys))
 	return keys, nil
 }
 
+// GetAllTyped retrieves all entities matching the query, decoding each into a
+// new value of the struct type registered for the query's kind with
+// WithKindType. The results are pointers to that type, in query order.
+// It returns an error if no struct type is registered for the kind.
+func (c *Client) GetAllTyped(ctx context.Context, q *Query) ([]any, error) {
+	t, ok := c.kindTypes[q.kind]
+	if !ok {
+		return nil, fmt.Errorf("no type registered for kind %q", q.kind)
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: type registered for kind %q must be a struct", ErrInvalidEntityType, q.kind)
+	}
+
+	dst := reflect.New(reflect.SliceOf(t))
+	if _, err := c.GetAll(ctx, q, dst.Interface()); err != nil {
+		return nil, err
+	}
+
+	slice := dst.Elem()
+	results := make([]any, slice.Len())
+	for i := range results {
+		results[i] = slice.Index(i).Addr().Interface()
+	}
+	return results, nil
+}
+
+// Sample retrieves up to n entities of kind into dst, for quick data inspection
+// in tooling. dst must be a pointer to a slice of structs.
+// The sample is simply the first n entities in key order, read with eventual
+// consistency; it is not a uniform random sample of the kind.
+func (c *Client) Sample(ctx context.Context, kind string, n int, dst any) error {
+	if n <= 0 {
+		return fmt.Errorf("sample size must be positive, got %d", n)
+	}
+	_, err := c.GetAll(ctx, NewQuery(kind).Limit(n).EventualConsistency(), dst)
+	return err
+}
+
 // Count returns the number of entities matching the query.
+// If the server stops counting before it finishes, Count resumes from the
+// returned cursor and sums the partial counts.
 // Deprecated: Use aggregation queries with RunAggregationQuery instead.
 // API compatible with cloud.google.com/go/datastore.
 func (c *Client) Count(ctx context.Context, q *Query) (int, error) {
 	ctx = c.withClientConfig(ctx)
 	c.logger.DebugContext(ctx, "counting entities", "kind", q.kind)
 
+	if c.zeroLimit(q) {
+		return 0, nil
+	}
+	if err := c.validateQuery(q); err != nil {
+		c.logger.WarnContext(ctx, "invalid query", "kind", q.kind, "error", err)
+		return 0, err
+	}
+
 	token, err := auth.AccessToken(ctx)
 	if err != nil {
 		c.logger.ErrorContext(ctx, "failed to get access token", "error", err)
 		return 0, fmt.Errorf("failed to get access token: %w", err)
 	}
 
-	// Build aggregation query with COUNT
-	queryObj := buildQueryMap(q)
+	// URL-encode project ID to prevent injection attacks
+	reqURL := fmt.Sprintf("%s/projects/%s:runAggregationQuery", c.baseURL, neturl.PathEscape(c.projectID))
+
+	// A batch the server stops early ends with a cursor; resume from there,
+	// past the offset, and sum the partial counts.
+	rq := c.outQuery(q)
+	count := 0
+	for {
+		n, cursor, err := c.countBatch(ctx, reqURL, token, rq, q.eventual)
+		if err != nil {
+			if count == 0 && c.countFallback && aggregationUnsupported(err) {
+				c.logger.WarnContext(ctx, "aggregation unsupported, counting keys instead", "error", err, "kind", q.kind)
+				return c.countKeys(ctx, q)
+			}
+			c.logger.ErrorContext(ctx, "count query failed", "error", err, "kind", q.kind)
+			return 0, err
+		}
+		count += n
+		if cursor == "" {
+			break
+		}
+
+		next := *rq
+		next.startCursor = cursor
+		next.offset = 0
+		if rq.limit > 0 {
+			next.limit = rq.limit - n
+			if next.limit <= 0 {
+				break
+			}
+		}
+		rq = &next
+		c.logger.DebugContext(ctx, "count not finished, continuing", "kind", q.kind, "partial_count", count)
+	}
+
+	c.logger.DebugContext(ctx, "count completed successfully", "kind", q.kind, "count", count)
+	return count, nil
+}
+
+// countBatch runs one COUNT aggregation over rq and returns the count. If the
+// server reports that it stopped before finishing, it also returns the cursor
+// to resume counting from.
+func (c *Client) countBatch(ctx context.Context, reqURL, token string, rq *Query, eventual bool) (int, Cursor, error) {
+	nested, err := buildQueryMap(rq)
+	if err != nil {
+		return 0, "", err
+	}
 	aggregationQuery := map[string]any{
 		"aggregations": []map[string]any{
 			{
@@ -504,7 +732,7 @@ func (c *Client) Count(ctx context.Context, q *Query) (int, error) {
 				"count": map[string]any{},
 			},
 		},
-		"nestedQuery": queryObj,
+		"nestedQuery": nested,
 	}
 
 	reqBody := map[string]any{
@@ -513,69 +741,124 @@ func (c *Client) Count(ctx context.Context, q *Query) (int, error) {
 	if c.databaseID != "" {
 		reqBody["databaseId"] = c.databaseID
 	}
-	if q.namespace != "" {
-		reqBody["partitionId"] = map[string]any{"namespaceId": q.namespace}
+	if rq.namespace != "" {
+		reqBody["partitionId"] = map[string]any{"namespaceId": rq.namespace}
+	}
+	if ro := readOptions(ctx, eventual); ro != nil {
+		reqBody["readOptions"] = ro
 	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		c.logger.ErrorContext(ctx, "failed to marshal request", "error", err)
-		return 0, fmt.Errorf("failed to marshal request: %w", err)
+		return 0, "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// URL-encode project ID to prevent injection attacks
-	reqURL := fmt.Sprintf("%s/projects/%s:runAggregationQuery", c.baseURL, neturl.PathEscape(c.projectID))
-	body, err := doRequest(ctx, c.logger, reqURL, jsonData, token, c.projectID, c.databaseID)
+	body, err := c.doRequest(ctx, reqURL, jsonData, token, c.readRetry, unknownEntities)
 	if err != nil {
-		c.logger.ErrorContext(ctx, "count query failed", "error", err, "kind", q.kind)
-		return 0, err
+		return 0, "", err
 	}
 
 	var result struct {
 		Batch struct {
 			AggregationResults []struct {
 				AggregateProperties map[string]struct {
-					IntegerValue string `json:"integerValue"`
+					IntegerValue any `json:"integerValue"`
 				} `json:"aggregateProperties"`
 			} `json:"aggregationResults"`
+			MoreResults string `json:"moreResults"`
+			EndCursor   string `json:"endCursor"`
+			ReadTime    string `json:"readTime"`
 		} `json:"batch"`
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		c.logger.ErrorContext(ctx, "failed to parse response", "error", err)
-		return 0, fmt.Errorf("failed to parse count response: %w", err)
+	if err := unmarshalResponse(body, &result); err != nil {
+		return 0, "", fmt.Errorf("failed to parse count response: %w", err)
+	}
+	recordReadTime(ctx, result.Batch.ReadTime)
+
+	var cursor Cursor
+	if result.Batch.MoreResults == "NOT_FINISHED" {
+		if result.Batch.EndCursor == "" || Cursor(result.Batch.EndCursor) == rq.startCursor {
+			return 0, "", errors.New("count not finished and no cursor to continue from")
+		}
+		cursor = Cursor(result.Batch.EndCursor)
 	}
 
 	if len(result.Batch.AggregationResults) == 0 {
-		c.logger.DebugContext(ctx, "no results returned", "kind", q.kind)
-		return 0, nil
+		return 0, cursor, nil
 	}
 
 	// Extract count from total aggregation
 	countVal, ok := result.Batch.AggregationResults[0].AggregateProperties["total"]
 	if !ok {
-		c.logger.ErrorContext(ctx, "count not found in response")
-		return 0, errors.New("count not found in aggregation response")
+		return 0, "", errors.New("count not found in aggregation response")
 	}
 
-	count, err := strconv.Atoi(countVal.IntegerValue)
+	n, err := parseInteger(countVal.IntegerValue)
 	if err != nil {
-		c.logger.ErrorContext(ctx, "failed to parse count", "error", err, "value", countVal.IntegerValue)
-		return 0, fmt.Errorf("failed to parse count: %w", err)
+		return 0, "", fmt.Errorf("failed to parse count: %w", err)
+	}
+	return int(n), cursor, nil
+}
+
+// countKeys counts the results of q by scanning it as a keys-only query,
+// respecting any limit and offset.
+func (c *Client) countKeys(ctx context.Context, q *Query) (int, error) {
+	kq := *q
+	kq.keysOnly = true
+
+	count := 0
+	it := c.Run(ctx, &kq)
+	for {
+		_, err := it.nextResult()
+		if errors.Is(err, Done) {
+			break
+		}
+		if err != nil {
+			c.logger.ErrorContext(ctx, "keys-only count failed", "error", err, "kind", q.kind)
+			return 0, err
+		}
+		count++
 	}
 
 	c.logger.DebugContext(ctx, "count completed successfully", "kind", q.kind, "count", count)
 	return count, nil
 }
 
+// aggregationUnsupported reports whether err indicates that the server
+// doesn't implement aggregation queries. Other errors, including NOT_FOUND
+// for a missing database, are returned to the caller as-is.
+func aggregationUnsupported(err error) bool {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.status == "UNIMPLEMENTED" || apiErr.statusCode == http.StatusNotImplemented
+}
+
 // Run executes the query and returns an iterator for the results.
 // API compatible with cloud.google.com/go/datastore.
 func (c *Client) Run(ctx context.Context, q *Query) *Iterator {
 	ctx = c.withClientConfig(ctx)
+	zero := c.zeroLimit(q)
 	return &Iterator{
 		ctx:       ctx,
 		client:    c,
 		query:     q,
-		fetchNext: true,
+		fetchNext: !zero,
+		atLimit:   zero,
 	}
 }
+
+// zeroLimit reports whether q is limited to zero results under the client's limit semantics.
+func (c *Client) zeroLimit(q *Query) bool {
+	return c.strictLimit && q.limitSet && q.limit == 0
+}
+
+// RunKeysOnly executes the query as a keys-only query and returns an iterator
+// over the result keys. No entity values are allocated or decoded.
+func (c *Client) RunKeysOnly(ctx context.Context, q *Query) *KeyIterator {
+	kq := *q
+	kq.keysOnly = true
+	return &KeyIterator{it: c.Run(ctx, &kq)}
+}
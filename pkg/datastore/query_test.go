@@ -2,12 +2,21 @@ package datastore_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/codeGROOVE-dev/ds9/auth"
 	"github.com/codeGROOVE-dev/ds9/pkg/datastore"
+	"github.com/codeGROOVE-dev/ds9/pkg/mock"
 )
 
 func TestQueryOperations(t *testing.T) {
@@ -68,6 +77,67 @@ func TestQueryWithLimitZero(t *testing.T) {
 	}
 }
 
+func TestQueryStrictLimit(t *testing.T) {
+	metadataURL, apiURL, cleanup := mock.NewMockServers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	client, err := datastore.NewClient(ctx, "test-project",
+		datastore.WithEndpoint(apiURL),
+		datastore.WithAuth(&auth.Config{MetadataURL: metadataURL, SkipADC: true}),
+		datastore.WithStrictLimit(),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	for i := range 5 {
+		key := datastore.NameKey("StrictLimit", fmt.Sprintf("key-%d", i), nil)
+		if _, err := client.Put(ctx, key, &testEntity{Name: "item", Count: int64(i)}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	t.Run("ZeroReturnsNone", func(t *testing.T) {
+		var entities []testEntity
+		keys, err := client.GetAll(ctx, datastore.NewQuery("StrictLimit").Limit(0), &entities)
+		if err != nil {
+			t.Fatalf("GetAll failed: %v", err)
+		}
+		if len(keys) != 0 || len(entities) != 0 {
+			t.Errorf("expected no results for Limit(0), got %d keys and %d entities", len(keys), len(entities))
+		}
+
+		count, err := client.Count(ctx, datastore.NewQuery("StrictLimit").Limit(0))
+		if err != nil {
+			t.Fatalf("Count failed: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("expected count 0 for Limit(0), got %d", count)
+		}
+	})
+
+	t.Run("NegativeReturnsAll", func(t *testing.T) {
+		keys, err := client.AllKeys(ctx, datastore.NewQuery("StrictLimit").KeysOnly().Limit(-1))
+		if err != nil {
+			t.Fatalf("AllKeys failed: %v", err)
+		}
+		if len(keys) != 5 {
+			t.Errorf("expected 5 keys for Limit(-1), got %d", len(keys))
+		}
+	})
+
+	t.Run("UnsetReturnsAll", func(t *testing.T) {
+		keys, err := client.AllKeys(ctx, datastore.NewQuery("StrictLimit").KeysOnly())
+		if err != nil {
+			t.Fatalf("AllKeys failed: %v", err)
+		}
+		if len(keys) != 5 {
+			t.Errorf("expected 5 keys without a limit, got %d", len(keys))
+		}
+	})
+}
+
 func TestQueryWithLimitLessThanResults(t *testing.T) {
 	client, cleanup := datastore.NewMockClient(t)
 	defer cleanup()
@@ -394,6 +464,94 @@ func TestGetAllWithLimitVariations(t *testing.T) {
 	}
 }
 
+func TestGetAllPaginatesBatches(t *testing.T) {
+	const total, batchSize = 700, 300
+
+	var cursors []string
+	entity := func(i int) map[string]any {
+		return map[string]any{
+			"key": map[string]any{"path": []any{map[string]any{"kind": "Task", "id": strconv.Itoa(i + 1)}}},
+			"properties": map[string]any{
+				"count": map[string]any{"integerValue": strconv.Itoa(i)},
+			},
+		}
+	}
+	client := newTestClient(t, pagingHandler(t, total, batchSize, entity, func(req pagedQuery) {
+		cursors = append(cursors, req.Query.StartCursor)
+	}))
+
+	var entities []testEntity
+	keys, err := client.GetAll(context.Background(), datastore.NewQuery("Task"), &entities)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+
+	if len(keys) != total || len(entities) != total {
+		t.Fatalf("expected %d keys and entities, got %d and %d", total, len(keys), len(entities))
+	}
+	for i, key := range keys {
+		if key.ID != int64(i+1) {
+			t.Fatalf("key %d: expected ID %d, got %d", i, i+1, key.ID)
+		}
+		if entities[i].Count != int64(i) {
+			t.Fatalf("entity %d: expected count %d, got %d", i, i, entities[i].Count)
+		}
+	}
+	if want := []string{"", "300", "600"}; !slices.Equal(cursors, want) {
+		t.Errorf("expected start cursors %v, got %v", want, cursors)
+	}
+}
+
+func TestGetAllDecodesBestEffort(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		counts := []string{"10", "not-an-integer", "30"}
+		results := make([]any, len(counts))
+		for i, c := range counts {
+			results[i] = map[string]any{
+				"entity": map[string]any{
+					"key": map[string]any{"path": []any{map[string]any{"kind": "Task", "id": strconv.Itoa(i + 1)}}},
+					"properties": map[string]any{
+						"name":  map[string]any{"stringValue": "task" + strconv.Itoa(i+1)},
+						"count": map[string]any{"integerValue": c},
+					},
+				},
+			}
+		}
+		writeJSON(t, w, map[string]any{"batch": map[string]any{
+			"entityResults": results,
+			"moreResults":   "NO_MORE_RESULTS",
+		}})
+	})
+
+	var entities []testEntity
+	keys, err := client.GetAll(context.Background(), datastore.NewQuery("Task"), &entities)
+	var multiErr datastore.MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("expected MultiError, got %v", err)
+	}
+	if len(multiErr) != 3 {
+		t.Fatalf("expected MultiError of length 3, got %d", len(multiErr))
+	}
+	if multiErr[0] != nil || multiErr[1] == nil || multiErr[2] != nil {
+		t.Errorf("expected only index 1 to fail, got %v", multiErr)
+	}
+
+	if len(keys) != 3 || len(entities) != 3 {
+		t.Fatalf("expected 3 keys and entities, got %d and %d", len(keys), len(entities))
+	}
+	for _, i := range []int{0, 2} {
+		if keys[i].ID != int64(i+1) {
+			t.Errorf("key %d: expected ID %d, got %d", i, i+1, keys[i].ID)
+		}
+		if want := int64((i + 1) * 10); entities[i].Count != want || entities[i].Name != "task"+strconv.Itoa(i+1) {
+			t.Errorf("entity %d: expected task%d with count %d, got %+v", i, i+1, want, entities[i])
+		}
+	}
+	if keys[1].ID != 2 {
+		t.Errorf("expected the failed entity's key to be returned, got %v", keys[1])
+	}
+}
+
 func TestCount(t *testing.T) {
 	client, cleanup := datastore.NewMockClient(t)
 	defer cleanup()
@@ -489,6 +647,93 @@ func TestCount(t *testing.T) {
 	})
 }
 
+func TestCountFallback(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/projects/test-project:runAggregationQuery":
+			w.WriteHeader(http.StatusNotImplemented)
+			writeJSON(t, w, map[string]any{"error": map[string]any{
+				"code": 501, "status": "UNIMPLEMENTED", "message": "aggregation queries are not supported",
+			}})
+		case "/projects/test-project:runQuery":
+			var reqBody struct {
+				Query struct {
+					Limit int `json:"limit"`
+				} `json:"query"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			n := 7
+			if reqBody.Query.Limit > 0 {
+				n = min(n, reqBody.Query.Limit)
+			}
+			results := make([]any, 0, n)
+			for i := range n {
+				results = append(results, map[string]any{"entity": map[string]any{
+					"key": map[string]any{"path": []any{map[string]any{"kind": "Task", "id": strconv.Itoa(i + 1)}}},
+				}})
+			}
+			writeJSON(t, w, map[string]any{"batch": map[string]any{
+				"entityResults": results,
+				"moreResults":   "NO_MORE_RESULTS",
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	ctx := context.Background()
+
+	t.Run("Enabled", func(t *testing.T) {
+		client := newTestClient(t, handler, datastore.WithCountFallback(true))
+
+		count, err := client.Count(ctx, datastore.NewQuery("Task"))
+		if err != nil {
+			t.Fatalf("Count failed: %v", err)
+		}
+		if count != 7 {
+			t.Errorf("expected count 7, got %d", count)
+		}
+
+		count, err = client.Count(ctx, datastore.NewQuery("Task").Limit(3))
+		if err != nil {
+			t.Fatalf("Count with limit failed: %v", err)
+		}
+		if count != 3 {
+			t.Errorf("expected count 3 with limit, got %d", count)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		client := newTestClient(t, handler)
+
+		if _, err := client.Count(ctx, datastore.NewQuery("Task")); err == nil {
+			t.Error("expected error without count fallback")
+		}
+	})
+
+	t.Run("NotFoundIsNotUnsupported", func(t *testing.T) {
+		var keysOnly int
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/projects/test-project:runQuery" {
+				keysOnly++
+			}
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(t, w, map[string]any{"error": map[string]any{
+				"code": 404, "status": "NOT_FOUND", "message": "database does not exist",
+			}})
+		}, datastore.WithCountFallback(true))
+
+		if _, err := client.Count(ctx, datastore.NewQuery("Task")); err == nil {
+			t.Error("expected NOT_FOUND to be returned, not counted around")
+		}
+		if keysOnly != 0 {
+			t.Errorf("expected no fallback query for NOT_FOUND, got %d", keysOnly)
+		}
+	})
+}
+
 func TestQueryNamespace(t *testing.T) {
 	client, cleanup := datastore.NewMockClient(t)
 	defer cleanup()
@@ -557,3 +802,1006 @@ func TestQueryDistinct(t *testing.T) {
 		}
 	})
 }
+
+func TestReadTimeContext(t *testing.T) {
+	readTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var mu sync.Mutex
+	seen := map[string]any{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		seen[r.URL.Path] = reqBody["readOptions"]
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/projects/test-project:lookup":
+			writeJSON(t, w, map[string]any{"found": []any{}})
+		case "/projects/test-project:runQuery":
+			writeJSON(t, w, map[string]any{"batch": map[string]any{"moreResults": "NO_MORE_RESULTS"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ctx := datastore.WithReadTimeContext(context.Background(), readTime)
+
+	var entity testEntity
+	if err := client.Get(ctx, datastore.NameKey("Snap", "a", nil), &entity); !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Fatalf("expected ErrNoSuchEntity, got %v", err)
+	}
+	var entities []testEntity
+	if _, err := client.GetAll(ctx, datastore.NewQuery("Snap"), &entities); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+
+	want := readTime.Format(time.RFC3339Nano)
+	for _, path := range []string{"/projects/test-project:lookup", "/projects/test-project:runQuery"} {
+		ro, ok := seen[path].(map[string]any)
+		if !ok {
+			t.Errorf("%s: expected readOptions, got %v", path, seen[path])
+			continue
+		}
+		if ro["readTime"] != want {
+			t.Errorf("%s: expected readTime %s, got %v", path, want, ro["readTime"])
+		}
+	}
+
+	// Reads on a plain context carry no read time.
+	seen = map[string]any{}
+	if err := client.Get(context.Background(), datastore.NameKey("Snap", "a", nil), &entity); !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Fatalf("expected ErrNoSuchEntity, got %v", err)
+	}
+	if ro := seen["/projects/test-project:lookup"]; ro != nil {
+		t.Errorf("expected no readOptions without read time context, got %v", ro)
+	}
+}
+
+func TestQueryEventualConsistency(t *testing.T) {
+	var mu sync.Mutex
+	var last map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		last = reqBody
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/projects/test-project:runQuery":
+			writeJSON(t, w, map[string]any{"batch": map[string]any{"moreResults": "NO_MORE_RESULTS"}})
+		case "/projects/test-project:runAggregationQuery":
+			writeJSON(t, w, map[string]any{"batch": map[string]any{"aggregationResults": []any{
+				map[string]any{"aggregateProperties": map[string]any{"total": map[string]any{"integerValue": "0"}}},
+			}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	ctx := context.Background()
+
+	consistency := func() any {
+		mu.Lock()
+		defer mu.Unlock()
+		ro, ok := last["readOptions"].(map[string]any)
+		if !ok {
+			return nil
+		}
+		return ro["readConsistency"]
+	}
+
+	var entities []testEntity
+	if _, err := client.GetAll(ctx, datastore.NewQuery("Task").EventualConsistency(), &entities); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if got := consistency(); got != "EVENTUAL" {
+		t.Errorf("GetAll: expected EVENTUAL read consistency, got %v", got)
+	}
+
+	if _, err := client.Count(ctx, datastore.NewQuery("Task").EventualConsistency()); err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if got := consistency(); got != "EVENTUAL" {
+		t.Errorf("Count: expected EVENTUAL read consistency, got %v", got)
+	}
+
+	if _, err := client.GetAll(ctx, datastore.NewQuery("Task"), &entities); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if got := consistency(); got != nil {
+		t.Errorf("expected no read consistency by default, got %v", got)
+	}
+}
+
+func TestIndexCreationHint(t *testing.T) {
+	const indexYAML = "indexes:\n- kind: Task\n  properties:\n  - name: done\n  - name: priority\n    direction: desc\n"
+
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		writeJSON(t, w, map[string]any{
+			"error": map[string]any{
+				"code":    412,
+				"status":  "FAILED_PRECONDITION",
+				"message": "no matching index found.",
+				"details": []any{
+					map[string]any{
+						"@type":        "type.googleapis.com/google.rpc.DebugInfo",
+						"detail":       indexYAML,
+						"stackEntries": []any{},
+					},
+				},
+			},
+		})
+	})
+
+	q := datastore.NewQuery("Task").Filter("done =", false).Order("-priority")
+	var tasks []testEntity
+	_, err := client.GetAll(context.Background(), q, &tasks)
+	if err == nil {
+		t.Fatal("expected error for missing index")
+	}
+
+	hint, ok := datastore.IndexCreationHint(err)
+	if !ok {
+		t.Fatalf("expected index hint in %v", err)
+	}
+	if hint != strings.TrimSpace(indexYAML) {
+		t.Errorf("unexpected hint:\n%s", hint)
+	}
+
+	if _, ok := datastore.IndexCreationHint(errors.New("unrelated")); ok {
+		t.Error("expected no hint for unrelated error")
+	}
+}
+
+func TestIndexCreationHintFromMessage(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(t, w, map[string]any{
+			"error": map[string]any{
+				"code":    400,
+				"status":  "FAILED_PRECONDITION",
+				"message": "no matching index found. recommended index is:\n- kind: Task\n  properties:\n  - name: done\n",
+			},
+		})
+	})
+
+	var tasks []testEntity
+	_, err := client.GetAll(context.Background(), datastore.NewQuery("Task").Filter("done =", false), &tasks)
+
+	hint, ok := datastore.IndexCreationHint(err)
+	if !ok {
+		t.Fatalf("expected index hint in %v", err)
+	}
+	if !strings.HasPrefix(hint, "- kind: Task") {
+		t.Errorf("unexpected hint: %q", hint)
+	}
+}
+
+func TestSample(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for i := range 10 {
+		key := datastore.IDKey("SampleKind", int64(i+1), nil)
+		if _, err := client.Put(ctx, key, &testEntity{Name: "seeded", Count: int64(i)}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	var sample []testEntity
+	if err := client.Sample(ctx, "SampleKind", 3, &sample); err != nil {
+		t.Fatalf("Sample failed: %v", err)
+	}
+	if len(sample) != 3 {
+		t.Errorf("expected 3 sampled entities, got %d", len(sample))
+	}
+	for _, e := range sample {
+		if e.Name != "seeded" {
+			t.Errorf("unexpected sampled entity: %+v", e)
+		}
+	}
+
+	var all []testEntity
+	if err := client.Sample(ctx, "SampleKind", 50, &all); err != nil {
+		t.Fatalf("Sample failed: %v", err)
+	}
+	if len(all) != 10 {
+		t.Errorf("expected sample capped at 10 entities, got %d", len(all))
+	}
+
+	if err := client.Sample(ctx, "SampleKind", 0, &all); err == nil {
+		t.Error("expected error for non-positive sample size")
+	}
+}
+
+func TestGetAllTyped(t *testing.T) {
+	metadataURL, apiURL, cleanup := mock.NewMockServers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	client, err := datastore.NewClient(ctx, "test-project",
+		datastore.WithEndpoint(apiURL),
+		datastore.WithAuth(&auth.Config{MetadataURL: metadataURL, SkipADC: true}),
+		datastore.WithKindType("Typed", &testEntity{}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if _, err := client.Put(ctx, datastore.NameKey("Typed", name, nil), &testEntity{Name: name}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	results, err := client.GetAllTyped(ctx, datastore.NewQuery("Typed"))
+	if err != nil {
+		t.Fatalf("GetAllTyped failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		e, ok := r.(*testEntity)
+		if !ok {
+			t.Fatalf("expected *testEntity, got %T", r)
+		}
+		if e.Name != "a" && e.Name != "b" {
+			t.Errorf("unexpected entity %+v", e)
+		}
+	}
+
+	if _, err := client.GetAllTyped(ctx, datastore.NewQuery("Unregistered")); err == nil {
+		t.Error("expected error for unregistered kind")
+	}
+}
+
+func TestQueryProjectDecodesPartialStructs(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	key := datastore.NameKey("TestKind", "rich", nil)
+	full := &testEntity{
+		UpdatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		Name:      "rich",
+		Notes:     "not projected",
+		Count:     42,
+		Score:     9.5,
+		Active:    true,
+	}
+	if _, err := client.Put(ctx, key, full); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var got []testEntity
+	keys, err := client.GetAll(ctx, datastore.NewQuery("TestKind").Project("name", "count"), &got)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(keys) != 1 || len(got) != 1 {
+		t.Fatalf("expected 1 result, got %d keys and %d entities", len(keys), len(got))
+	}
+
+	want := testEntity{Name: "rich", Count: 42}
+	if got[0] != want {
+		t.Errorf("expected only projected fields to be set, got %+v", got[0])
+	}
+}
+
+func TestQueryOnUnindexedArray(t *testing.T) {
+	type taggedEntity struct {
+		Labels []string `datastore:"labels"`
+		Tags   []string `datastore:"tags,noindex"`
+	}
+
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	key := datastore.NameKey("Tagged", "a", nil)
+	if _, err := client.Put(ctx, key, &taggedEntity{Labels: []string{"x", "y"}, Tags: []string{"x", "y"}}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var got []taggedEntity
+	_, err := client.GetAll(ctx, datastore.NewQuery("Tagged").FilterField("tags", "=", "x"), &got)
+	if !errors.Is(err, datastore.ErrUnindexedProperty) {
+		t.Fatalf("expected ErrUnindexedProperty, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"tags"`) {
+		t.Errorf("expected error to name the property, got %q", err)
+	}
+
+	// Without a struct to check against, the query runs and, as in Datastore,
+	// matches nothing on the unindexed property
+	keys, err := client.AllKeys(ctx, datastore.NewQuery("Tagged").FilterField("tags", "=", "x").KeysOnly())
+	if err != nil {
+		t.Fatalf("AllKeys failed: %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("expected no matches on an unindexed array, got %v", keys)
+	}
+
+	// An indexed array matches on any element
+	if _, err := client.GetAll(ctx, datastore.NewQuery("Tagged").FilterField("labels", "=", "y"), &got); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("expected the indexed array to match, got %d results", len(got))
+	}
+}
+
+func TestQueryArrayMembership(t *testing.T) {
+	type ticket struct {
+		Tags []string `datastore:"tags"`
+	}
+
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	tickets := map[string][]string{
+		"t1": {"urgent", "billing"},
+		"t2": {"billing"},
+		"t3": {"urgent"},
+		"t4": nil,
+		"t5": {"ops", "urgent", "billing"},
+	}
+	for name, tags := range tickets {
+		if _, err := client.Put(ctx, datastore.NameKey("Ticket", name, nil), &ticket{Tags: tags}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	names := func(keys []*datastore.Key) []string {
+		var out []string
+		for _, k := range keys {
+			out = append(out, k.Name)
+		}
+		slices.Sort(out)
+		return out
+	}
+
+	var got []ticket
+	keys, err := client.GetAll(ctx, datastore.NewQuery("Ticket").Filter("tags =", "urgent"), &got)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if want := []string{"t1", "t3", "t5"}; !slices.Equal(names(keys), want) {
+		t.Errorf("tags = urgent matched %v, want %v", names(keys), want)
+	}
+
+	// Each equality filter is satisfied by any element, so two of them on the
+	// same array match entities holding both values
+	keys, err = client.AllKeys(ctx, datastore.NewQuery("Ticket").Filter("tags =", "urgent").Filter("tags =", "billing").KeysOnly())
+	if err != nil {
+		t.Fatalf("AllKeys failed: %v", err)
+	}
+	if want := []string{"t1", "t5"}; !slices.Equal(names(keys), want) {
+		t.Errorf("tags = urgent AND tags = billing matched %v, want %v", names(keys), want)
+	}
+
+	n, err := client.Count(ctx, datastore.NewQuery("Ticket").Filter("tags =", "billing"))
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Count(tags = billing) = %d, want 3", n)
+	}
+
+	// Comparing against a whole slice isn't a membership test, and is rejected
+	if _, err := client.AllKeys(ctx, datastore.NewQuery("Ticket").Filter("tags =", []string{"urgent"}).KeysOnly()); err == nil {
+		t.Error("expected an array filter value to be rejected")
+	}
+}
+
+func TestQueryFilterByTime(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		key := datastore.NameKey("Event", fmt.Sprintf("e%d", i), nil)
+		e := &testEntity{Name: fmt.Sprintf("e%d", i), UpdatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if _, err := client.Put(ctx, key, e); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	cutoff := base.Add(2 * time.Hour)
+	var got []testEntity
+	query := datastore.NewQuery("Event").Filter("updated_at >", cutoff).Order("updated_at")
+	if _, err := client.GetAll(ctx, query, &got); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+
+	var names []string
+	for _, e := range got {
+		names = append(names, e.Name)
+	}
+	if want := []string{"e3", "e4"}; !slices.Equal(names, want) {
+		t.Errorf("expected %v after the cutoff, got %v", want, names)
+	}
+
+	// Cutoffs in other zones compare by instant
+	got = nil
+	query = datastore.NewQuery("Event").FilterField("updated_at", "<=", cutoff.In(time.FixedZone("EST", -5*3600)))
+	if _, err := client.GetAll(ctx, query, &got); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("expected 3 entities at or before the cutoff, got %d", len(got))
+	}
+}
+
+func TestQueryOrFilter(t *testing.T) {
+	type task struct {
+		Status   string `datastore:"status"`
+		Priority int64  `datastore:"priority"`
+	}
+
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	tasks := map[string]task{
+		"open-low":    {Status: "open", Priority: 1},
+		"closed-high": {Status: "closed", Priority: 9},
+		"open-high":   {Status: "open", Priority: 8},
+		"closed-low":  {Status: "closed", Priority: 2},
+	}
+	for name, tk := range tasks {
+		if _, err := client.Put(ctx, datastore.NameKey("Task", name, nil), &tk); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	query := datastore.NewQuery("Task").KeysOnly().FilterEntity(datastore.OrFilter(
+		datastore.PropertyFilter{FieldName: "status", Operator: "=", Value: "open"},
+		datastore.PropertyFilter{FieldName: "priority", Operator: ">", Value: 5},
+	))
+	keys, err := client.AllKeys(ctx, query)
+	if err != nil {
+		t.Fatalf("AllKeys failed: %v", err)
+	}
+
+	var names []string
+	for _, k := range keys {
+		names = append(names, k.Name)
+	}
+	slices.Sort(names)
+	if want := []string{"closed-high", "open-high", "open-low"}; !slices.Equal(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+}
+
+func TestQueryOrderByKey(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	// IDs order numerically, not as strings
+	for _, id := range []int64{10, 2, 9, 100} {
+		if _, err := client.Put(ctx, datastore.IDKey("Page", id, nil), &testEntity{Name: fmt.Sprint(id)}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	ids := func(keys []*datastore.Key) []int64 {
+		var out []int64
+		for _, k := range keys {
+			out = append(out, k.ID)
+		}
+		return out
+	}
+
+	keys, err := client.AllKeys(ctx, datastore.NewQuery("Page").KeysOnly().Order("__key__"))
+	if err != nil {
+		t.Fatalf("AllKeys failed: %v", err)
+	}
+	if want := []int64{2, 9, 10, 100}; !slices.Equal(ids(keys), want) {
+		t.Errorf("ascending: expected %v, got %v", want, ids(keys))
+	}
+
+	keys, err = client.AllKeys(ctx, datastore.NewQuery("Page").KeysOnly().Order("-__key__"))
+	if err != nil {
+		t.Fatalf("AllKeys failed: %v", err)
+	}
+	if want := []int64{100, 10, 9, 2}; !slices.Equal(ids(keys), want) {
+		t.Errorf("descending: expected %v, got %v", want, ids(keys))
+	}
+}
+
+func TestQueryKeyFilterResumesScan(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := client.Put(ctx, datastore.NameKey("Item", name, nil), &testEntity{Name: name}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	// Scan in pages of two, resuming after the last key seen
+	var seen []string
+	var last *datastore.Key
+	for range 5 {
+		q := datastore.NewQuery("Item").Order("__key__").Limit(2)
+		if last != nil {
+			q = q.Filter("__key__ >", last)
+		}
+		var page []testEntity
+		keys, err := client.GetAll(ctx, q, &page)
+		if err != nil {
+			t.Fatalf("GetAll failed: %v", err)
+		}
+		if len(keys) == 0 {
+			break
+		}
+		for _, e := range page {
+			seen = append(seen, e.Name)
+		}
+		last = keys[len(keys)-1]
+	}
+
+	if want := []string{"a", "b", "c", "d", "e"}; !slices.Equal(seen, want) {
+		t.Errorf("expected %v, got %v", want, seen)
+	}
+}
+
+func TestCountIntegerRepresentations(t *testing.T) {
+	// The API sends counts as strings; some proxies and the emulator send numbers
+	for name, total := range map[string]any{"string": "42", "number": 42} {
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+				writeJSON(t, w, map[string]any{"batch": map[string]any{
+					"aggregationResults": []any{map[string]any{
+						"aggregateProperties": map[string]any{"total": map[string]any{"integerValue": total}},
+					}},
+				}})
+			})
+			count, err := client.Count(context.Background(), datastore.NewQuery("TestKind"))
+			if err != nil {
+				t.Fatalf("Count failed: %v", err)
+			}
+			if count != 42 {
+				t.Errorf("expected 42, got %d", count)
+			}
+		})
+	}
+}
+
+func TestGetAllKeysOnlyIntoKeySlice(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := client.Put(ctx, datastore.NameKey("Widget", name, nil), &testEntity{Name: name}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if _, err := client.Put(ctx, datastore.NameKey("Gadget", "z", nil), &testEntity{Name: "z"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var keys []*datastore.Key
+	returned, err := client.GetAll(ctx, datastore.NewQuery("Widget").KeysOnly().Order("__key__"), &keys)
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 keys in dst, got %d", len(keys))
+	}
+	for i, name := range []string{"a", "b", "c"} {
+		if keys[i].Kind != "Widget" || keys[i].Name != name {
+			t.Errorf("key %d = %v, want Widget %q", i, keys[i], name)
+		}
+		if !keys[i].Equal(returned[i]) {
+			t.Errorf("dst key %d = %v, returned %v", i, keys[i], returned[i])
+		}
+	}
+}
+
+func TestRegisterIndexedFields(t *testing.T) {
+	datastore.RegisterIndexedFields("RegisteredTicket", "status", "priority")
+
+	var requests int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeJSON(t, w, map[string]any{"batch": map[string]any{"moreResults": "NO_MORE_RESULTS"}})
+	})
+	ctx := context.Background()
+
+	var got []testEntity
+	_, err := client.GetAll(ctx, datastore.NewQuery("RegisteredTicket").FilterField("name", "=", "x"), &got)
+	if !errors.Is(err, datastore.ErrFieldNotIndexed) {
+		t.Fatalf("expected ErrFieldNotIndexed, got %v", err)
+	}
+	if !errors.Is(err, datastore.ErrUnindexedProperty) {
+		t.Errorf("expected ErrFieldNotIndexed to match ErrUnindexedProperty, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"name"`) || !strings.Contains(err.Error(), `"RegisteredTicket"`) {
+		t.Errorf("expected error to name the property and kind, got %q", err)
+	}
+	if _, err := client.Count(ctx, datastore.NewQuery("RegisteredTicket").Order("-name")); !errors.Is(err, datastore.ErrFieldNotIndexed) {
+		t.Errorf("expected ErrFieldNotIndexed from Count, got %v", err)
+	}
+	if requests != 0 {
+		t.Fatalf("expected no requests for rejected queries, got %d", requests)
+	}
+
+	// Registered fields, __key__, and kinds never registered are allowed
+	q := datastore.NewQuery("RegisteredTicket").FilterField("status", "=", "open").Order("priority").Order("__key__")
+	if _, err := client.GetAll(ctx, q, &got); err != nil {
+		t.Errorf("GetAll on registered fields failed: %v", err)
+	}
+	if _, err := client.GetAll(ctx, datastore.NewQuery("UnregisteredTicket").FilterField("name", "=", "x"), &got); err != nil {
+		t.Errorf("GetAll on an unregistered kind failed: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+
+	// Registering again adds to the kind's fields
+	datastore.RegisterIndexedFields("RegisteredTicket", "name")
+	if _, err := client.GetAll(ctx, datastore.NewQuery("RegisteredTicket").FilterField("name", "=", "x"), &got); err != nil {
+		t.Errorf("GetAll after registering name failed: %v", err)
+	}
+}
+
+func TestCountFollowsUnfinishedBatches(t *testing.T) {
+	countBatch := func(total, more, cursor string) map[string]any {
+		return map[string]any{"batch": map[string]any{
+			"aggregationResults": []any{map[string]any{
+				"aggregateProperties": map[string]any{"total": map[string]any{"integerValue": total}},
+			}},
+			"moreResults": more,
+			"endCursor":   cursor,
+		}}
+	}
+
+	var nested []map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			AggregationQuery struct {
+				NestedQuery map[string]any `json:"nestedQuery"`
+			} `json:"aggregationQuery"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		nested = append(nested, req.AggregationQuery.NestedQuery)
+		if len(nested) == 1 {
+			writeJSON(t, w, countBatch("300", "NOT_FINISHED", "resume-here"))
+			return
+		}
+		writeJSON(t, w, countBatch("125", "NO_MORE_RESULTS", ""))
+	})
+
+	count, err := client.Count(context.Background(), datastore.NewQuery("TestKind").Offset(10).Limit(1000))
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if count != 425 {
+		t.Errorf("expected the sum of both batches, 425, got %d", count)
+	}
+	if len(nested) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(nested))
+	}
+	// The second batch resumes at the cursor, past the offset, with what remains of the limit
+	if got := nested[1]["startCursor"]; got != "resume-here" {
+		t.Errorf("expected second batch to start at the cursor, got %v", got)
+	}
+	if _, ok := nested[1]["offset"]; ok {
+		t.Errorf("expected no offset on the second batch, got %v", nested[1]["offset"])
+	}
+	if got := nested[1]["limit"]; got != float64(700) {
+		t.Errorf("expected remaining limit 700 on the second batch, got %v", got)
+	}
+
+	// An unfinished batch with no cursor can't be completed
+	client = newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(t, w, countBatch("300", "NOT_FINISHED", ""))
+	})
+	if _, err := client.Count(context.Background(), datastore.NewQuery("TestKind")); err == nil {
+		t.Error("expected an error for an unfinished count without a cursor")
+	}
+}
+
+func TestDistinctOnValidation(t *testing.T) {
+	var bodies []map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		bodies = append(bodies, req)
+		writeJSON(t, w, map[string]any{"batch": map[string]any{"moreResults": "NO_MORE_RESULTS"}})
+	})
+	ctx := context.Background()
+
+	invalid := map[string]struct {
+		q    *datastore.Query
+		want string
+	}{
+		"not projected": {
+			q:    datastore.NewQuery("Task").Project("name").DistinctOn("name", "count"),
+			want: `distinct-on field "count" must also be projected`,
+		},
+		"ordered after another field": {
+			q:    datastore.NewQuery("Task").Project("name", "count").DistinctOn("name").Order("count").Order("name"),
+			want: `order 1 is on "count", but distinct-on fields must be ordered on first`,
+		},
+	}
+	for name, tc := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var got []testEntity
+			_, err := client.GetAll(ctx, tc.q, &got)
+			if !errors.Is(err, datastore.ErrInvalidQuery) {
+				t.Fatalf("expected ErrInvalidQuery, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected error containing %q, got %q", tc.want, err)
+			}
+			if _, err := client.Count(ctx, tc.q); !errors.Is(err, datastore.ErrInvalidQuery) {
+				t.Errorf("expected ErrInvalidQuery from Count, got %v", err)
+			}
+		})
+	}
+	if len(bodies) != 0 {
+		t.Fatalf("expected invalid queries not to be sent, got %d requests", len(bodies))
+	}
+
+	q := datastore.NewQuery("Task").Project("name", "count").DistinctOn("count", "name").
+		Order("name").Order("-count").Order("score")
+	var got []testEntity
+	if _, err := client.GetAll(ctx, q, &got); err != nil {
+		t.Fatalf("GetAll with a valid DistinctOn failed: %v", err)
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(bodies))
+	}
+	query, _ := bodies[0]["query"].(map[string]any)
+	names := func(field string) []string {
+		var out []string
+		entries, _ := query[field].([]any)
+		for _, e := range entries {
+			m, _ := e.(map[string]any)
+			p, _ := m["property"].(map[string]any)
+			out = append(out, fmt.Sprint(p["name"]))
+		}
+		return out
+	}
+	if got := names("distinctOn"); !slices.Equal(got, []string{"count", "name"}) {
+		t.Errorf("distinctOn = %v, want [count name]", got)
+	}
+	if got := names("projection"); !slices.Equal(got, []string{"name", "count"}) {
+		t.Errorf("projection = %v, want [name count]", got)
+	}
+	if got := names("order"); !slices.Equal(got, []string{"name", "count", "score"}) {
+		t.Errorf("order = %v, want [name count score]", got)
+	}
+}
+
+func TestFilterNestedFieldPath(t *testing.T) {
+	type address struct {
+		City string `datastore:"city"`
+		Zip  string `datastore:"zip"`
+	}
+	type customer struct {
+		Name    string  `datastore:"name"`
+		Address address `datastore:"address,flatten"`
+		Home    address `datastore:"home"`
+	}
+
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, c := range []customer{
+		{Name: "ada", Address: address{City: "London", Zip: "NW1"}, Home: address{City: "Leeds"}},
+		{Name: "bea", Address: address{City: "Paris", Zip: "75001"}, Home: address{City: "London"}},
+		{Name: "cal", Address: address{City: "London", Zip: "EC1"}, Home: address{City: "Paris"}},
+	} {
+		if _, err := client.Put(ctx, datastore.NameKey("Customer", c.Name, nil), &c); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	names := func(q *datastore.Query) []string {
+		t.Helper()
+		var got []customer
+		if _, err := client.GetAll(ctx, q, &got); err != nil {
+			t.Fatalf("GetAll failed: %v", err)
+		}
+		var out []string
+		for _, c := range got {
+			out = append(out, c.Name)
+		}
+		return out
+	}
+
+	// A flattened struct's fields are properties named by their dotted path
+	q := datastore.NewQuery("Customer").FilterField("address.city", "=", "London").Order("address.zip")
+	if got := names(q); !slices.Equal(got, []string{"cal", "ada"}) {
+		t.Errorf("FilterField on address.city = %v, want [cal ada]", got)
+	}
+	q = datastore.NewQuery("Customer").Filter("address.city =", "London").Order("name")
+	if got := names(q); !slices.Equal(got, []string{"ada", "cal"}) {
+		t.Errorf("Filter on address.city = %v, want [ada cal]", got)
+	}
+
+	// A dotted path also reaches into a nested entity
+	q = datastore.NewQuery("Customer").FilterField("home.city", "=", "London")
+	if got := names(q); !slices.Equal(got, []string{"bea"}) {
+		t.Errorf("FilterField on home.city = %v, want [bea]", got)
+	}
+}
+
+func TestMultipleInequalityRejected(t *testing.T) {
+	var requests int
+	handler := func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		writeJSON(t, w, map[string]any{"batch": map[string]any{"moreResults": "NO_MORE_RESULTS"}})
+	}
+	client := newTestClient(t, handler)
+	ctx := context.Background()
+
+	queries := map[string]*datastore.Query{
+		"FilterField": datastore.NewQuery("Task").FilterField("count", ">", 1).FilterField("score", "<", 5.0),
+		"NotEqual":    datastore.NewQuery("Task").FilterField("count", ">", 1).FilterField("name", "!=", "x"),
+		"FilterEntity": datastore.NewQuery("Task").FilterField("count", ">", 1).FilterEntity(datastore.OrFilter(
+			datastore.PropertyFilter{FieldName: "name", Operator: "=", Value: "x"},
+			datastore.PropertyFilter{FieldName: "score", Operator: "<=", Value: 5.0},
+		)),
+	}
+
+	// Firestore in Datastore mode accepts these, so the check can be disabled
+	unchecked := newTestClient(t, handler, datastore.WithInequalityCheck(false))
+	for name, q := range queries {
+		var got []testEntity
+		if _, err := unchecked.GetAll(ctx, q, &got); err != nil {
+			t.Errorf("%s: expected query to be sent with the check disabled, got %v", name, err)
+		}
+	}
+	if requests != len(queries) {
+		t.Fatalf("expected %d requests with the check disabled, got %d", len(queries), requests)
+	}
+	requests = 0
+
+	for name, q := range queries {
+		t.Run(name, func(t *testing.T) {
+			var got []testEntity
+			_, err := client.GetAll(ctx, q, &got)
+			if !errors.Is(err, datastore.ErrMultipleInequality) {
+				t.Fatalf("expected ErrMultipleInequality, got %v", err)
+			}
+			if !strings.Contains(err.Error(), `"count"`) {
+				t.Errorf("expected error to name the properties, got %q", err)
+			}
+		})
+	}
+	if requests != 0 {
+		t.Fatalf("expected rejected queries not to be sent, got %d requests", requests)
+	}
+
+	// Several inequalities on one property, alongside equality filters, are fine
+	q := datastore.NewQuery("Task").FilterField("count", ">", 1).FilterField("count", "<", 10).FilterField("name", "=", "x")
+	var got []testEntity
+	if _, err := client.GetAll(ctx, q, &got); err != nil {
+		t.Fatalf("GetAll with a single inequality property failed: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+}
+
+func TestKeyFiltersUseClientNamespace(t *testing.T) {
+	var filter map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query struct {
+				Filter map[string]any `json:"filter"`
+			} `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		filter = req.Query.Filter
+		writeJSON(t, w, map[string]any{"batch": map[string]any{"moreResults": "NO_MORE_RESULTS"}})
+	}, datastore.WithNamespace("tenant"))
+
+	a, b := datastore.NameKey("Task", "a", nil), datastore.NameKey("Task", "b", nil)
+	q := datastore.NewQuery("Task").KeysOnly().
+		FilterEntity(datastore.OrFilter(
+			datastore.PropertyFilter{FieldName: "__key__", Operator: "=", Value: a},
+			datastore.PropertyFilter{FieldName: "done", Operator: "=", Value: true},
+		)).
+		FilterField("__key__", "IN", []*datastore.Key{a, b})
+	if _, err := client.AllKeys(context.Background(), q); err != nil {
+		t.Fatalf("AllKeys failed: %v", err)
+	}
+
+	// Collect the namespace of every key the filter compares __key__ against
+	var namespaces []any
+	var walk func(f map[string]any)
+	walk = func(f map[string]any) {
+		if comp, ok := f["compositeFilter"].(map[string]any); ok {
+			subs, _ := comp["filters"].([]any) //nolint:errcheck // Checked by the count below
+			for _, sub := range subs {
+				if m, ok := sub.(map[string]any); ok {
+					walk(m)
+				}
+			}
+			return
+		}
+		prop, _ := f["propertyFilter"].(map[string]any) //nolint:errcheck // Checked by the count below
+		if name, _ := prop["property"].(map[string]any)["name"].(string); name != "__key__" {
+			return
+		}
+		value, _ := prop["value"].(map[string]any) //nolint:errcheck // Checked by the count below
+		values := []any{value}
+		if arr, ok := value["arrayValue"].(map[string]any); ok {
+			values, _ = arr["values"].([]any) //nolint:errcheck // Checked by the count below
+		}
+		for _, v := range values {
+			kv, _ := v.(map[string]any)["keyValue"].(map[string]any) //nolint:errcheck // Checked below
+			partition, _ := kv["partitionId"].(map[string]any)       //nolint:errcheck // Checked below
+			namespaces = append(namespaces, partition["namespaceId"])
+		}
+	}
+	walk(filter)
+
+	if want := []any{"tenant", "tenant", "tenant"}; !reflect.DeepEqual(namespaces, want) {
+		t.Errorf("expected filter keys in namespaces %v, got %v in %v", want, namespaces, filter)
+	}
+}
+
+func TestKeyFilterSendsKeyValue(t *testing.T) {
+	var filter map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query struct {
+				Filter map[string]any `json:"filter"`
+			} `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		filter = req.Query.Filter
+		writeJSON(t, w, map[string]any{"batch": map[string]any{"moreResults": "NO_MORE_RESULTS"}})
+	})
+
+	parent := datastore.NameKey("Parent", "p", nil)
+	key := datastore.IDKey("Child", 42, parent)
+	if _, err := client.AllKeys(context.Background(), datastore.NewQuery("Child").KeysOnly().FilterField("__key__", ">", key)); err != nil {
+		t.Fatalf("AllKeys failed: %v", err)
+	}
+
+	propFilter, _ := filter["propertyFilter"].(map[string]any) //nolint:errcheck // Checked below
+	value, _ := propFilter["value"].(map[string]any)           //nolint:errcheck // Checked below
+	keyValue, ok := value["keyValue"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected a keyValue filter value, got %v", propFilter["value"])
+	}
+	want := []any{
+		map[string]any{"kind": "Parent", "name": "p"},
+		map[string]any{"kind": "Child", "id": "42"},
+	}
+	if !reflect.DeepEqual(keyValue["path"], want) {
+		t.Errorf("expected key path %v, got %v", want, keyValue["path"])
+	}
+}
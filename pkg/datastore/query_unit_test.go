@@ -1,7 +1,10 @@
 package datastore
 
 import (
+	"errors"
+	"maps"
 	"testing"
+	"time"
 )
 
 func TestQueryFilter(t *testing.T) {
@@ -213,9 +216,29 @@ func TestQueryChaining(t *testing.T) {
 	}
 }
 
+func mustBuildQueryMap(t *testing.T, q *Query) map[string]any {
+	t.Helper()
+	queryMap, err := buildQueryMap(q)
+	if err != nil {
+		t.Fatalf("buildQueryMap failed: %v", err)
+	}
+	return queryMap
+}
+
+func TestBuildQueryMapUnencodableFilter(t *testing.T) {
+	for name, q := range map[string]*Query{
+		"FilterField":  NewQuery("TestKind").FilterField("Count", "=", make(chan int)),
+		"FilterEntity": NewQuery("TestKind").FilterEntity(AndFilter(PropertyFilter{FieldName: "Count", Operator: "=", Value: func() {}})),
+	} {
+		if _, err := buildQueryMap(q); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: expected ErrInvalidQuery, got %v", name, err)
+		}
+	}
+}
+
 func TestBuildQueryMapBasic(t *testing.T) {
 	q := NewQuery("TestKind")
-	queryMap := buildQueryMap(q)
+	queryMap := mustBuildQueryMap(t, q)
 
 	kind, ok := queryMap["kind"].([]map[string]any)
 	if !ok || len(kind) == 0 {
@@ -229,7 +252,7 @@ func TestBuildQueryMapBasic(t *testing.T) {
 
 func TestBuildQueryMapWithLimit(t *testing.T) {
 	q := NewQuery("TestKind").Limit(10)
-	queryMap := buildQueryMap(q)
+	queryMap := mustBuildQueryMap(t, q)
 
 	limit, ok := queryMap["limit"]
 	if !ok {
@@ -243,7 +266,7 @@ func TestBuildQueryMapWithLimit(t *testing.T) {
 
 func TestBuildQueryMapWithOffset(t *testing.T) {
 	q := NewQuery("TestKind").Offset(5)
-	queryMap := buildQueryMap(q)
+	queryMap := mustBuildQueryMap(t, q)
 
 	offset, ok := queryMap["offset"]
 	if !ok {
@@ -257,7 +280,7 @@ func TestBuildQueryMapWithOffset(t *testing.T) {
 
 func TestBuildQueryMapWithFilter(t *testing.T) {
 	q := NewQuery("TestKind").FilterField("Count", ">", 10)
-	queryMap := buildQueryMap(q)
+	queryMap := mustBuildQueryMap(t, q)
 
 	_, ok := queryMap["filter"]
 	if !ok {
@@ -265,9 +288,89 @@ func TestBuildQueryMapWithFilter(t *testing.T) {
 	}
 }
 
+func TestBuildQueryMapWithTimeFilter(t *testing.T) {
+	cutoff := time.Date(2024, 3, 1, 12, 30, 0, 500, time.UTC)
+	q := NewQuery("TestKind").Filter("created_at >", cutoff)
+	queryMap := mustBuildQueryMap(t, q)
+
+	filter, ok := queryMap["filter"].(map[string]any)["propertyFilter"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected property filter, got %v", queryMap["filter"])
+	}
+	value, ok := filter["value"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected encoded filter value, got %v", filter["value"])
+	}
+	if value["timestampValue"] != "2024-03-01T12:30:00.0000005Z" {
+		t.Errorf("Expected timestampValue, got %v", value)
+	}
+}
+
+func TestBuildQueryMapWithOrFilter(t *testing.T) {
+	q := NewQuery("Task").FilterEntity(OrFilter(
+		PropertyFilter{FieldName: "status", Operator: "=", Value: "open"},
+		PropertyFilter{FieldName: "priority", Operator: ">", Value: 5},
+	))
+	queryMap := mustBuildQueryMap(t, q)
+
+	composite, ok := queryMap["filter"].(map[string]any)["compositeFilter"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected composite filter, got %v", queryMap["filter"])
+	}
+	if composite["op"] != "OR" {
+		t.Errorf("Expected OR, got %v", composite["op"])
+	}
+	filters, ok := composite["filters"].([]map[string]any)
+	if !ok || len(filters) != 2 {
+		t.Fatalf("Expected 2 sub-filters, got %v", composite["filters"])
+	}
+
+	want := []struct {
+		name, op string
+		value    map[string]any
+	}{
+		{"status", "EQUAL", map[string]any{"stringValue": "open"}},
+		{"priority", "GREATER_THAN", map[string]any{"integerValue": "5"}},
+	}
+	for i, w := range want {
+		pf, ok := filters[i]["propertyFilter"].(map[string]any)
+		if !ok {
+			t.Fatalf("Expected property filter %d, got %v", i, filters[i])
+		}
+		if pf["property"].(map[string]string)["name"] != w.name || pf["op"] != w.op {
+			t.Errorf("Filter %d: expected %s %s, got %v", i, w.name, w.op, pf)
+		}
+		if !maps.Equal(pf["value"].(map[string]any), w.value) {
+			t.Errorf("Filter %d: expected value %v, got %v", i, w.value, pf["value"])
+		}
+	}
+}
+
+func TestBuildQueryMapFilterEntityWithFilter(t *testing.T) {
+	q := NewQuery("Task").
+		FilterField("done", "=", false).
+		FilterEntity(OrFilter(
+			PropertyFilter{FieldName: "status", Operator: "=", Value: "open"},
+			PropertyFilter{FieldName: "status", Operator: "=", Value: "blocked"},
+		))
+	queryMap := mustBuildQueryMap(t, q)
+
+	composite, ok := queryMap["filter"].(map[string]any)["compositeFilter"].(map[string]any)
+	if !ok || composite["op"] != "AND" {
+		t.Fatalf("Expected simple and entity filters to be ANDed, got %v", queryMap["filter"])
+	}
+	filters, ok := composite["filters"].([]map[string]any)
+	if !ok || len(filters) != 2 {
+		t.Fatalf("Expected 2 sub-filters, got %v", composite["filters"])
+	}
+	if _, ok := filters[1]["compositeFilter"]; !ok {
+		t.Errorf("Expected the OR filter to be nested, got %v", filters[1])
+	}
+}
+
 func TestBuildQueryMapWithOrder(t *testing.T) {
 	q := NewQuery("TestKind").Order("-Count")
-	queryMap := buildQueryMap(q)
+	queryMap := mustBuildQueryMap(t, q)
 
 	orders, ok := queryMap["order"].([]map[string]any)
 	if !ok || len(orders) == 0 {
@@ -281,7 +384,7 @@ func TestBuildQueryMapWithOrder(t *testing.T) {
 
 func TestBuildQueryMapKeysOnly(t *testing.T) {
 	q := NewQuery("TestKind").KeysOnly()
-	queryMap := buildQueryMap(q)
+	queryMap := mustBuildQueryMap(t, q)
 
 	projection, ok := queryMap["projection"].([]map[string]any)
 	if !ok || len(projection) == 0 {
@@ -289,6 +392,22 @@ func TestBuildQueryMapKeysOnly(t *testing.T) {
 	}
 }
 
+func TestBuildQueryMapProjection(t *testing.T) {
+	q := NewQuery("TestKind").Project("name", "count")
+	queryMap := mustBuildQueryMap(t, q)
+
+	projection, ok := queryMap["projection"].([]map[string]any)
+	if !ok || len(projection) != 2 {
+		t.Fatalf("Expected 2 projected properties, got %v", queryMap["projection"])
+	}
+	for i, want := range []string{"name", "count"} {
+		prop, ok := projection[i]["property"].(map[string]string)
+		if !ok || prop["name"] != want {
+			t.Errorf("Expected projection %d to be %q, got %v", i, want, projection[i])
+		}
+	}
+}
+
 func TestQueryStart(t *testing.T) {
 	cursor := Cursor("test-start-cursor")
 	q := NewQuery("TestKind").Start(cursor)
@@ -0,0 +1,46 @@
+package datastore
+
+import (
+	"context"
+	"strings"
+)
+
+// Tracer starts spans around the client's RPCs, so Datastore calls show up in
+// the caller's traces without ds9 depending on a tracing library.
+// Spans are named "datastore.<method>", e.g. "datastore.commit", and attrs are
+// alternating keys and values: "method", and "entities" when the number of
+// entities in the request is known. end is called once the RPC completes,
+// with its error if it failed.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string, attrs ...any) (spanCtx context.Context, end func(err error))
+}
+
+// WithTracer returns a ClientOption that traces RPCs with t.
+func WithTracer(t Tracer) ClientOption {
+	return func(o *clientOptionsInternal) {
+		o.tracer = t
+	}
+}
+
+// unknownEntities is passed to startSpan for RPCs whose entity count isn't
+// known up front, such as queries.
+const unknownEntities = -1
+
+// startSpan starts a span for the RPC at url, which ends in ":<method>".
+// Without a tracer, it returns ctx and a no-op end function.
+func (c *Client) startSpan(ctx context.Context, url string, entities int) (context.Context, func(error)) {
+	if c.tracer == nil {
+		return ctx, func(error) {}
+	}
+	method := rpcMethod(url)
+	attrs := []any{"method", method}
+	if entities != unknownEntities {
+		attrs = append(attrs, "entities", entities)
+	}
+	return c.tracer.StartSpan(ctx, "datastore."+method, attrs...)
+}
+
+// rpcMethod returns the RPC name from a URL ending in ":<method>".
+func rpcMethod(url string) string {
+	return url[strings.LastIndex(url, ":")+1:]
+}
@@ -0,0 +1,111 @@
+package datastore_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/codeGROOVE-dev/ds9/auth"
+	"github.com/codeGROOVE-dev/ds9/pkg/datastore"
+	"github.com/codeGROOVE-dev/ds9/pkg/mock"
+)
+
+type span struct {
+	name  string
+	attrs []any
+	ended bool
+	err   error
+}
+
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*span
+}
+
+func (f *fakeTracer) StartSpan(ctx context.Context, name string, attrs ...any) (context.Context, func(error)) {
+	s := &span{name: name, attrs: attrs}
+	f.mu.Lock()
+	f.spans = append(f.spans, s)
+	f.mu.Unlock()
+	return ctx, func(err error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		s.ended, s.err = true, err
+	}
+}
+
+func (f *fakeTracer) names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for _, s := range f.spans {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func TestWithTracer(t *testing.T) {
+	metadataURL, apiURL, cleanup := mock.NewMockServers(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	tracer := &fakeTracer{}
+	client, err := datastore.NewClient(ctx, "test-project",
+		datastore.WithEndpoint(apiURL),
+		datastore.WithAuth(&auth.Config{MetadataURL: metadataURL, SkipADC: true}),
+		datastore.WithTracer(tracer),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	key := datastore.NameKey("Traced", "a", nil)
+	if _, err := client.Put(ctx, key, &testEntity{Name: "a"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("expected 1 span, got %v", tracer.names())
+	}
+	s := tracer.spans[0]
+	if s.name != "datastore.commit" {
+		t.Errorf("expected span datastore.commit, got %q", s.name)
+	}
+	want := []any{"method", "commit", "entities", 1}
+	if len(s.attrs) != len(want) {
+		t.Fatalf("expected attrs %v, got %v", want, s.attrs)
+	}
+	for i := range want {
+		if s.attrs[i] != want[i] {
+			t.Errorf("expected attrs %v, got %v", want, s.attrs)
+			break
+		}
+	}
+	if !s.ended || s.err != nil {
+		t.Errorf("expected span ended without error, got ended=%v err=%v", s.ended, s.err)
+	}
+
+	tracer.spans = nil
+	if _, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var e testEntity
+		if err := tx.Get(key, &e); err != nil {
+			return err
+		}
+		_, err := tx.Put(key, &e)
+		return err
+	}); err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+
+	got := tracer.names()
+	wantNames := []string{"datastore.beginTransaction", "datastore.lookup", "datastore.commit"}
+	if len(got) != len(wantNames) {
+		t.Fatalf("expected spans %v, got %v", wantNames, got)
+	}
+	for i := range wantNames {
+		if got[i] != wantNames[i] {
+			t.Errorf("expected spans %v, got %v", wantNames, got)
+			break
+		}
+	}
+}
@@ -1,23 +1,43 @@
 package datastore
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	neturl "net/url"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/codeGROOVE-dev/ds9/auth"
 )
 
+// transactionRetry sends beginTransaction and transactional commits once:
+// RunInTransaction retries a transaction that fails to begin or commit as a
+// whole, re-running its function.
+var transactionRetry = RetryConfig{MaxAttempts: 1}
+
 // Commit represents the result of a committed transaction.
 // This is provided for API compatibility with cloud.google.com/go/datastore.
-type Commit struct{}
+type Commit struct {
+	stats TransactionStats
+}
+
+// Stats returns the work done by the committed transaction attempt.
+func (c *Commit) Stats() TransactionStats {
+	return c.stats
+}
+
+// TransactionStats records the RPC traffic of a transaction, for cost analysis.
+// Lookups served from the transaction's read cache are not counted.
+type TransactionStats struct {
+	Lookups      int   // lookup RPCs sent
+	BytesRead    int64 // response bytes received for lookups and the commit
+	BytesWritten int64 // request bytes sent for lookups and the commit
+	CommitBytes  int   // size of the commit request
+}
 
 // Transaction represents a Datastore transaction.
 // Note: This struct stores context for API compatibility with Google's official
@@ -27,6 +47,16 @@ type Transaction struct {
 	client    *Client
 	id        string
 	mutations []map[string]any
+	reads     map[string]*txRead // lookup results by key; nil if missing
+	stats     TransactionStats
+	attempt   int
+}
+
+// txRead is an entity read in a transaction, kept so repeated reads of its key
+// see the same snapshot.
+type txRead struct {
+	entity  map[string]any
+	version int64
 }
 
 // TransactionOption configures transaction behavior.
@@ -34,9 +64,13 @@ type TransactionOption interface {
 	apply(*transactionSettings)
 }
 
+// txMarkerKey is the context key marking a context as belonging to a running transaction.
+type txMarkerKey struct{}
+
 type transactionSettings struct {
 	readTime    time.Time
 	maxAttempts int
+	readOnly    bool
 }
 
 type maxAttemptsOption int
@@ -61,10 +95,23 @@ func (o readTimeOption) apply(s *transactionSettings) {
 
 // WithReadTime returns a TransactionOption that sets a specific timestamp
 // at which to read data, enabling reading from a particular snapshot in time.
+// It implies ReadOnly.
 func WithReadTime(t time.Time) TransactionOption {
 	return readTimeOption{t: t}
 }
 
+type readOnlyOption struct{}
+
+func (readOnlyOption) apply(s *transactionSettings) {
+	s.readOnly = true
+}
+
+// ReadOnly is a TransactionOption that makes the transaction read-only.
+// Read-only transactions read a consistent snapshot and never abort because
+// of contention, but fail if they write.
+// API compatible with cloud.google.com/go/datastore.
+var ReadOnly TransactionOption = readOnlyOption{}
+
 // NewTransaction creates a new transaction.
 // The caller must call Commit or Rollback when done.
 // API compatible with cloud.google.com/go/datastore.
@@ -82,19 +129,36 @@ func (c *Client) NewTransaction(ctx context.Context, opts ...TransactionOption)
 		return nil, fmt.Errorf("failed to get access token: %w", err)
 	}
 
-	// Begin transaction
+	txID, err := c.beginTransaction(ctx, token, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Transaction{
+		ctx:     ctx,
+		client:  c,
+		id:      txID,
+		attempt: 1,
+	}
+
+	c.logger.DebugContext(ctx, "transaction begun", "transaction", tx.id)
+	return tx, nil
+}
+
+// beginTransaction starts a transaction configured by settings and returns its ID.
+func (c *Client) beginTransaction(ctx context.Context, token string, settings transactionSettings) (string, error) {
 	reqBody := map[string]any{}
 	if c.databaseID != "" {
 		reqBody["databaseId"] = c.databaseID
 	}
 
 	// Add transaction options if needed
-	if !settings.readTime.IsZero() {
-		reqBody["transactionOptions"] = map[string]any{
-			"readOnly": map[string]any{
-				"readTime": settings.readTime.Format(time.RFC3339Nano),
-			},
+	if settings.readOnly || !settings.readTime.IsZero() {
+		readOnly := map[string]any{}
+		if !settings.readTime.IsZero() {
+			readOnly["readTime"] = settings.readTime.Format(time.RFC3339Nano)
 		}
+		reqBody["transactionOptions"] = map[string]any{"readOnly": readOnly}
 	} else {
 		reqBody["transactionOptions"] = map[string]any{
 			"readWrite": map[string]any{},
@@ -103,42 +167,14 @@ func (c *Client) NewTransaction(ctx context.Context, opts ...TransactionOption)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	// URL-encode project ID to prevent injection attacks
 	reqURL := fmt.Sprintf("%s/projects/%s:beginTransaction", c.baseURL, neturl.PathEscape(c.projectID))
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(jsonData))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	// Add routing header for named databases
-	if c.databaseID != "" {
-		// URL-encode values to prevent header injection attacks
-		routingHeader := fmt.Sprintf("project_id=%s&database_id=%s", neturl.QueryEscape(c.projectID), neturl.QueryEscape(c.databaseID))
-		req.Header.Set("X-Goog-Request-Params", routingHeader)
-	}
-
-	resp, err := httpClient.Do(req)
+	body, err := c.doRequest(ctx, reqURL, jsonData, token, transactionRetry, unknownEntities)
 	if err != nil {
-		return nil, err
-	}
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
-	closeErr := resp.Body.Close()
-	if closeErr != nil {
-		c.logger.Warn("failed to close response body", "error", closeErr)
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("begin transaction failed with status %d: %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("begin transaction failed: %w", err)
 	}
 
 	var txResp struct {
@@ -146,22 +182,38 @@ func (c *Client) NewTransaction(ctx context.Context, opts ...TransactionOption)
 	}
 
 	if err := json.Unmarshal(body, &txResp); err != nil {
-		return nil, fmt.Errorf("failed to parse transaction response: %w", err)
+		return "", fmt.Errorf("failed to parse transaction response: %w", err)
 	}
 
-	tx := &Transaction{
-		ctx:    ctx,
-		client: c,
-		id:     txResp.Transaction,
-	}
-
-	return tx, nil
+	return txResp.Transaction, nil
 }
 
 // RunInTransaction runs a function in a transaction.
 // The function should use the transaction's Get and Put methods.
+// If the function returns an error the transaction is rolled back; returning
+// ErrAbort rolls back without RunInTransaction reporting an error.
+// Use RunInTransactionContext if the function needs the transaction's context.
 // API compatible with cloud.google.com/go/datastore.
 func (c *Client) RunInTransaction(ctx context.Context, f func(*Transaction) error, opts ...TransactionOption) (*Commit, error) {
+	return c.RunInTransactionContext(ctx, func(_ context.Context, tx *Transaction) error {
+		return f(tx)
+	}, opts...)
+}
+
+// RunInTransactionContext is like RunInTransaction, but passes the transaction's
+// context to f so cancellation and deadlines propagate into work done alongside
+// the transaction. If the context is canceled by the time f returns, the
+// transaction is rolled back instead of committed.
+//
+// Migrating is mechanical: func(tx *Transaction) error becomes
+// func(ctx context.Context, tx *Transaction) error.
+func (c *Client) RunInTransactionContext(
+	ctx context.Context, f func(context.Context, *Transaction) error, opts ...TransactionOption,
+) (*Commit, error) {
+	if ctx.Value(txMarkerKey{}) != nil {
+		c.logger.WarnContext(ctx, "RunInTransaction called from within a transaction")
+		return nil, ErrNestedTransaction
+	}
 	ctx = c.withClientConfig(ctx)
 	settings := transactionSettings{
 		maxAttempts: 3, // default
@@ -178,93 +230,64 @@ func (c *Client) RunInTransaction(ctx context.Context, f func(*Transaction) erro
 			return nil, fmt.Errorf("failed to get access token: %w", err)
 		}
 
-		// Begin transaction
-		reqBody := map[string]any{}
-		if c.databaseID != "" {
-			reqBody["databaseId"] = c.databaseID
-		}
-
-		// Add transaction options if needed
-		if !settings.readTime.IsZero() {
-			reqBody["transactionOptions"] = map[string]any{
-				"readOnly": map[string]any{
-					"readTime": settings.readTime.Format(time.RFC3339Nano),
-				},
+		txID, err := c.beginTransaction(ctx, token, settings)
+		if err != nil {
+			// Contention or a brief outage while beginning is retried like an aborted commit
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) || (apiErr.code() != "ABORTED" && apiErr.code() != "UNAVAILABLE") {
+				return nil, err
 			}
-		} else {
-			reqBody["transactionOptions"] = map[string]any{
-				"readWrite": map[string]any{},
+			lastErr = err
+			c.logger.WarnContext(ctx, "transaction begin failed, will retry",
+				"attempt", attempt+1,
+				"max_attempts", settings.maxAttempts,
+				"error", err)
+			if attempt < settings.maxAttempts-1 {
+				backoffMS := 100 * (1 << attempt)
+				c.logger.DebugContext(ctx, "sleeping before retry", "backoff_ms", backoffMS)
+				time.Sleep(time.Duration(backoffMS) * time.Millisecond)
 			}
+			continue
 		}
 
-		jsonData, err := json.Marshal(reqBody)
-		if err != nil {
-			return nil, err
-		}
-
-		// URL-encode project ID to prevent injection attacks
-		reqURL := fmt.Sprintf("%s/projects/%s:beginTransaction", c.baseURL, neturl.PathEscape(c.projectID))
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(jsonData))
-		if err != nil {
-			return nil, err
-		}
-
-		req.Header.Set("Authorization", "Bearer "+token)
-		req.Header.Set("Content-Type", "application/json")
-
-		// Add routing header for named databases
-		if c.databaseID != "" {
-			// URL-encode values to prevent header injection attacks
-			routingHeader := fmt.Sprintf("project_id=%s&database_id=%s", neturl.QueryEscape(c.projectID), neturl.QueryEscape(c.databaseID))
-			req.Header.Set("X-Goog-Request-Params", routingHeader)
-		}
-
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			return nil, err
+		tx := &Transaction{
+			ctx:     context.WithValue(ctx, txMarkerKey{}, txID),
+			client:  c,
+			id:      txID,
+			attempt: attempt + 1,
 		}
+		c.logger.DebugContext(ctx, "transaction begun", "transaction", tx.id, "attempt", attempt+1)
 
-		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
-		closeErr := resp.Body.Close()
-		if closeErr != nil {
-			c.logger.Warn("failed to close response body", "error", closeErr)
+		// Run the function; a canceled context aborts the transaction like an error would
+		err = f(tx.ctx, tx)
+		if err == nil {
+			err = ctx.Err()
 		}
 		if err != nil {
-			return nil, err
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("begin transaction failed with status %d: %s", resp.StatusCode, string(body))
-		}
-
-		var txResp struct {
-			Transaction string `json:"transaction"`
-		}
-
-		if err := json.Unmarshal(body, &txResp); err != nil {
-			return nil, fmt.Errorf("failed to parse transaction response: %w", err)
-		}
-
-		tx := &Transaction{
-			ctx:    ctx,
-			client: c,
-			id:     txResp.Transaction,
-		}
-
-		// Run the function
-		if err := f(tx); err != nil {
-			// Rollback is implicit if commit is not called
+			// Roll back even if ctx is canceled so the server releases the transaction promptly
+			if rbErr := tx.doRollback(context.WithoutCancel(ctx), token); rbErr != nil {
+				c.logger.WarnContext(ctx, "transaction rollback failed", "error", rbErr)
+			}
+			if errors.Is(err, ErrAbort) {
+				c.logger.DebugContext(ctx, "transaction aborted by caller", "attempt", attempt+1)
+				return nil, nil
+			}
 			return nil, err
 		}
 
 		// Commit the transaction
 		err = tx.doCommit(ctx, token)
 		if err == nil {
-			c.logger.Debug("transaction committed successfully", "attempt", attempt+1)
-			return &Commit{}, nil // Success
+			c.logger.DebugContext(ctx, "transaction committed successfully", "attempt", attempt+1)
+			return &Commit{stats: tx.stats}, nil // Success
 		}
 
-		c.logger.Warn("transaction commit failed", "attempt", attempt+1, "error", err)
+		c.logger.WarnContext(ctx, "transaction commit failed", "attempt", attempt+1, "error", err)
+
+		// An insert conflict shares status 409 with ABORTED but retrying can't help
+		if errors.Is(err, ErrAlreadyExists) {
+			return nil, err
+		}
 
 		// Check if error contains 409 ABORTED - if so, retry
 		errStr := err.Error()
@@ -273,7 +296,7 @@ func (c *Client) RunInTransaction(ctx context.Context, f func(*Transaction) erro
 
 		if is409 || isAborted {
 			lastErr = err
-			c.logger.Warn("transaction aborted, will retry",
+			c.logger.WarnContext(ctx, "transaction aborted, will retry",
 				"attempt", attempt+1,
 				"max_attempts", settings.maxAttempts,
 				"has_409", is409,
@@ -283,27 +306,84 @@ func (c *Client) RunInTransaction(ctx context.Context, f func(*Transaction) erro
 			// Exponential backoff: 100ms, 200ms, 400ms
 			if attempt < settings.maxAttempts-1 {
 				backoffMS := 100 * (1 << attempt)
-				c.logger.Debug("sleeping before retry", "backoff_ms", backoffMS)
+				c.logger.DebugContext(ctx, "sleeping before retry", "backoff_ms", backoffMS)
 				time.Sleep(time.Duration(backoffMS) * time.Millisecond)
 			}
 			continue
 		}
 
 		// Non-retriable error
-		c.logger.Warn("non-retriable transaction error", "error", err)
+		c.logger.WarnContext(ctx, "non-retriable transaction error", "error", err)
 		return nil, err
 	}
 
 	return nil, fmt.Errorf("transaction failed after %d attempts: %w", settings.maxAttempts, lastErr)
 }
 
+// RunInReadOnlyTransaction runs f in a read-only transaction, for reading
+// several entities consistently. Read-only transactions don't abort on
+// contention, so f runs exactly once and is never retried. Writes made by f
+// fail when the transaction commits.
+func (c *Client) RunInReadOnlyTransaction(ctx context.Context, f func(*Transaction) error, opts ...TransactionOption) error {
+	_, err := c.RunInTransaction(ctx, f, slices.Concat(opts, []TransactionOption{ReadOnly, MaxAttempts(1)})...)
+	return err
+}
+
+// GetOrCreate loads the entity with the given key into dst, creating it first
+// if it doesn't exist: create is called to produce the new entity, which is
+// stored under key and then loaded into dst. The lookup and the write run in
+// one transaction, so concurrent callers can't both create the entity.
+// create may be called more than once if the transaction is retried.
+// key must be complete.
+func (c *Client) GetOrCreate(ctx context.Context, key *Key, dst any, create func() any) error {
+	if key == nil || key.Incomplete() {
+		return ErrInvalidKey
+	}
+
+	_, err := c.RunInTransaction(ctx, func(tx *Transaction) error {
+		err := tx.Get(key, dst)
+		if !errors.Is(err, ErrNoSuchEntity) {
+			return err
+		}
+
+		src := create()
+		if _, err := tx.Put(key, src); err != nil {
+			return err
+		}
+		entity, err := encodeEntity(key, src)
+		if err != nil {
+			return err
+		}
+		return decodeEntity(entity, dst)
+	})
+	return err
+}
+
+// Attempt returns which attempt of RunInTransaction tx belongs to, starting
+// at 1. The callback is re-run on each retry, so a value above 1 means an
+// earlier attempt's commit was aborted and its writes were discarded.
+// Transactions from NewTransaction always report 1.
+func (tx *Transaction) Attempt() int {
+	return tx.attempt
+}
+
 // Get retrieves an entity within the transaction.
+// Every read in a transaction sees the same snapshot, so repeated reads of a
+// key are served from the transaction's cache without another lookup.
 // API compatible with cloud.google.com/go/datastore.
 func (tx *Transaction) Get(key *Key, dst any) error {
 	if key == nil {
 		return ErrInvalidKey
 	}
 
+	cacheKey := key.String()
+	if read, ok := tx.reads[cacheKey]; ok {
+		if read == nil {
+			return ErrNoSuchEntity
+		}
+		return read.decode(dst)
+	}
+
 	token, err := auth.AccessToken(tx.ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get access token: %w", err)
@@ -311,7 +391,7 @@ func (tx *Transaction) Get(key *Key, dst any) error {
 
 	reqBody := map[string]any{
 		"keys": []map[string]any{
-			keyToJSON(key),
+			keyToJSON(tx.client.outKey(key)),
 		},
 		"readOptions": map[string]any{
 			"transaction": tx.id,
@@ -329,68 +409,61 @@ func (tx *Transaction) Get(key *Key, dst any) error {
 
 	// URL-encode project ID to prevent injection attacks
 	reqURL := fmt.Sprintf("%s/projects/%s:lookup", tx.client.baseURL, neturl.PathEscape(tx.client.projectID))
-	req, err := http.NewRequestWithContext(tx.ctx, http.MethodPost, reqURL, bytes.NewReader(jsonData))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-
-	// Add routing header for named databases
-	if tx.client.databaseID != "" {
-		// URL-encode values to prevent header injection attacks
-		routingHeader := fmt.Sprintf("project_id=%s&database_id=%s",
-			neturl.QueryEscape(tx.client.projectID),
-			neturl.QueryEscape(tx.client.databaseID))
-		req.Header.Set("X-Goog-Request-Params", routingHeader)
-	}
-
-	resp, err := httpClient.Do(req)
+	tx.stats.Lookups++
+	tx.stats.BytesWritten += int64(len(jsonData))
+	body, err := tx.client.doRequest(tx.ctx, reqURL, jsonData, token, tx.client.readRetry, 1)
 	if err != nil {
-		return err
-	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			tx.client.logger.Warn("failed to close response body", "error", closeErr)
-		}
-	}()
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
-	if err != nil {
-		return err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("transaction get failed with status %d: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("transaction get failed: %w", err)
 	}
+	tx.stats.BytesRead += int64(len(body))
 
 	var result struct {
-		Found []struct {
-			Entity map[string]any `json:"entity"`
+		ReadTime string `json:"readTime"`
+		Found    []struct {
+			Entity  map[string]any `json:"entity"`
+			Version int64          `json:"version,string"`
 		} `json:"found"`
 		Missing []struct{} `json:"missing"`
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := unmarshalResponse(body, &result); err != nil {
 		return fmt.Errorf("failed to parse response: %w", err)
 	}
+	recordReadTime(tx.ctx, result.ReadTime)
+
+	if tx.reads == nil {
+		tx.reads = make(map[string]*txRead)
+	}
 
 	if len(result.Found) == 0 {
+		tx.reads[cacheKey] = nil
 		return ErrNoSuchEntity
 	}
 
-	return decodeEntity(result.Found[0].Entity, dst)
+	read := &txRead{entity: tx.client.inEntity(result.Found[0].Entity), version: result.Found[0].Version}
+	tx.reads[cacheKey] = read
+	return read.decode(dst)
+}
+
+// decode decodes the read entity into dst, with its version.
+func (r *txRead) decode(dst any) error {
+	if err := decodeEntity(r.entity, dst); err != nil {
+		return err
+	}
+	setVersion(dst, r.version)
+	return nil
 }
 
 // Put stores an entity within the transaction.
+// Writes are buffered and sent with the commit, so a transaction that only
+// writes issues no lookups.
 func (tx *Transaction) Put(key *Key, src any) (*Key, error) {
 	if key == nil {
 		return nil, ErrInvalidKey
 	}
 
 	// Encode the entity
-	entity, err := encodeEntity(key, src)
+	entity, err := tx.client.encodeForWrite(tx.ctx, key, src)
 	if err != nil {
 		return nil, err
 	}
@@ -415,7 +488,7 @@ func (tx *Transaction) Delete(key *Key) error {
 
 	// Create delete mutation
 	mutation := map[string]any{
-		"delete": keyToJSON(key),
+		"delete": keyToJSON(tx.client.outKey(key)),
 	}
 
 	// Accumulate mutation for commit
@@ -500,6 +573,13 @@ func (tx *Transaction) PutMulti(keys []*Key, src any) ([]*Key, error) {
 	return keys, nil
 }
 
+// AllocateIDs allocates IDs for incomplete keys from within the transaction,
+// so the returned keys can be used by its Put calls. As in Datastore, the IDs
+// are reserved immediately and are not reused even if the transaction rolls back.
+func (tx *Transaction) AllocateIDs(keys []*Key) ([]*Key, error) {
+	return tx.client.AllocateIDs(tx.ctx, keys)
+}
+
 // Commit applies the transaction's mutations.
 // API compatible with cloud.google.com/go/datastore.
 func (tx *Transaction) Commit() (*Commit, error) {
@@ -512,16 +592,21 @@ func (tx *Transaction) Commit() (*Commit, error) {
 		return nil, err
 	}
 
-	return &Commit{}, nil
+	return &Commit{stats: tx.stats}, nil
 }
 
-// Rollback abandons the transaction.
+// Rollback abandons the transaction, discarding any pending mutations.
 // API compatible with cloud.google.com/go/datastore.
 func (tx *Transaction) Rollback() error {
-	// Datastore transactions are automatically rolled back if not committed
-	// So we just need to clear the mutations to prevent accidental commit
+	// Clear mutations first so a failed rollback can't lead to an accidental commit
 	tx.mutations = nil
-	return nil
+
+	token, err := auth.AccessToken(tx.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	return tx.doRollback(tx.ctx, token)
 }
 
 // Mutate adds one or more mutations to the transaction.
@@ -548,7 +633,7 @@ func (tx *Transaction) Mutate(muts ...*Mutation) ([]*PendingKey, error) {
 			if mut.entity == nil {
 				return nil, fmt.Errorf("insert mutation at index %d has nil entity", i)
 			}
-			entity, err := encodeEntity(mut.key, mut.entity)
+			entity, err := tx.client.encodeForWrite(tx.ctx, mut.key, mut.entity)
 			if err != nil {
 				return nil, fmt.Errorf("failed to encode entity at index %d: %w", i, err)
 			}
@@ -558,7 +643,7 @@ func (tx *Transaction) Mutate(muts ...*Mutation) ([]*PendingKey, error) {
 			if mut.entity == nil {
 				return nil, fmt.Errorf("update mutation at index %d has nil entity", i)
 			}
-			entity, err := encodeEntity(mut.key, mut.entity)
+			entity, err := tx.client.encodeForWrite(tx.ctx, mut.key, mut.entity)
 			if err != nil {
 				return nil, fmt.Errorf("failed to encode entity at index %d: %w", i, err)
 			}
@@ -568,14 +653,14 @@ func (tx *Transaction) Mutate(muts ...*Mutation) ([]*PendingKey, error) {
 			if mut.entity == nil {
 				return nil, fmt.Errorf("upsert mutation at index %d has nil entity", i)
 			}
-			entity, err := encodeEntity(mut.key, mut.entity)
+			entity, err := tx.client.encodeForWrite(tx.ctx, mut.key, mut.entity)
 			if err != nil {
 				return nil, fmt.Errorf("failed to encode entity at index %d: %w", i, err)
 			}
 			mutMap["upsert"] = entity
 
 		case MutationDelete:
-			mutMap["delete"] = keyToJSON(mut.key)
+			mutMap["delete"] = keyToJSON(tx.client.outKey(mut.key))
 
 		default:
 			return nil, fmt.Errorf("unknown mutation operation at index %d: %s", i, mut.op)
@@ -597,60 +682,63 @@ type PendingKey struct {
 	key *Key
 }
 
-// commit commits the transaction.
-func (tx *Transaction) doCommit(ctx context.Context, token string) error {
+// doRollback releases the transaction on the server.
+func (tx *Transaction) doRollback(ctx context.Context, token string) error {
+	tx.mutations = nil
+	tx.client.logger.DebugContext(ctx, "rolling back transaction", "transaction", tx.id)
+
 	reqBody := map[string]any{
-		"mode":        "TRANSACTIONAL",
 		"transaction": tx.id,
-		"mutations":   tx.mutations,
 	}
-
 	if tx.client.databaseID != "" {
 		reqBody["databaseId"] = tx.client.databaseID
 	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// URL-encode project ID to prevent injection attacks
-	reqURL := fmt.Sprintf("%s/projects/%s:commit", tx.client.baseURL, neturl.PathEscape(tx.client.projectID))
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(jsonData))
-	if err != nil {
-		return err
+	reqURL := fmt.Sprintf("%s/projects/%s:rollback", tx.client.baseURL, neturl.PathEscape(tx.client.projectID))
+	if _, err := tx.client.doRequest(ctx, reqURL, jsonData, token, tx.client.readRetry, unknownEntities); err != nil {
+		return fmt.Errorf("rollback failed: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
+	return nil
+}
 
-	// Add routing header for named databases
-	if tx.client.databaseID != "" {
-		// URL-encode values to prevent header injection attacks
-		routingHeader := fmt.Sprintf("project_id=%s&database_id=%s",
-			neturl.QueryEscape(tx.client.projectID),
-			neturl.QueryEscape(tx.client.databaseID))
-		req.Header.Set("X-Goog-Request-Params", routingHeader)
+// doCommit commits the transaction.
+func (tx *Transaction) doCommit(ctx context.Context, token string) error {
+	tx.client.logger.DebugContext(ctx, "committing transaction", "transaction", tx.id, "mutations", len(tx.mutations))
+
+	reqBody := map[string]any{
+		"mode":        "TRANSACTIONAL",
+		"transaction": tx.id,
+		"mutations":   tx.mutations,
 	}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return err
+	if tx.client.databaseID != "" {
+		reqBody["databaseId"] = tx.client.databaseID
 	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			tx.client.logger.Warn("failed to close response body", "error", closeErr)
-		}
-	}()
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("commit failed with status %d: %s", resp.StatusCode, string(body))
+	// URL-encode project ID to prevent injection attacks
+	reqURL := fmt.Sprintf("%s/projects/%s:commit", tx.client.baseURL, neturl.PathEscape(tx.client.projectID))
+	tx.stats.CommitBytes = len(jsonData)
+	tx.stats.BytesWritten += int64(len(jsonData))
+	body, err := tx.client.doRequest(ctx, reqURL, jsonData, token, transactionRetry, len(tx.mutations))
+	if err != nil {
+		if hasUpdate(tx.mutations) {
+			err = missingEntity(err)
+		}
+		return fmt.Errorf("commit failed: %w", err)
 	}
+	tx.stats.BytesRead += int64(len(body))
 
 	return nil
 }
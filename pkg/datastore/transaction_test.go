@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -172,8 +176,13 @@ func TestTransactionWithError(t *testing.T) {
 	}
 
 	// Verify entity was not modified (transaction rolled back)
-	// Note: In a real implementation this would check rollback, but our mock doesn't support it
-	// This test at least exercises the error path
+	var after testEntity
+	if err := client.Get(ctx, key, &after); err != nil {
+		t.Fatalf("Get after rollback failed: %v", err)
+	}
+	if after.Count != 1 {
+		t.Errorf("expected Count 1 after rollback, got %d", after.Count)
+	}
 }
 
 func TestTransactionWithDatabaseID(t *testing.T) {
@@ -327,6 +336,14 @@ func TestTransactionRollback(t *testing.T) {
 	if !strings.Contains(err.Error(), "force rollback") {
 		t.Errorf("expected 'force rollback' error, got: %v", err)
 	}
+
+	var after testEntity
+	if err := client.Get(ctx, key, &after); err != nil {
+		t.Fatalf("Get after rollback failed: %v", err)
+	}
+	if after.Name != "original" || after.Count != 1 {
+		t.Errorf("expected entity unchanged after rollback, got %+v", after)
+	}
 }
 
 func TestTransactionBeginFailure(t *testing.T) {
@@ -393,6 +410,88 @@ func TestTransactionBeginFailure(t *testing.T) {
 	}
 }
 
+func TestTransactionBeginContentionRetry(t *testing.T) {
+	var begins, calls int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "beginTransaction"):
+			begins++
+			if begins <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				writeJSON(t, w, map[string]any{"error": map[string]any{"status": "UNAVAILABLE"}})
+				return
+			}
+			writeJSON(t, w, map[string]any{"transaction": "tx-1"})
+		case strings.Contains(r.URL.Path, "commit"):
+			writeJSON(t, w, map[string]any{"mutationResults": []any{}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	commit, err := client.RunInTransaction(context.Background(), func(tx *datastore.Transaction) error {
+		calls++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+	if commit == nil {
+		t.Fatal("expected a commit")
+	}
+	if begins != 3 {
+		t.Errorf("beginTransaction called %d times, want 3", begins)
+	}
+	if calls != 1 {
+		t.Errorf("callback ran %d times, want 1", calls)
+	}
+
+	// Non-retryable begin errors fail on the first attempt
+	begins = 0
+	client = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		begins++
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(t, w, map[string]any{"error": map[string]any{"status": "INVALID_ARGUMENT"}})
+	})
+	if _, err := client.RunInTransaction(context.Background(), func(*datastore.Transaction) error { return nil }); err == nil {
+		t.Fatal("expected begin failure")
+	}
+	if begins != 1 {
+		t.Errorf("beginTransaction called %d times after 400, want 1", begins)
+	}
+}
+
+func TestTransactionAttempt(t *testing.T) {
+	var commits int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "beginTransaction"):
+			writeJSON(t, w, map[string]any{"transaction": "tx-1"})
+		case strings.Contains(r.URL.Path, "commit"):
+			commits++
+			if commits <= 2 {
+				w.WriteHeader(http.StatusConflict)
+				writeJSON(t, w, map[string]any{"error": map[string]any{"status": "ABORTED"}})
+				return
+			}
+			writeJSON(t, w, map[string]any{"mutationResults": []any{}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	var attempts []int
+	if _, err := client.RunInTransaction(context.Background(), func(tx *datastore.Transaction) error {
+		attempts = append(attempts, tx.Attempt())
+		return nil
+	}); err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+	if !reflect.DeepEqual(attempts, []int{1, 2, 3}) {
+		t.Errorf("attempts = %v, want [1 2 3]", attempts)
+	}
+}
+
 func TestTransactionCommitAbortedRetry(t *testing.T) {
 	// Setup mock servers
 	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1640,6 +1739,77 @@ func TestRunInTransactionErrorReturnsNilCommit(t *testing.T) {
 	}
 }
 
+func TestCommitStats(t *testing.T) {
+	var mu sync.Mutex
+	var commitSize int
+	var lookupRead, lookupWritten int64
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var resp []byte
+		switch r.URL.Path {
+		case "/projects/test-project:beginTransaction":
+			resp = []byte(`{"transaction":"tx-stats"}`)
+		case "/projects/test-project:lookup":
+			resp = []byte(`{"missing":[{"entity":{"key":{"path":[{"kind":"TestKind","name":"x"}]}}}]}`)
+			mu.Lock()
+			lookupWritten += int64(len(body))
+			lookupRead += int64(len(resp))
+			mu.Unlock()
+		case "/projects/test-project:commit":
+			resp = []byte(`{"mutationResults":[{}]}`)
+			mu.Lock()
+			commitSize = len(body)
+			mu.Unlock()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(resp); err != nil {
+			t.Errorf("write failed: %v", err)
+		}
+	})
+
+	const lookups = 3
+	commit, err := client.RunInTransaction(context.Background(), func(tx *datastore.Transaction) error {
+		for i := range lookups {
+			var e testEntity
+			err := tx.Get(datastore.NameKey("TestKind", fmt.Sprintf("k%d", i), nil), &e)
+			if !errors.Is(err, datastore.ErrNoSuchEntity) {
+				return fmt.Errorf("get %d: %w", i, err)
+			}
+		}
+		// Repeated reads are served from the transaction's cache
+		var e testEntity
+		if err := tx.Get(datastore.NameKey("TestKind", "k0", nil), &e); !errors.Is(err, datastore.ErrNoSuchEntity) {
+			return err
+		}
+		_, err := tx.Put(datastore.NameKey("TestKind", "k0", nil), &testEntity{Name: "stats"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+
+	stats := commit.Stats()
+	if stats.Lookups != lookups {
+		t.Errorf("Lookups = %d, want %d", stats.Lookups, lookups)
+	}
+	if stats.CommitBytes == 0 || stats.CommitBytes != commitSize {
+		t.Errorf("CommitBytes = %d, want %d", stats.CommitBytes, commitSize)
+	}
+	if want := lookupWritten + int64(commitSize); stats.BytesWritten != want {
+		t.Errorf("BytesWritten = %d, want %d", stats.BytesWritten, want)
+	}
+	if want := lookupRead + int64(len(`{"mutationResults":[{}]}`)); stats.BytesRead != want {
+		t.Errorf("BytesRead = %d, want %d", stats.BytesRead, want)
+	}
+}
+
 func TestTransactionOptions(t *testing.T) {
 	t.Run("MaxAttempts", func(t *testing.T) {
 		// Test that MaxAttempts option is accepted and sets the retry limit
@@ -1683,7 +1853,7 @@ func TestTransactionOptions(t *testing.T) {
 			var result testEntity
 			return tx.Get(key, &result)
 		}, datastore.WithReadTime(readTime))
-		// Note: ds9mock doesn't actually enforce read-only semantics,
+		// Note: ds9mock reads from the snapshot at begin rather than at readTime,
 		// but we're testing that the option is accepted and doesn't cause errors
 		if err != nil {
 			t.Fatalf("Transaction with WithReadTime failed: %v", err)
@@ -1697,11 +1867,15 @@ func TestTransactionOptions(t *testing.T) {
 		ctx := context.Background()
 		key := datastore.NameKey("TestKind", "test", nil)
 
-		// Test that multiple options can be combined
+		if _, err := client.Put(ctx, key, &testEntity{Name: "test", Count: 42}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+
+		// Test that multiple options can be combined. A readTime makes the
+		// transaction read-only, so it only reads.
 		_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
-			entity := testEntity{Name: "test", Count: 42}
-			_, err := tx.Put(key, &entity)
-			return err
+			var result testEntity
+			return tx.Get(key, &result)
 		}, datastore.MaxAttempts(2), datastore.WithReadTime(time.Now().UTC()))
 		// With mock client, this should succeed
 		if err != nil {
@@ -1709,3 +1883,454 @@ func TestTransactionOptions(t *testing.T) {
 		}
 	})
 }
+
+func TestRunInTransactionRollsBackOnError(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	var rolledBack string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/projects/test-project:beginTransaction":
+			writeJSON(t, w, map[string]any{"transaction": "tx-rollback"})
+		case "/projects/test-project:rollback":
+			mu.Lock()
+			rolledBack, _ = reqBody["transaction"].(string) //nolint:errcheck // checked below
+			mu.Unlock()
+			writeJSON(t, w, map[string]any{})
+		case "/projects/test-project:commit":
+			writeJSON(t, w, map[string]any{"mutationResults": []any{}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ctx := context.Background()
+	key := datastore.NameKey("TestKind", "rollback", nil)
+
+	t.Run("ErrorRollsBack", func(t *testing.T) {
+		paths, rolledBack = nil, ""
+		_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+			if _, err := tx.Put(key, &testEntity{Name: "discarded"}); err != nil {
+				return err
+			}
+			return errors.New("callback failed")
+		})
+		if err == nil || !strings.Contains(err.Error(), "callback failed") {
+			t.Fatalf("expected callback error, got %v", err)
+		}
+		if rolledBack != "tx-rollback" {
+			t.Errorf("expected rollback of tx-rollback, got %q", rolledBack)
+		}
+		for _, p := range paths {
+			if strings.HasSuffix(p, ":commit") {
+				t.Error("commit should not be issued when callback fails")
+			}
+		}
+	})
+
+	t.Run("ErrAbort", func(t *testing.T) {
+		paths, rolledBack = nil, ""
+		commit, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+			if _, err := tx.Put(key, &testEntity{Name: "discarded"}); err != nil {
+				return err
+			}
+			return datastore.ErrAbort
+		})
+		if err != nil {
+			t.Fatalf("expected nil error for ErrAbort, got %v", err)
+		}
+		if commit != nil {
+			t.Errorf("expected nil commit for aborted transaction, got %v", commit)
+		}
+		if rolledBack != "tx-rollback" {
+			t.Errorf("expected rollback of tx-rollback, got %q", rolledBack)
+		}
+	})
+
+	t.Run("ManualRollback", func(t *testing.T) {
+		paths, rolledBack = nil, ""
+		tx, err := client.NewTransaction(ctx)
+		if err != nil {
+			t.Fatalf("NewTransaction failed: %v", err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Rollback failed: %v", err)
+		}
+		if rolledBack != "tx-rollback" {
+			t.Errorf("expected rollback of tx-rollback, got %q", rolledBack)
+		}
+	})
+}
+
+func TestRunInTransactionContext(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request-scoped")
+	key := datastore.NameKey("TxContext", "ctx", nil)
+
+	_, err := client.RunInTransactionContext(ctx, func(ctx context.Context, tx *datastore.Transaction) error {
+		if ctx.Value(ctxKey{}) != "request-scoped" {
+			t.Error("expected callback context to derive from the caller's context")
+		}
+
+		// The transaction context carries the nesting marker.
+		_, err := client.RunInTransaction(ctx, func(*datastore.Transaction) error { return nil })
+		if !errors.Is(err, datastore.ErrNestedTransaction) {
+			t.Errorf("expected ErrNestedTransaction, got %v", err)
+		}
+
+		_, err = tx.Put(key, &testEntity{Name: "committed"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RunInTransactionContext failed: %v", err)
+	}
+
+	var got testEntity
+	if err := client.Get(ctx, key, &got); err != nil || got.Name != "committed" {
+		t.Errorf("expected committed entity, got %+v (err=%v)", got, err)
+	}
+}
+
+func TestRunInTransactionContextCancellation(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	key := datastore.NameKey("TxContext", "canceled", nil)
+
+	_, err := client.RunInTransactionContext(ctx, func(ctx context.Context, tx *datastore.Transaction) error {
+		if _, err := tx.Put(key, &testEntity{Name: "should not commit"}); err != nil {
+			return err
+		}
+		cancel()
+		if ctx.Err() == nil {
+			t.Error("expected callback context to observe cancellation")
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	var got testEntity
+	if err := client.Get(context.Background(), key, &got); !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Errorf("expected entity to be absent after canceled transaction, got %v", err)
+	}
+}
+
+func TestTransactionGetCachesReads(t *testing.T) {
+	var mu sync.Mutex
+	lookups := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/projects/test-project:beginTransaction":
+			writeJSON(t, w, map[string]any{"transaction": "tx-cache"})
+		case "/projects/test-project:lookup":
+			mu.Lock()
+			lookups++
+			mu.Unlock()
+			writeJSON(t, w, map[string]any{"found": []any{
+				map[string]any{"entity": map[string]any{
+					"key":        map[string]any{"path": []any{map[string]any{"kind": "Cached", "name": "k"}}},
+					"properties": map[string]any{"name": map[string]any{"stringValue": "cached"}},
+				}},
+			}})
+		case "/projects/test-project:commit":
+			writeJSON(t, w, map[string]any{})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	key := datastore.NameKey("Cached", "k", nil)
+	_, err := client.RunInTransaction(context.Background(), func(tx *datastore.Transaction) error {
+		for range 2 {
+			var got testEntity
+			if err := tx.Get(key, &got); err != nil {
+				return err
+			}
+			if got.Name != "cached" {
+				t.Errorf("expected name %q, got %q", "cached", got.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+
+	if lookups != 1 {
+		t.Errorf("expected 1 lookup request, got %d", lookups)
+	}
+}
+
+func TestTransactionMutate(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	oldKey := datastore.NameKey("TxMutate", "old", nil)
+	newKey := datastore.NameKey("TxMutate", "new", nil)
+	keepKey := datastore.NameKey("TxMutate", "keep", nil)
+
+	for _, k := range []*datastore.Key{oldKey, keepKey} {
+		if _, err := client.Put(ctx, k, &testEntity{Name: k.Name}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	t.Run("InsertAndDelete", func(t *testing.T) {
+		_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+			_, err := tx.Mutate(
+				datastore.NewInsert(newKey, &testEntity{Name: "new"}),
+				datastore.NewDelete(oldKey),
+			)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("RunInTransaction failed: %v", err)
+		}
+
+		var got testEntity
+		if err := client.Get(ctx, newKey, &got); err != nil || got.Name != "new" {
+			t.Errorf("expected inserted entity, got %+v (err=%v)", got, err)
+		}
+		if err := client.Get(ctx, oldKey, &got); !errors.Is(err, datastore.ErrNoSuchEntity) {
+			t.Errorf("expected deleted entity to be gone, got %v", err)
+		}
+	})
+
+	t.Run("InsertConflictIsAtomic", func(t *testing.T) {
+		_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+			_, err := tx.Mutate(
+				datastore.NewDelete(keepKey),
+				datastore.NewInsert(newKey, &testEntity{Name: "duplicate"}),
+			)
+			return err
+		})
+		if !errors.Is(err, datastore.ErrAlreadyExists) {
+			t.Fatalf("expected ErrAlreadyExists, got %v", err)
+		}
+
+		var got testEntity
+		if err := client.Get(ctx, keepKey, &got); err != nil {
+			t.Errorf("expected delete to be rolled back with the failed insert, got %v", err)
+		}
+		if err := client.Get(ctx, newKey, &got); err != nil || got.Name != "new" {
+			t.Errorf("expected original entity to be untouched, got %+v (err=%v)", got, err)
+		}
+	})
+}
+
+func TestTransactionAllocateIDs(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	var allocated *datastore.Key
+	_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		keys, err := tx.AllocateIDs([]*datastore.Key{datastore.IncompleteKey("TxAlloc", nil)})
+		if err != nil {
+			return err
+		}
+		allocated = keys[0]
+		_, err = tx.Put(allocated, &testEntity{Name: "allocated"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+
+	if allocated == nil || allocated.Incomplete() {
+		t.Fatalf("expected an allocated key, got %v", allocated)
+	}
+
+	var got testEntity
+	if err := client.Get(ctx, allocated, &got); err != nil {
+		t.Fatalf("Get of allocated key failed: %v", err)
+	}
+	if got.Name != "allocated" {
+		t.Errorf("expected Name 'allocated', got %q", got.Name)
+	}
+}
+
+func TestWriteOnlyTransactionSkipsLookups(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, ":")+1:]
+		mu.Lock()
+		methods = append(methods, method)
+		mu.Unlock()
+		switch method {
+		case "beginTransaction":
+			writeJSON(t, w, map[string]any{"transaction": "tx-1"})
+		case "commit":
+			writeJSON(t, w, map[string]any{"mutationResults": []any{map[string]any{}, map[string]any{}}})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	_, err := client.RunInTransaction(context.Background(), func(tx *datastore.Transaction) error {
+		if _, err := tx.Put(datastore.NameKey("Blind", "a", nil), &testEntity{Name: "a"}); err != nil {
+			return err
+		}
+		return tx.Delete(datastore.NameKey("Blind", "b", nil))
+	})
+	if err != nil {
+		t.Fatalf("RunInTransaction failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"beginTransaction", "commit"}; !slices.Equal(methods, want) {
+		t.Errorf("expected requests %v, got %v", want, methods)
+	}
+}
+
+func TestGetOrCreate(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	t.Run("Existing", func(t *testing.T) {
+		key := datastore.NameKey("TestKind", "existing", nil)
+		if _, err := client.Put(ctx, key, &testEntity{Name: "stored", Count: 7}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+
+		var got testEntity
+		err := client.GetOrCreate(ctx, key, &got, func() any {
+			t.Error("create should not be called for an existing entity")
+			return &testEntity{Name: "default"}
+		})
+		if err != nil {
+			t.Fatalf("GetOrCreate failed: %v", err)
+		}
+		if got.Name != "stored" || got.Count != 7 {
+			t.Errorf("expected stored entity, got %+v", got)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		key := datastore.NameKey("TestKind", "missing", nil)
+		calls := 0
+
+		var got testEntity
+		err := client.GetOrCreate(ctx, key, &got, func() any {
+			calls++
+			return &testEntity{Name: "default", Count: 1}
+		})
+		if err != nil {
+			t.Fatalf("GetOrCreate failed: %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected create to be called once, got %d", calls)
+		}
+		if got.Name != "default" || got.Count != 1 {
+			t.Errorf("expected created entity in dst, got %+v", got)
+		}
+
+		var stored testEntity
+		if err := client.Get(ctx, key, &stored); err != nil {
+			t.Fatalf("created entity was not persisted: %v", err)
+		}
+		if stored.Name != "default" {
+			t.Errorf("expected persisted entity named default, got %q", stored.Name)
+		}
+	})
+
+	t.Run("IncompleteKey", func(t *testing.T) {
+		var got testEntity
+		err := client.GetOrCreate(ctx, datastore.IncompleteKey("TestKind", nil), &got, func() any { return &testEntity{} })
+		if !errors.Is(err, datastore.ErrInvalidKey) {
+			t.Errorf("expected ErrInvalidKey, got: %v", err)
+		}
+	})
+}
+
+func TestRunInReadOnlyTransaction(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	author := datastore.NameKey("Author", "ada", nil)
+	keys := []*datastore.Key{
+		author,
+		datastore.NameKey("Book", "notes", author),
+		datastore.NameKey("Book", "sketch", author),
+	}
+	initial := []testEntity{{Name: "Ada", Count: 2}, {Name: "Notes", Count: 1}, {Name: "Sketch", Count: 1}}
+	if _, err := client.PutMulti(ctx, keys, initial); err != nil {
+		t.Fatalf("PutMulti failed: %v", err)
+	}
+
+	// A write after the reads would abort a read-write transaction and retry
+	// it; a read-only transaction keeps its snapshot and runs once
+	calls := 0
+	var got []testEntity
+	err := client.RunInReadOnlyTransaction(ctx, func(tx *datastore.Transaction) error {
+		calls++
+		got = make([]testEntity, len(keys))
+		if err := tx.GetMulti(keys, &got); err != nil {
+			return err
+		}
+		_, err := client.Put(ctx, author, &testEntity{Name: "Ada", Count: 3})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RunInReadOnlyTransaction failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the function to run once, ran %d times", calls)
+	}
+	if !reflect.DeepEqual(got, initial) {
+		t.Errorf("expected a consistent read of %+v, got %+v", initial, got)
+	}
+
+	// Writes are rejected when the transaction commits, without a retry
+	calls = 0
+	err = client.RunInReadOnlyTransaction(ctx, func(tx *datastore.Transaction) error {
+		calls++
+		_, err := tx.Put(author, &testEntity{Name: "Ada", Count: 4})
+		return err
+	})
+	if err == nil {
+		t.Error("expected a write in a read-only transaction to fail")
+	}
+	if calls != 1 {
+		t.Errorf("expected the function to run once, ran %d times", calls)
+	}
+	var stored testEntity
+	if err := client.Get(ctx, author, &stored); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if stored.Count != 3 {
+		t.Errorf("expected the read-only transaction to leave count 3, got %d", stored.Count)
+	}
+	// The caller's options are left alone, even with spare capacity
+	opts := make([]datastore.TransactionOption, 1, 3)
+	opts[0] = datastore.MaxAttempts(2)
+	if err := client.RunInReadOnlyTransaction(ctx, func(*datastore.Transaction) error { return nil }, opts...); err != nil {
+		t.Fatalf("RunInReadOnlyTransaction failed: %v", err)
+	}
+	if spare := opts[1:3]; spare[0] != nil || spare[1] != nil {
+		t.Errorf("expected the caller's backing array to be untouched, got %v", spare)
+	}
+}
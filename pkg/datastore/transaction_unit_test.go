@@ -0,0 +1,49 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRunInTransactionRejectsNesting(t *testing.T) {
+	client, cleanup := NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	key := NameKey("Nested", "outer", nil)
+
+	var innerErr error
+	_, err := client.RunInTransaction(ctx, func(tx *Transaction) error {
+		_, innerErr = client.RunInTransaction(tx.ctx, func(*Transaction) error {
+			return nil
+		})
+		_, err := tx.Put(key, &struct{ Name string }{Name: "outer"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("outer transaction failed: %v", err)
+	}
+	if !errors.Is(innerErr, ErrNestedTransaction) {
+		t.Errorf("expected ErrNestedTransaction, got %v", innerErr)
+	}
+}
+
+func TestRunInTransactionNotNested(t *testing.T) {
+	client, cleanup := NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	key := NameKey("Nested", "plain", nil)
+
+	// Sequential transactions on the same context are independent, not nested.
+	for range 2 {
+		_, err := client.RunInTransaction(ctx, func(tx *Transaction) error {
+			_, err := tx.Put(key, &struct{ Name string }{Name: "plain"})
+			return err
+		})
+		if err != nil {
+			t.Fatalf("RunInTransaction failed: %v", err)
+		}
+	}
+}
@@ -18,14 +18,17 @@
 package mock
 
 import (
+	"cmp"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
 	"net/http/httptest"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -47,9 +50,70 @@ const (
 type Store struct {
 	mu           sync.RWMutex
 	entities     map[string]map[string]any
+	versions     map[string]int64 // Commit version of each entity, in microseconds
 	transactions map[string]*transactionState
 	nextID       int64 // Counter for allocating unique IDs
 	nextTxID     int64 // Counter for transaction IDs
+	lastVersion  int64 // Most recently assigned commit version
+	now          func() time.Time
+	latency      time.Duration // Delay before each API request is handled
+	faults       []*fault      // Injected failures, checked in order
+}
+
+// fault fails the next remaining API requests for method ("" for any).
+type fault struct {
+	method     string
+	remaining  int
+	statusCode int
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithLatency delays every API request by d before it is handled, to simulate
+// a slow server. A request whose context ends during the delay is dropped.
+func WithLatency(d time.Duration) Option {
+	return func(s *Store) {
+		s.latency = d
+	}
+}
+
+// FailNext makes the next API request, whatever its method, fail with the
+// HTTP status statusCode, as a transient server error would.
+func FailNext(statusCode int) Option {
+	return FailOn("", 1, statusCode)
+}
+
+// FailOn makes the next count requests for method fail with the HTTP status
+// statusCode; later requests are handled normally. method is the API method
+// name, such as "lookup", "commit", "runQuery" or "beginTransaction".
+func FailOn(method string, count, statusCode int) Option {
+	return func(s *Store) {
+		s.FailOn(method, count, statusCode)
+	}
+}
+
+// FailNext is like the FailNext option, but arms the fault on a running
+// store, so a test can fail a request partway through.
+func (s *Store) FailNext(statusCode int) {
+	s.FailOn("", 1, statusCode)
+}
+
+// FailOn is like the FailOn option, but arms the fault on a running store.
+func (s *Store) FailOn(method string, count, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault{method: method, remaining: count, statusCode: statusCode})
+}
+
+// WithClock makes the store read the time from now instead of time.Now. The
+// clock sets commit versions and expires transactions, so a fixed or manually
+// advanced clock makes both deterministic. Versions still increase on every
+// commit even if the clock stands still.
+func WithClock(now func() time.Time) Option {
+	return func(s *Store) {
+		s.now = now
+	}
 }
 
 // transactionState tracks the state of an active transaction.
@@ -59,16 +123,26 @@ type transactionState struct {
 	id        string
 	createdAt time.Time
 	readKeys  map[string]bool // Keys read during this transaction
+	readOnly  bool            // Read-only transactions never conflict but can't write
+	// Entities and versions as of begin; reads in the transaction see only these
+	entities map[string]map[string]any
+	versions map[string]int64
 }
 
 // NewStore creates a new in-memory store.
-func NewStore() *Store {
-	return &Store{
+func NewStore(opts ...Option) *Store {
+	s := &Store{
 		entities:     make(map[string]map[string]any),
+		versions:     make(map[string]int64),
 		transactions: make(map[string]*transactionState),
 		nextID:       1000, // Start IDs at 1000
 		nextTxID:     1,
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // NewMockServers creates mock metadata and API servers for testing.
@@ -76,10 +150,16 @@ func NewStore() *Store {
 // This function doesn't import datastore to avoid import cycles.
 //
 // For convenience, use datastore.NewMockClient() instead which handles all setup.
-func NewMockServers(t *testing.T) (metadataURL, apiURL string, cleanup func()) {
+func NewMockServers(t *testing.T, opts ...Option) (metadataURL, apiURL string, cleanup func()) {
 	t.Helper()
+	return NewMockServersWithStore(t, NewStore(opts...))
+}
 
-	store := NewStore()
+// NewMockServersWithStore is like NewMockServers, but serves an existing
+// store, so the test keeps a handle on it, for example to arm faults with
+// Store.FailOn after setup.
+func NewMockServersWithStore(t *testing.T, store *Store) (metadataURL, apiURL string, cleanup func()) {
+	t.Helper()
 
 	// Mock metadata server
 	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -119,6 +199,19 @@ func NewMockServers(t *testing.T) (metadataURL, apiURL string, cleanup func()) {
 			return
 		}
 
+		if store.latency > 0 {
+			select {
+			case <-time.After(store.latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if code := store.injectedFault(strings.TrimPrefix(r.URL.Path, "/projects/test-project:")); code != 0 {
+			store.writeError(w, code, canonicalStatus(code), "Injected failure")
+			return
+		}
+
 		// Route based on path
 		if r.URL.Path == "/projects/test-project:lookup" {
 			store.handleLookup(w, r)
@@ -140,11 +233,21 @@ func NewMockServers(t *testing.T) (metadataURL, apiURL string, cleanup func()) {
 			return
 		}
 
+		if r.URL.Path == "/projects/test-project:rollback" {
+			store.handleRollback(w, r)
+			return
+		}
+
 		if r.URL.Path == "/projects/test-project:allocateIds" {
 			store.handleAllocateIDs(w, r)
 			return
 		}
 
+		if r.URL.Path == "/projects/test-project:reserveIds" {
+			store.handleReserveIDs(w, r)
+			return
+		}
+
 		if r.URL.Path == "/projects/test-project:runAggregationQuery" {
 			store.handleRunAggregationQuery(w, r)
 			return
@@ -164,8 +267,11 @@ func NewMockServers(t *testing.T) (metadataURL, apiURL string, cleanup func()) {
 // handleLookup handles lookup (get) requests.
 func (s *Store) handleLookup(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		DatabaseID string           `json:"databaseId"`
-		Keys       []map[string]any `json:"keys"`
+		DatabaseID  string           `json:"databaseId"`
+		Keys        []map[string]any `json:"keys"`
+		ReadOptions struct {
+			Transaction string `json:"transaction"`
+		} `json:"readOptions"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -196,18 +302,36 @@ func (s *Store) handleLookup(w http.ResponseWriter, r *http.Request) {
 	var found []map[string]any
 	var missing []map[string]any
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	// Locked for writing since transactional reads are recorded
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Reads in a transaction see the store as it was when the transaction began
+	entities, versions := s.entities, s.versions
+	var txState *transactionState
+	if req.ReadOptions.Transaction != "" {
+		var exists bool
+		txState, exists = s.transactions[req.ReadOptions.Transaction]
+		if !exists {
+			s.writeErrorLocked(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid or expired transaction")
+			return
+		}
+		entities, versions = txState.entities, txState.versions
+	}
 
 	for _, keyData := range req.Keys {
 		keyStr, ok := s.extractKeyString(keyData)
 		if !ok {
 			continue
 		}
+		if txState != nil {
+			txState.readKeys[keyStr] = true
+		}
 
-		if entity, exists := s.entities[keyStr]; exists {
+		if entity, exists := entities[keyStr]; exists {
 			found = append(found, map[string]any{
-				"entity": entity,
+				"entity":  entity,
+				"version": strconv.FormatInt(versions[keyStr], 10),
 			})
 		} else {
 			missing = append(missing, map[string]any{
@@ -270,6 +394,19 @@ func (s *Store) handleCommit(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// Commits are atomic: restore the previous entities unless every mutation applies
+	snapshot, versionSnapshot, lastVersion := maps.Clone(s.entities), maps.Clone(s.versions), s.lastVersion
+	applied := false
+	defer func() {
+		if !applied {
+			s.entities, s.versions, s.lastVersion = snapshot, versionSnapshot, lastVersion
+		}
+	}()
+
+	// Every entity written by this commit shares one version, like real Datastore
+	s.lastVersion = max(s.now().UnixMicro(), s.lastVersion+1)
+	version := s.lastVersion
+
 	// Validate transaction if provided
 	if req.Transaction != "" {
 		txState, exists := s.transactions[req.Transaction]
@@ -279,7 +416,7 @@ func (s *Store) handleCommit(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Check transaction timeout
-		if time.Since(txState.createdAt) > transactionTimeout*time.Second {
+		if s.now().Sub(txState.createdAt) > transactionTimeout*time.Second {
 			delete(s.transactions, req.Transaction)
 			s.writeErrorLocked(w, http.StatusBadRequest, "ABORTED", "Transaction has expired")
 			return
@@ -287,6 +424,23 @@ func (s *Store) handleCommit(w http.ResponseWriter, r *http.Request) {
 
 		// Remove transaction after commit (whether successful or not)
 		defer delete(s.transactions, req.Transaction)
+
+		if txState.readOnly {
+			if len(req.Mutations) > 0 {
+				s.writeErrorLocked(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Cannot modify entities in a read-only transaction")
+				return
+			}
+			txState.readKeys = nil // Reads from a snapshot never conflict
+		}
+
+		// An entity read by the transaction and written since it began means
+		// the reads are stale, so the commit is aborted for the caller to retry
+		for keyStr := range txState.readKeys {
+			if s.versions[keyStr] != txState.versions[keyStr] {
+				s.writeErrorLocked(w, http.StatusConflict, "ABORTED", "Transaction conflict: entity modified since it was read")
+				return
+			}
+		}
 	}
 
 	var mutationResults []map[string]any
@@ -294,6 +448,16 @@ func (s *Store) handleCommit(w http.ResponseWriter, r *http.Request) {
 	for _, mutation := range req.Mutations {
 		var resultKey map[string]any
 
+		// A mutation with a base version is skipped, and the conflict
+		// reported, if the entity has been written since that version
+		if keyData, conflict := s.baseVersionConflict(mutation); conflict {
+			mutationResults = append(mutationResults, map[string]any{
+				"key":              keyData,
+				"conflictDetected": true,
+			})
+			continue
+		}
+
 		// Handle insert - fails if entity already exists (like real Datastore)
 		if insert, ok := mutation["insert"].(map[string]any); ok {
 			keyData, ok := insert["key"].(map[string]any)
@@ -328,6 +492,7 @@ func (s *Store) handleCommit(w http.ResponseWriter, r *http.Request) {
 			// Update the entity's key with potentially allocated ID
 			insert["key"] = keyData
 			s.entities[keyStr] = insert
+			s.versions[keyStr] = version
 			resultKey = keyData
 		}
 
@@ -362,6 +527,7 @@ func (s *Store) handleCommit(w http.ResponseWriter, r *http.Request) {
 			}
 
 			s.entities[keyStr] = update
+			s.versions[keyStr] = version
 			resultKey = keyData
 		}
 
@@ -393,6 +559,7 @@ func (s *Store) handleCommit(w http.ResponseWriter, r *http.Request) {
 			// Update the entity's key with potentially allocated ID
 			upsert["key"] = keyData
 			s.entities[keyStr] = upsert
+			s.versions[keyStr] = version
 			resultKey = keyData
 		}
 
@@ -404,6 +571,7 @@ func (s *Store) handleCommit(w http.ResponseWriter, r *http.Request) {
 			}
 
 			delete(s.entities, keyStr)
+			delete(s.versions, keyStr)
 			resultKey = deleteKey
 		}
 
@@ -415,6 +583,7 @@ func (s *Store) handleCommit(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	applied = true
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]any{
@@ -424,6 +593,30 @@ func (s *Store) handleCommit(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// baseVersionConflict reports whether mutation has a baseVersion that its
+// entity's current version doesn't match, and returns the mutation's key.
+// A missing entity has version 0. Caller must hold the lock.
+func (s *Store) baseVersionConflict(mutation map[string]any) (map[string]any, bool) {
+	base, ok := mutation["baseVersion"].(string)
+	if !ok {
+		return nil, false
+	}
+	var keyData map[string]any
+	for _, op := range []string{"insert", "update", "upsert"} {
+		if entity, ok := mutation[op].(map[string]any); ok {
+			keyData, _ = entity["key"].(map[string]any) //nolint:errcheck // A missing key is skipped below
+		}
+	}
+	if deleteKey, ok := mutation["delete"].(map[string]any); ok {
+		keyData = deleteKey
+	}
+	keyStr, ok := s.extractKeyString(keyData)
+	if !ok {
+		return nil, false
+	}
+	return keyData, strconv.FormatInt(s.versions[keyStr], 10) != base
+}
+
 // writeError writes an error response (must NOT hold lock).
 //
 //nolint:unparam // code parameter kept for consistency with writeErrorLocked
@@ -456,6 +649,42 @@ func (*Store) writeErrorLocked(w http.ResponseWriter, statusCode int, status, me
 	}
 }
 
+// injectedFault returns the status code a request for method should fail
+// with, or 0 if it should be handled.
+func (s *Store) injectedFault(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.faults {
+		if f.remaining > 0 && (f.method == "" || f.method == method) {
+			f.remaining--
+			return f.statusCode
+		}
+	}
+	return 0
+}
+
+// canonicalStatus returns the canonical error code Datastore reports with an
+// HTTP status.
+func canonicalStatus(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "ABORTED"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	}
+	return "INTERNAL"
+}
+
 // validateEntitySize checks if an entity exceeds the size limit.
 func (*Store) validateEntitySize(entity map[string]any) error {
 	data, err := json.Marshal(entity)
@@ -483,74 +712,70 @@ func (*Store) validateKeySize(keyData map[string]any) error {
 // resolveKey handles incomplete keys by allocating an ID if needed.
 // Returns the key string, updated key data, and success flag.
 func (s *Store) resolveKey(keyData map[string]any) (keyStr string, updatedKey map[string]any, ok bool) {
+	if keyStr, ok := keyString(keyData); ok {
+		return keyStr, keyData, true
+	}
+
+	// Incomplete key - allocate an ID for the last path element
 	path, ok := keyData["path"].([]any)
 	if !ok || len(path) == 0 {
 		return "", nil, false
 	}
-	pathElem, ok := path[0].(map[string]any)
+	pathElem, ok := path[len(path)-1].(map[string]any)
 	if !ok {
 		return "", nil, false
 	}
-	kind, ok := pathElem["kind"].(string)
-	if !ok {
+	if _, ok := pathElem["kind"].(string); !ok {
 		return "", nil, false
 	}
-
-	// Extract namespace
-	namespace := ""
-	if pid, ok := keyData["partitionId"].(map[string]any); ok {
-		if ns, ok := pid["namespaceId"].(string); ok {
-			namespace = ns
-		}
-	}
-
-	// Handle both name and ID keys
-	if name, ok := pathElem["name"].(string); ok {
-		return namespace + "!" + kind + "/" + name, keyData, true
-	}
-	if id, ok := pathElem["id"].(string); ok {
-		return namespace + "!" + kind + "/" + id, keyData, true
-	}
-
-	// Incomplete key - allocate an ID
 	s.nextID++
-	allocatedID := strconv.FormatInt(s.nextID, 10)
-	pathElem["id"] = allocatedID
+	pathElem["id"] = strconv.FormatInt(s.nextID, 10)
 
-	return namespace + "!" + kind + "/" + allocatedID, keyData, true
+	keyStr, ok = keyString(keyData)
+	return keyStr, keyData, ok
 }
 
 // extractKeyString extracts the key string from key data.
 func (*Store) extractKeyString(keyData map[string]any) (string, bool) {
+	return keyString(keyData)
+}
+
+// keyString formats a complete key as "namespace!kind/name_or_id", with a
+// kind/name_or_id pair for each path element so that child entities are
+// stored apart from their parents and siblings. It fails for incomplete keys.
+func keyString(keyData map[string]any) (string, bool) {
 	path, ok := keyData["path"].([]any)
 	if !ok || len(path) == 0 {
 		return "", false
 	}
-	pathElem, ok := path[0].(map[string]any)
-	if !ok {
-		return "", false
-	}
-	kind, ok := pathElem["kind"].(string)
-	if !ok {
-		return "", false
-	}
 
-	// Extract namespace
-	namespace := ""
-	if pid, ok := keyData["partitionId"].(map[string]any); ok {
-		if ns, ok := pid["namespaceId"].(string); ok {
-			namespace = ns
+	var b strings.Builder
+	b.WriteString(keyNamespace(keyData))
+	b.WriteString("!")
+	for i, e := range path {
+		pathElem, ok := e.(map[string]any)
+		if !ok {
+			return "", false
 		}
-	}
+		kind, ok := pathElem["kind"].(string)
+		if !ok {
+			return "", false
+		}
+		if i > 0 {
+			b.WriteString("/")
+		}
+		b.WriteString(kind + "/")
 
-	// Handle both name and ID keys
-	if name, ok := pathElem["name"].(string); ok {
-		return namespace + "!" + kind + "/" + name, true
-	}
-	if id, ok := pathElem["id"].(string); ok {
-		return namespace + "!" + kind + "/" + id, true
+		// Handle both name and ID keys
+		if name, ok := pathElem["name"].(string); ok {
+			b.WriteString(name)
+		} else if id, ok := pathElem["id"].(string); ok {
+			b.WriteString(id)
+		} else {
+			return "", false
+		}
 	}
-	return "", false
+	return b.String(), true
 }
 
 // queryResult holds an entity with its key for sorting.
@@ -561,6 +786,50 @@ type queryResult struct {
 	entity map[string]any
 }
 
+// matchingEntities returns the entities of kind in namespace that match the
+// query's filter, in the query's order, breaking ties by key. An entity's kind
+// is the kind of the last element of its key path. Callers must hold s.mu.
+func (s *Store) matchingEntities(query map[string]any, kind, namespace string) []queryResult {
+	filterMap, hasFilter := query["filter"].(map[string]any)
+
+	var matches []queryResult
+	for keyStr, entity := range s.entities {
+		keyData, ok := entity["key"].(map[string]any)
+		if !ok {
+			continue
+		}
+		path, ok := keyData["path"].([]any)
+		if !ok || len(path) == 0 {
+			continue
+		}
+		pathElem, ok := path[len(path)-1].(map[string]any)
+		if !ok {
+			continue
+		}
+		if entityKind, _ := pathElem["kind"].(string); entityKind != kind { //nolint:errcheck // Missing kind never matches
+			continue
+		}
+		if keyNamespace(keyData) != namespace {
+			continue
+		}
+		if hasFilter && !matchesFilter(entity, filterMap) {
+			continue
+		}
+		matches = append(matches, queryResult{keyStr: keyStr, entity: entity})
+	}
+
+	// Sort results deterministically by key string for consistent ordering
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].keyStr < matches[j].keyStr
+	})
+
+	// Apply ordering from query if specified
+	if orders, ok := query["order"].([]any); ok && len(orders) > 0 {
+		s.applyOrdering(matches, orders)
+	}
+	return matches
+}
+
 // isKeysOnlyQuery checks if the query has a projection for only __key__.
 func isKeysOnlyQuery(query map[string]any) bool {
 	projection, ok := query["projection"].([]any)
@@ -579,6 +848,49 @@ func isKeysOnlyQuery(query map[string]any) bool {
 	return ok && name == "__key__"
 }
 
+// projectedProperties returns the property names in the query's projection,
+// or nil if it has none.
+func projectedProperties(query map[string]any) []string {
+	projection, ok := query["projection"].([]any)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, p := range projection {
+		proj, ok := p.(map[string]any)
+		if !ok {
+			continue
+		}
+		prop, ok := proj["property"].(map[string]any)
+		if !ok {
+			continue
+		}
+		if name, ok := prop["name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// projectEntity returns a copy of entity holding only the projected
+// properties, or entity itself if there is no projection.
+func projectEntity(entity map[string]any, projected []string) map[string]any {
+	if len(projected) == 0 {
+		return entity
+	}
+	props, _ := entity["properties"].(map[string]any) //nolint:errcheck // Missing properties project to none
+	out := make(map[string]any, len(projected))
+	for _, name := range projected {
+		if v, ok := props[name]; ok {
+			out[name] = v
+		}
+	}
+	return map[string]any{
+		"key":        entity["key"],
+		"properties": out,
+	}
+}
+
 // handleRunQuery handles query requests.
 func (s *Store) handleRunQuery(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -636,6 +948,13 @@ func (s *Store) handleRunQuery(w http.ResponseWriter, r *http.Request) {
 		offset = int(o)
 	}
 
+	if filterMap, ok := query["filter"].(map[string]any); ok {
+		if err := validateFilter(filterMap); err != nil {
+			s.writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+			return
+		}
+	}
+
 	// Parse cursor to get starting position
 	var startIdx int
 	if sc, ok := query["startCursor"].(string); ok && sc != "" {
@@ -646,61 +965,14 @@ func (s *Store) handleRunQuery(w http.ResponseWriter, r *http.Request) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Collect all matching entities
-	var matches []queryResult
-	for keyStr, entity := range s.entities {
-		keyData, ok := entity["key"].(map[string]any)
-		if !ok {
-			continue
-		}
-		path, ok := keyData["path"].([]any)
-		if !ok || len(path) == 0 {
-			continue
-		}
-		pathElem, ok := path[0].(map[string]any)
-		if !ok {
-			continue
-		}
-		entityKind, ok := pathElem["kind"].(string)
-		if !ok {
-			continue
-		}
-
-		// Check kind and namespace
-		entityNamespace := ""
-		if pid, ok := keyData["partitionId"].(map[string]any); ok {
-			if ns, ok := pid["namespaceId"].(string); ok {
-				entityNamespace = ns
-			}
-		}
-
-		if entityKind == kind && entityNamespace == namespace {
-			// Apply filters if present
-			if filterMap, hasFilter := query["filter"].(map[string]any); hasFilter {
-				if !matchesFilter(entity, filterMap) {
-					continue
-				}
-			}
-
-			matches = append(matches, queryResult{keyStr: keyStr, entity: entity})
-		}
-	}
-
-	// Sort results deterministically by key string for consistent ordering
-	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].keyStr < matches[j].keyStr
-	})
+	matches := s.matchingEntities(query, kind, namespace)
 
-	// Apply ordering from query if specified
-	if orders, ok := query["order"].([]any); ok && len(orders) > 0 {
-		s.applyOrdering(matches, orders)
-	}
-
-	// Apply offset and cursor
-	skipCount := offset + startIdx
-	if skipCount > len(matches) {
-		skipCount = len(matches)
-	}
+	// Resume after the cursor, then skip the offset. Datastore reports how many
+	// results the offset skipped so clients resuming from the end cursor don't
+	// apply it again.
+	startIdx = min(startIdx, len(matches))
+	skipped := min(offset, len(matches)-startIdx)
+	skipCount := startIdx + skipped
 	matches = matches[skipCount:]
 
 	// Apply limit
@@ -711,20 +983,27 @@ func (s *Store) handleRunQuery(w http.ResponseWriter, r *http.Request) {
 
 	// Check if this is a keys-only query (projection contains only __key__)
 	keysOnly := isKeysOnlyQuery(query)
+	projected := projectedProperties(query)
 
 	// Build results
 	results := make([]any, 0, len(matches))
-	for _, m := range matches {
+	for i, m := range matches {
+		// Each result's cursor resumes right after it
+		cursor := s.encodeCursor(skipCount + i + 1)
 		if keysOnly {
 			// For keys-only queries, return entity with only the key (no properties)
 			results = append(results, map[string]any{
 				"entity": map[string]any{
 					"key": m.entity["key"],
 				},
+				"cursor":  cursor,
+				"version": strconv.FormatInt(s.versions[m.keyStr], 10),
 			})
 		} else {
 			results = append(results, map[string]any{
-				"entity": m.entity,
+				"entity":  projectEntity(m.entity, projected),
+				"cursor":  cursor,
+				"version": strconv.FormatInt(s.versions[m.keyStr], 10),
 			})
 		}
 	}
@@ -747,6 +1026,9 @@ func (s *Store) handleRunQuery(w http.ResponseWriter, r *http.Request) {
 	if endCursor != "" {
 		batch["endCursor"] = endCursor
 	}
+	if skipped > 0 {
+		batch["skippedResults"] = skipped
+	}
 
 	response := map[string]any{
 		"batch": batch,
@@ -796,17 +1078,23 @@ func (*Store) applyOrdering(matches []queryResult, orders []any) {
 			direction, ok := order["direction"].(string)
 			descending := ok && direction == "DESCENDING"
 
-			// Get property values from both entities
-			propsI, okI := matches[i].entity["properties"].(map[string]any)
-			propsJ, okJ := matches[j].entity["properties"].(map[string]any)
-			if !okI || !okJ {
-				continue
+			var cmp int
+			if propName == "__key__" {
+				keyI, okI := matches[i].entity["key"].(map[string]any)
+				keyJ, okJ := matches[j].entity["key"].(map[string]any)
+				if !okI || !okJ {
+					continue
+				}
+				cmp = compareKeys(keyI, keyJ)
+			} else {
+				// Get property values from both entities
+				propsI, okI := matches[i].entity["properties"].(map[string]any)
+				propsJ, okJ := matches[j].entity["properties"].(map[string]any)
+				if !okI || !okJ {
+					continue
+				}
+				cmp = compareValues(getPropertyValue(propsI, propName), getPropertyValue(propsJ, propName))
 			}
-
-			valI := getPropertyValue(propsI, propName)
-			valJ := getPropertyValue(propsJ, propName)
-
-			cmp := compareValues(valI, valJ)
 			if cmp != 0 {
 				if descending {
 					return cmp > 0
@@ -823,26 +1111,38 @@ func getPropertyValue(props map[string]any, name string) any {
 	if props == nil {
 		return nil
 	}
-	prop, ok := props[name].(map[string]any)
+	prop, ok := lookupProperty(props, name)
 	if !ok {
 		return nil
 	}
-	if v, ok := prop["integerValue"].(string); ok {
-		var i int64
-		if _, err := fmt.Sscanf(v, "%d", &i); err == nil {
-			return i
-		}
-	}
-	if v, ok := prop["stringValue"].(string); ok {
-		return v
-	}
-	if v, ok := prop["doubleValue"].(float64); ok {
-		return v
+	return extractEntityValue(prop)
+}
+
+// lookupProperty returns the property called name. Like Datastore, a dotted
+// name that isn't itself a property, such as "address.city", names a property
+// of a nested entity value.
+func lookupProperty(props map[string]any, name string) (map[string]any, bool) {
+	if prop, ok := props[name].(map[string]any); ok {
+		return prop, true
 	}
-	if v, ok := prop["booleanValue"].(bool); ok {
-		return v
+	for i := range len(name) {
+		if name[i] != '.' {
+			continue
+		}
+		outer, ok := props[name[:i]].(map[string]any)
+		if !ok {
+			continue
+		}
+		ev, ok := outer["entityValue"].(map[string]any)
+		if !ok {
+			continue
+		}
+		nested, _ := ev["properties"].(map[string]any) //nolint:errcheck // Missing properties mean an empty entity
+		if prop, ok := lookupProperty(nested, name[i+1:]); ok {
+			return prop, true
+		}
 	}
-	return nil
+	return nil, false
 }
 
 // compareValues compares two property values.
@@ -856,56 +1156,52 @@ func compareValues(a, b any) int {
 	if b == nil {
 		return 1
 	}
+	c, _ := compareTyped(a, b) //nolint:errcheck // Mismatched types sort as equal
+	return c
+}
 
+// compareTyped compares two property values of the same type, reporting false
+// if their types differ.
+func compareTyped(a, b any) (int, bool) {
 	switch va := a.(type) {
 	case int64:
 		if vb, ok := b.(int64); ok {
-			if va < vb {
-				return -1
-			}
-			if va > vb {
-				return 1
-			}
-			return 0
+			return cmp.Compare(va, vb), true
 		}
 	case string:
 		if vb, ok := b.(string); ok {
-			if va < vb {
-				return -1
-			}
-			if va > vb {
-				return 1
-			}
-			return 0
+			return cmp.Compare(va, vb), true
 		}
 	case float64:
 		if vb, ok := b.(float64); ok {
-			if va < vb {
-				return -1
-			}
-			if va > vb {
-				return 1
-			}
-			return 0
+			return cmp.Compare(va, vb), true
 		}
 	case bool:
 		if vb, ok := b.(bool); ok {
-			if !va && vb {
-				return -1
-			}
-			if va && !vb {
-				return 1
+			switch {
+			case va == vb:
+				return 0, true
+			case vb:
+				return -1, true
+			default:
+				return 1, true
 			}
-			return 0
+		}
+	case time.Time:
+		if vb, ok := b.(time.Time); ok {
+			return va.Compare(vb), true
 		}
 	}
-	return 0
+	return 0, false
 }
 
 // handleBeginTransaction handles transaction begin requests.
 func (s *Store) handleBeginTransaction(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		DatabaseID string `json:"databaseId"`
+		DatabaseID         string `json:"databaseId"`
+		TransactionOptions struct {
+			ReadOnly map[string]any `json:"readOnly"`
+		} `json:"transactionOptions"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -927,13 +1223,16 @@ func (s *Store) handleBeginTransaction(w http.ResponseWriter, r *http.Request) {
 
 	// Generate unique transaction ID
 	s.nextTxID++
-	txID := fmt.Sprintf("tx-%d-%d", time.Now().UnixNano(), s.nextTxID)
+	txID := fmt.Sprintf("tx-%d-%d", s.now().UnixNano(), s.nextTxID)
 
 	// Store transaction state
 	s.transactions[txID] = &transactionState{
 		id:        txID,
-		createdAt: time.Now(),
+		createdAt: s.now(),
 		readKeys:  make(map[string]bool),
+		readOnly:  req.TransactionOptions.ReadOnly != nil,
+		entities:  maps.Clone(s.entities),
+		versions:  maps.Clone(s.versions),
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -945,6 +1244,43 @@ func (s *Store) handleBeginTransaction(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleRollback handles :rollback requests by discarding the transaction.
+func (s *Store) handleRollback(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DatabaseID  string `json:"databaseId"`
+		Transaction string `json:"transaction"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Validate routing header for named databases
+	if req.DatabaseID != "" {
+		routingHeader := r.Header.Get("X-Goog-Request-Params")
+		if routingHeader == "" {
+			s.writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Missing routing header for named database")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.transactions[req.Transaction]; !exists {
+		s.writeErrorLocked(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid or expired transaction")
+		return
+	}
+	delete(s.transactions, req.Transaction)
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{}); err != nil {
+		log.Printf("failed to encode rollback response: %v", err)
+	}
+}
+
 // handleAllocateIDs handles :allocateIds requests.
 func (s *Store) handleAllocateIDs(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -1015,18 +1351,67 @@ func (s *Store) handleAllocateIDs(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// extractFilterKeyData extracts key data from a filter value, handling both wrapped and direct formats.
+// handleReserveIDs handles :reserveIds requests.
+// Reserved IDs are never handed out by handleAllocateIDs afterwards.
+func (s *Store) handleReserveIDs(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DatabaseID string           `json:"databaseId"`
+		Keys       []map[string]any `json:"keys"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Validate routing header for named databases
+	if req.DatabaseID != "" && r.Header.Get("X-Goog-Request-Params") == "" {
+		s.writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Missing routing header for named database")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, keyData := range req.Keys {
+		path, ok := keyData["path"].([]any)
+		if !ok || len(path) == 0 {
+			s.writeErrorLocked(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Key path is empty")
+			return
+		}
+		lastElem, ok := path[len(path)-1].(map[string]any)
+		if !ok {
+			s.writeErrorLocked(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid key path element")
+			return
+		}
+		idStr, ok := lastElem["id"].(string)
+		if !ok {
+			s.writeErrorLocked(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Reserved keys must have a numeric ID")
+			return
+		}
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			s.writeErrorLocked(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid key ID: "+idStr)
+			return
+		}
+		s.nextID = max(s.nextID, id)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write([]byte("{}")); err != nil {
+		log.Printf("failed to write reserveIds response: %v", err)
+	}
+}
+
+// extractFilterKeyData extracts the key from a filter's keyValue.
 func extractFilterKeyData(filterValue any) (map[string]any, bool) {
 	fkd, ok := filterValue.(map[string]any)
 	if !ok {
 		return nil, false
 	}
-	// Check if it's wrapped in keyValue
-	if kv, hasKeyValue := fkd["keyValue"].(map[string]any); hasKeyValue {
-		return kv, true
-	}
-	// It's a direct key
-	return fkd, true
+	kv, ok := fkd["keyValue"].(map[string]any)
+	return kv, ok
 }
 
 // matchesKeyFilter checks if an entity matches a __key__ filter.
@@ -1061,18 +1446,9 @@ func matchesKeyFilter(entity map[string]any, operator string, filterValue any) b
 
 // matchesAncestorFilter checks if an entity matches a HAS_ANCESTOR filter.
 func matchesAncestorFilter(entity map[string]any, filterValue any) bool {
-	ancestorKeyData, ok := filterValue.(map[string]any)
+	ancestorKeyData, ok := extractFilterKeyData(filterValue)
 	if !ok {
-		// Try keyValue if wrapped
-		kv, ok := filterValue.(map[string]any)
-		if !ok {
-			return false
-		}
-		ak, ok := kv["keyValue"].(map[string]any)
-		if !ok {
-			return false
-		}
-		ancestorKeyData = ak
+		return false
 	}
 	// Check if entity key has prefix of ancestor key path
 	entityKeyData, ok := entity["key"].(map[string]any)
@@ -1095,6 +1471,10 @@ func extractEntityValue(entityProp map[string]any) any {
 		return boolVal
 	} else if floatVal, ok := entityProp["doubleValue"].(float64); ok {
 		return floatVal
+	} else if tsVal, ok := entityProp["timestampValue"].(string); ok {
+		if ts, err := time.Parse(time.RFC3339Nano, tsVal); err == nil {
+			return ts
+		}
 	}
 	return nil
 }
@@ -1102,51 +1482,32 @@ func extractEntityValue(entityProp map[string]any) any {
 // extractFilterValue extracts a typed value from a filter value.
 func extractFilterValue(filterValue any) any {
 	if fv, ok := filterValue.(map[string]any); ok {
-		if intVal, ok := fv["integerValue"].(string); ok {
-			var i int64
-			if _, err := fmt.Sscanf(intVal, "%d", &i); err == nil {
-				return i
-			}
-		} else if strVal, ok := fv["stringValue"].(string); ok {
-			return strVal
-		}
+		return extractEntityValue(fv)
 	}
 	return nil
 }
 
 // comparePropertyValues compares entity and filter values based on the operator.
 func comparePropertyValues(entityValue, filterVal any, operator string) bool {
+	c, ok := compareTyped(entityValue, filterVal)
+	if !ok {
+		// Values of different or unordered types, such as nulls, can only be equal
+		return operator == "EQUAL" && entityValue == filterVal
+	}
 	switch operator {
 	case "EQUAL":
-		return entityValue == filterVal
+		return c == 0
 	case "GREATER_THAN":
-		if ev, ok := entityValue.(int64); ok {
-			if fv, ok := filterVal.(int64); ok {
-				return ev > fv
-			}
-		}
+		return c > 0
 	case "GREATER_THAN_OR_EQUAL":
-		if ev, ok := entityValue.(int64); ok {
-			if fv, ok := filterVal.(int64); ok {
-				return ev >= fv
-			}
-		}
+		return c >= 0
 	case "LESS_THAN":
-		if ev, ok := entityValue.(int64); ok {
-			if fv, ok := filterVal.(int64); ok {
-				return ev < fv
-			}
-		}
+		return c < 0
 	case "LESS_THAN_OR_EQUAL":
-		if ev, ok := entityValue.(int64); ok {
-			if fv, ok := filterVal.(int64); ok {
-				return ev <= fv
-			}
-		}
+		return c <= 0
 	default:
 		return false
 	}
-	return false
 }
 
 // matchesPropertyFilter checks if an entity matches a property filter.
@@ -1165,32 +1526,82 @@ func matchesPropertyFilter(entity map[string]any, propFilter map[string]any) boo
 	}
 	filterValue := propFilter["value"]
 
+	// Handle HAS_ANCESTOR, which is also a filter on __key__
+	if operator == "HAS_ANCESTOR" {
+		return matchesAncestorFilter(entity, filterValue)
+	}
+
 	// Handle __key__ filters (special property)
 	if propertyName == "__key__" {
 		return matchesKeyFilter(entity, operator, filterValue)
 	}
 
-	// Handle HAS_ANCESTOR
-	if operator == "HAS_ANCESTOR" {
-		return matchesAncestorFilter(entity, filterValue)
-	}
-
 	// Get entity properties
 	properties, ok := entity["properties"].(map[string]any)
 	if !ok {
 		return false
 	}
-	entityProp, ok := properties[propertyName].(map[string]any)
+	entityProp, ok := lookupProperty(properties, propertyName)
 	if !ok {
 		return false // Property doesn't exist
 	}
 
-	// Extract entity and filter values
-	entityValue := extractEntityValue(entityProp)
 	filterVal := extractFilterValue(filterValue)
 
+	// Like Datastore, only match indexed values; an array matches if any of
+	// its indexed elements does
+	if arr, ok := entityProp["arrayValue"].(map[string]any); ok {
+		values, _ := arr["values"].([]any) //nolint:errcheck // Missing values mean an empty array
+		for _, v := range values {
+			elem, ok := v.(map[string]any)
+			if ok && !isUnindexed(elem) && comparePropertyValues(extractEntityValue(elem), filterVal, operator) {
+				return true
+			}
+		}
+		return false
+	}
+	if isUnindexed(entityProp) {
+		return false
+	}
+
 	// Compare based on operator
-	return comparePropertyValues(entityValue, filterVal, operator)
+	return comparePropertyValues(extractEntityValue(entityProp), filterVal, operator)
+}
+
+// isUnindexed reports whether a property value is excluded from indexes.
+func isUnindexed(prop map[string]any) bool {
+	excluded, _ := prop["excludeFromIndexes"].(bool) //nolint:errcheck // Absent means indexed
+	return excluded
+}
+
+// validateFilter rejects filters Datastore would. Each element of an array
+// property is indexed separately, so a filter compares a single value against
+// them; an array filter value is an error, except as the list for IN or NOT_IN.
+// A filter on __key__, including HAS_ANCESTOR, must have a keyValue.
+func validateFilter(filterMap map[string]any) error {
+	if propFilter, ok := filterMap["propertyFilter"].(map[string]any); ok {
+		value, _ := propFilter["value"].(map[string]any)       //nolint:errcheck // Missing values are matched as null
+		op, _ := propFilter["op"].(string)                     //nolint:errcheck // Unknown operators match nothing
+		property, _ := propFilter["property"].(map[string]any) //nolint:errcheck // Missing names match nothing
+		if _, isArray := value["arrayValue"]; isArray && op != "IN" && op != "NOT_IN" {
+			return fmt.Errorf("filter on %v cannot have an array value for operator %s", property["name"], op)
+		}
+		if _, isKey := value["keyValue"]; !isKey && (property["name"] == "__key__" || op == "HAS_ANCESTOR") {
+			return fmt.Errorf("filter on %v must have a key value for operator %s", property["name"], op)
+		}
+		return nil
+	}
+	if compFilter, ok := filterMap["compositeFilter"].(map[string]any); ok {
+		filters, _ := compFilter["filters"].([]any) //nolint:errcheck // Missing filters match everything
+		for _, f := range filters {
+			if fm, ok := f.(map[string]any); ok {
+				if err := validateFilter(fm); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
 }
 
 // matchesFilter checks if an entity matches a filter.
@@ -1243,101 +1654,63 @@ func matchesFilter(entity map[string]any, filterMap map[string]any) bool {
 //
 //	0 if keyA == keyB
 //	1 if keyA > keyB
+//
+// Like Datastore, keys compare by namespace and then path element by element:
+// kind first, then numeric IDs (in numeric order) before names. A key sorts
+// before its descendants.
 func compareKeys(keyA, keyB map[string]any) int {
-	// Extract key strings for comparison
-	strA := keyToSortString(keyA)
-	strB := keyToSortString(keyB)
+	pathA, _ := keyA["path"].([]any) //nolint:errcheck // A missing path compares as empty
+	pathB, _ := keyB["path"].([]any) //nolint:errcheck // A missing path compares as empty
 
-	if strA < strB {
-		return -1
-	}
-	if strA > strB {
-		return 1
-	}
-	return 0
-}
-
-// keyToSortStringFromEntityValue extracts sort string from entityValue format.
-func keyToSortStringFromEntityValue(entityValue map[string]any) string {
-	props, ok := entityValue["properties"].(map[string]any)
-	if !ok {
-		return ""
+	if c := cmp.Compare(keyNamespace(keyA), keyNamespace(keyB)); c != 0 {
+		return c
 	}
-
-	// Extract kind
-	kindProp, ok := props["Kind"].(map[string]any)
-	if !ok {
-		return ""
-	}
-	kind, ok := kindProp["stringValue"].(string)
-	if !ok {
-		return ""
-	}
-
-	// Extract namespace
-	namespace := ""
-	if nsProp, ok := props["Namespace"].(map[string]any); ok {
-		if ns, ok := nsProp["stringValue"].(string); ok && ns != "" {
-			namespace = ns
-		}
-	}
-
-	// Extract name or ID
-	if nameProp, ok := props["Name"].(map[string]any); ok {
-		if name, ok := nameProp["stringValue"].(string); ok && name != "" {
-			return namespace + "!" + kind + "/" + name
-		}
-	}
-	if idProp, ok := props["ID"].(map[string]any); ok {
-		if idInt, ok := idProp["integerValue"].(float64); ok && idInt != 0 {
-			return namespace + "!" + kind + "/" + fmt.Sprintf("%.0f", idInt)
-		}
-		if idStr, ok := idProp["stringValue"].(string); ok && idStr != "" {
-			return namespace + "!" + kind + "/" + idStr
+	for i := range min(len(pathA), len(pathB)) {
+		elemA, _ := pathA[i].(map[string]any) //nolint:errcheck // nil map compares as empty
+		elemB, _ := pathB[i].(map[string]any) //nolint:errcheck // nil map compares as empty
+		if c := comparePathElements(elemA, elemB); c != 0 {
+			return c
 		}
 	}
-	return namespace + "!" + kind + "/"
+	return cmp.Compare(len(pathA), len(pathB))
 }
 
-// keyToSortString converts a key to a string for sorting/comparison.
-// Format: "namespace!kind/name_or_id"
-// Supports both standard key format (with "path") and entityValue format (with "properties").
-func keyToSortString(keyData map[string]any) string {
-	// Try entityValue format first (used by encoded filter values)
-	if entityValue, ok := keyData["entityValue"].(map[string]any); ok {
-		return keyToSortStringFromEntityValue(entityValue)
-	}
-
-	// Try standard path format (used by stored entities)
-	path, ok := keyData["path"].([]any)
-	if !ok || len(path) == 0 {
-		return ""
-	}
-	pathElem, ok := path[0].(map[string]any)
-	if !ok {
-		return ""
-	}
-	kind, ok := pathElem["kind"].(string)
-	if !ok {
-		return ""
+// comparePathElements compares two key path elements by kind, then identifier.
+func comparePathElements(a, b map[string]any) int {
+	kindA, _ := a["kind"].(string) //nolint:errcheck // missing kind compares as empty
+	kindB, _ := b["kind"].(string) //nolint:errcheck // missing kind compares as empty
+	if c := cmp.Compare(kindA, kindB); c != 0 {
+		return c
+	}
+
+	idA, hasIDA := a["id"].(string)
+	idB, hasIDB := b["id"].(string)
+	switch {
+	case hasIDA && hasIDB:
+		nA, errA := strconv.ParseInt(idA, 10, 64)
+		nB, errB := strconv.ParseInt(idB, 10, 64)
+		if errA != nil || errB != nil {
+			return cmp.Compare(idA, idB)
+		}
+		return cmp.Compare(nA, nB)
+	case hasIDA:
+		return -1
+	case hasIDB:
+		return 1
 	}
+	nameA, _ := a["name"].(string) //nolint:errcheck // missing name compares as empty
+	nameB, _ := b["name"].(string) //nolint:errcheck // missing name compares as empty
+	return cmp.Compare(nameA, nameB)
+}
 
-	// Extract namespace
-	namespace := ""
+// keyNamespace returns the namespace of a key, or "" for the default namespace.
+func keyNamespace(keyData map[string]any) string {
 	if pid, ok := keyData["partitionId"].(map[string]any); ok {
 		if ns, ok := pid["namespaceId"].(string); ok {
-			namespace = ns
+			return ns
 		}
 	}
-
-	// Handle both name and ID keys
-	if name, ok := pathElem["name"].(string); ok {
-		return namespace + "!" + kind + "/" + name
-	}
-	if id, ok := pathElem["id"].(string); ok {
-		return namespace + "!" + kind + "/" + id
-	}
-	return namespace + "!" + kind + "/"
+	return ""
 }
 
 // isAncestor checks if ancestorKey is a prefix of entityKey.
@@ -1381,6 +1754,7 @@ func (s *Store) handleRunAggregationQuery(w http.ResponseWriter, r *http.Request
 	var req struct { //nolint:govet // Local anonymous struct for JSON unmarshaling
 		DatabaseID       string         `json:"databaseId"`
 		AggregationQuery map[string]any `json:"aggregationQuery"`
+		PartitionID      map[string]any `json:"partitionId"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1433,40 +1807,25 @@ func (s *Store) handleRunAggregationQuery(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Count entities of this kind in store
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	namespace, _ := req.PartitionID["namespaceId"].(string) //nolint:errcheck // Absent means the default namespace
 
-	count := 0
-	// entities map is keyed by "kind/keyname", so we need to iterate
-	for keyStr, entity := range s.entities {
-		// Extract kind from entity's key
-		keyData, ok := entity["key"].(map[string]any)
-		if !ok {
-			continue
-		}
-		path, ok := keyData["path"].([]any)
-		if !ok || len(path) == 0 {
-			continue
-		}
-		pathElem, ok := path[0].(map[string]any)
-		if !ok {
-			continue
-		}
-		entityKind, ok := pathElem["kind"].(string)
-		if !ok || entityKind != kind {
-			continue
+	if filterMap, ok := nestedQuery["filter"].(map[string]any); ok {
+		if err := validateFilter(filterMap); err != nil {
+			s.writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+			return
 		}
+	}
 
-		// Apply filters if present
-		if filterMap, hasFilter := nestedQuery["filter"].(map[string]any); hasFilter {
-			if !matchesFilter(entity, filterMap) {
-				continue
-			}
-		}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-		_ = keyStr
-		count++
+	// Count what the nested query would return, after its offset and up to its limit
+	count := len(s.matchingEntities(nestedQuery, kind, namespace))
+	if o, ok := nestedQuery["offset"].(float64); ok {
+		count = max(count-int(o), 0)
+	}
+	if l, ok := nestedQuery["limit"].(float64); ok && l > 0 {
+		count = min(count, int(l))
 	}
 
 	w.WriteHeader(http.StatusOK)
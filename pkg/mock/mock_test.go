@@ -3,7 +3,12 @@ package mock_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/codeGROOVE-dev/ds9/pkg/datastore"
 	"github.com/codeGROOVE-dev/ds9/pkg/mock"
@@ -755,3 +760,416 @@ func TestMockTransactionValidation(t *testing.T) {
 		t.Errorf("Expected Name 'modified', got %q", result.Name)
 	}
 }
+
+func TestMockQueryOrderOffsetLimit(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type TestEntity struct {
+		Rank int64 `datastore:"rank"`
+	}
+
+	// Insert in an order unrelated to rank, so key order can't mask a missing sort
+	for i := range 10 {
+		rank := int64((i * 7) % 10)
+		key := datastore.NameKey("PageKind", string(rune('a'+i)), nil)
+		if _, err := client.Put(ctx, key, &TestEntity{Rank: rank}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	query := datastore.NewQuery("PageKind").Order("-rank").Offset(2).Limit(3)
+	var got []TestEntity
+	if _, err := client.GetAll(ctx, query, &got); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+
+	want := []int64{7, 6, 5}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d results, got %d: %+v", len(want), len(got), got)
+	}
+	for i, r := range want {
+		if got[i].Rank != r {
+			t.Errorf("result %d: expected rank %d, got %d", i, r, got[i].Rank)
+		}
+	}
+}
+
+func TestMockTransactionIsolation(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type TestEntity struct {
+		Counter int64 `datastore:"counter"`
+	}
+	key := datastore.NameKey("TxIsolation", "counter", nil)
+	if _, err := client.Put(ctx, key, &TestEntity{Counter: 1}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	t.Run("RollbackDiscardsWrites", func(t *testing.T) {
+		_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+			if _, err := tx.Put(key, &TestEntity{Counter: 99}); err != nil {
+				return err
+			}
+			return errors.New("force rollback")
+		})
+		if err == nil {
+			t.Fatal("expected transaction to fail")
+		}
+		var got TestEntity
+		if err := client.Get(ctx, key, &got); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got.Counter != 1 {
+			t.Errorf("expected Counter 1 after rollback, got %d", got.Counter)
+		}
+	})
+
+	t.Run("ReadsSeeSnapshotAndConflictAborts", func(t *testing.T) {
+		tx, err := client.NewTransaction(ctx)
+		if err != nil {
+			t.Fatalf("NewTransaction failed: %v", err)
+		}
+
+		// A write outside the transaction after it began
+		if _, err := client.Put(ctx, key, &TestEntity{Counter: 2}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+
+		var seen TestEntity
+		if err := tx.Get(key, &seen); err != nil {
+			t.Fatalf("tx.Get failed: %v", err)
+		}
+		if seen.Counter != 1 {
+			t.Errorf("expected transaction to read Counter 1 from its snapshot, got %d", seen.Counter)
+		}
+
+		if _, err := tx.Put(key, &TestEntity{Counter: seen.Counter + 10}); err != nil {
+			t.Fatalf("tx.Put failed: %v", err)
+		}
+		if _, err := tx.Commit(); err == nil {
+			t.Fatal("expected commit of stale read to be aborted")
+		}
+
+		var got TestEntity
+		if err := client.Get(ctx, key, &got); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got.Counter != 2 {
+			t.Errorf("expected outside write to survive, got Counter %d", got.Counter)
+		}
+	})
+
+	t.Run("CommitPersists", func(t *testing.T) {
+		_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+			var current TestEntity
+			if err := tx.Get(key, &current); err != nil {
+				return err
+			}
+			current.Counter += 5
+			_, err := tx.Put(key, &current)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("RunInTransaction failed: %v", err)
+		}
+		var got TestEntity
+		if err := client.Get(ctx, key, &got); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if got.Counter != 7 {
+			t.Errorf("expected Counter 7, got %d", got.Counter)
+		}
+	})
+
+	t.Run("InsertConflict", func(t *testing.T) {
+		_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+			_, err := tx.Mutate(datastore.NewInsert(key, &TestEntity{Counter: 100}))
+			return err
+		})
+		if !errors.Is(err, datastore.ErrAlreadyExists) {
+			t.Errorf("expected ErrAlreadyExists, got %v", err)
+		}
+	})
+}
+
+func TestMockQueryEvaluation(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type Item struct {
+		Name  string `datastore:"name"`
+		Count int64  `datastore:"count"`
+	}
+	parent := datastore.NameKey("Shelf", "s1", nil)
+	for i := range 10 {
+		// Half the items are children of a shelf; both halves are of kind Item
+		var p *datastore.Key
+		if i%2 == 0 {
+			p = parent
+		}
+		key := datastore.NameKey("Item", fmt.Sprintf("item-%d", i), p)
+		if _, err := client.Put(ctx, key, &Item{Name: key.Name, Count: int64(i)}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	other := datastore.NameKey("Item", "elsewhere", nil)
+	other.Namespace = "other"
+	if _, err := client.Put(ctx, other, &Item{Name: "elsewhere", Count: 100}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	n, err := client.Count(ctx, datastore.NewQuery("Item").Filter("count >=", 5))
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("Count(count >= 5) = %d, want 5", n)
+	}
+
+	n, err = client.Count(ctx, datastore.NewQuery("Item").Filter("count >=", 5).Offset(1).Limit(3))
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Count with offset 1 and limit 3 = %d, want 3", n)
+	}
+
+	n, err = client.Count(ctx, datastore.NewQuery("Item").Namespace("other"))
+	if err != nil {
+		t.Fatalf("Count failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Count in namespace other = %d, want 1", n)
+	}
+
+	var items []Item
+	if _, err := client.GetAll(ctx, datastore.NewQuery("Item").Filter("count <", 8).Order("-count").Offset(2).Limit(3), &items); err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	var got []int64
+	for _, it := range items {
+		got = append(got, it.Count)
+	}
+	if want := []int64{5, 4, 3}; !slices.Equal(got, want) {
+		t.Errorf("GetAll(count < 8, -count, offset 2, limit 3) = %v, want %v", got, want)
+	}
+}
+
+func TestMockLatency(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t, mock.WithLatency(100*time.Millisecond))
+	defer cleanup()
+
+	type TestEntity struct {
+		Name string `datastore:"name"`
+	}
+	key := datastore.NameKey("Slow", "k", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var got TestEntity
+	err := client.Get(ctx, key, &got)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("expected Get to give up at the deadline, took %v", elapsed)
+	}
+
+	// Without a deadline the request completes after the latency
+	start = time.Now()
+	if _, err := client.Put(context.Background(), key, &TestEntity{Name: "slow"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected Put to take at least the configured latency, took %v", elapsed)
+	}
+}
+
+func TestMockClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client, cleanup := datastore.NewMockClient(t, mock.WithClock(func() time.Time { return now }))
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type TestEntity struct {
+		Version int64  `datastore:",version"`
+		Name    string `datastore:"name"`
+	}
+	key := datastore.NameKey("Clocked", "k", nil)
+	first := &TestEntity{Name: "a"}
+	if _, err := client.Put(ctx, key, first); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	var got TestEntity
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if want := now.UnixMicro(); got.Version != want {
+		t.Errorf("expected version %d from the clock, got %d", want, got.Version)
+	}
+
+	// A clock standing still still yields increasing versions
+	if _, err := client.Put(ctx, key, &TestEntity{Name: "b"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Version != now.UnixMicro()+1 {
+		t.Errorf("expected version %d, got %d", now.UnixMicro()+1, got.Version)
+	}
+}
+
+func TestMockFailOn(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t, mock.FailOn("commit", 2, http.StatusServiceUnavailable))
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type TestEntity struct {
+		Name string `datastore:"name"`
+	}
+	key := datastore.NameKey("Flaky", "k", nil)
+
+	// Lookups are unaffected by a commit fault
+	var got TestEntity
+	if err := client.Get(ctx, key, &got); !errors.Is(err, datastore.ErrNoSuchEntity) {
+		t.Fatalf("expected ErrNoSuchEntity, got %v", err)
+	}
+
+	// Put retries through both injected 503s
+	if _, err := client.Put(ctx, key, &TestEntity{Name: "persisted"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Name != "persisted" {
+		t.Errorf("expected persisted, got %q", got.Name)
+	}
+}
+
+func TestMockFailNext(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t, mock.FailNext(http.StatusBadRequest))
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type TestEntity struct {
+		Name string `datastore:"name"`
+	}
+	key := datastore.NameKey("Flaky", "k", nil)
+
+	// A 400 isn't retried, so the first request fails and the next succeeds
+	if _, err := client.Put(ctx, key, &TestEntity{Name: "a"}); err == nil {
+		t.Fatal("expected injected failure")
+	}
+	if _, err := client.Put(ctx, key, &TestEntity{Name: "a"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+}
+
+func TestMockFailOnRunningStore(t *testing.T) {
+	store := mock.NewStore()
+	client, cleanup := datastore.NewMockClientWithStore(t, store)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type TestEntity struct {
+		Name string `datastore:"name"`
+	}
+	key := datastore.NameKey("Flaky", "k", nil)
+
+	if _, err := client.Put(ctx, key, &TestEntity{Name: "a"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Faults armed after setup apply to the next matching requests
+	store.FailOn("lookup", 1, http.StatusForbidden)
+	var got TestEntity
+	if err := client.Get(ctx, key, &got); err == nil {
+		t.Fatal("expected injected lookup failure")
+	}
+	if err := client.Get(ctx, key, &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	store.FailNext(http.StatusBadRequest)
+	if _, err := client.Put(ctx, key, &TestEntity{Name: "b"}); err == nil {
+		t.Fatal("expected injected failure")
+	}
+	if _, err := client.Put(ctx, key, &TestEntity{Name: "b"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+}
+
+func TestMockKeyFilters(t *testing.T) {
+	client, cleanup := datastore.NewMockClient(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type TestEntity struct {
+		Name string `datastore:"name"`
+	}
+	parent := datastore.NameKey("Parent", "p", nil)
+	other := datastore.NameKey("Parent", "q", nil)
+	keys := []*datastore.Key{
+		datastore.IDKey("Child", 1, parent),
+		datastore.IDKey("Child", 2, parent),
+		datastore.IDKey("Child", 3, other),
+	}
+	if _, err := client.PutMulti(ctx, keys, make([]TestEntity, len(keys))); err != nil {
+		t.Fatalf("PutMulti failed: %v", err)
+	}
+
+	// Keys with parents compare by their full path
+	got, err := client.AllKeys(ctx, datastore.NewQuery("Child").KeysOnly().FilterField("__key__", ">", keys[0]))
+	if err != nil {
+		t.Fatalf("AllKeys failed: %v", err)
+	}
+	if len(got) != 2 || !got[0].Equal(keys[1]) || !got[1].Equal(keys[2]) {
+		t.Errorf("expected keys after %v, got %v", keys[0], got)
+	}
+
+	got, err = client.AllKeys(ctx, datastore.NewQuery("Child").KeysOnly().Ancestor(parent))
+	if err != nil {
+		t.Fatalf("AllKeys failed: %v", err)
+	}
+	if len(got) != 2 || !got[0].Equal(keys[0]) || !got[1].Equal(keys[1]) {
+		t.Errorf("expected the children of %v, got %v", parent, got)
+	}
+}
+
+func TestMockRejectsNonKeyKeyFilter(t *testing.T) {
+	_, apiURL, cleanup := mock.NewMockServers(t)
+	defer cleanup()
+
+	body := `{"query":{"kind":[{"name":"Child"}],"filter":{"propertyFilter":{` +
+		`"property":{"name":"__key__"},"op":"EQUAL","value":{"entityValue":{"properties":{}}}}}}}`
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost,
+		apiURL+"/projects/test-project:runQuery", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer test-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // Test cleanup
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for a __key__ filter without a key value, got %d", resp.StatusCode)
+	}
+}